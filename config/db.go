@@ -67,7 +67,7 @@ func setupCollections(client *mongo.Client) {
 	db := client.Database(dbName)
 
 	// Ensure collections exist
-	collections := []string{"users", "companies", "serviceProviders", "wholesalers"}
+	collections := []string{"users", "companies", "serviceProviders", "wholesalers", "sessions", "auth_attempts", "settings", "audit_events", "api_keys", "data_exports"}
 	for _, collName := range collections {
 		db.CreateCollection(ctx, collName)
 	}
@@ -85,6 +85,15 @@ func setupCollections(client *mongo.Client) {
 		log.Printf("Error creating email index: %v", err)
 	}
 
+	// Accounts pending deletion are found by the purge job
+	_, err = userColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "deletionScheduledAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		log.Printf("Error creating deletionScheduledAt index: %v", err)
+	}
+
 	// UserId index for entity collections
 	for _, collName := range []string{"companies", "serviceProviders", "wholesalers"} {
 		coll := db.Collection(collName)
@@ -98,5 +107,184 @@ func setupCollections(client *mongo.Client) {
 		}
 	}
 
+	// A verified phone can reset a password, so it must identify a single account
+	phoneIndexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "phone", Value: 1}},
+		Options: options.Index().
+			SetName("phone_verified_unique").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"phoneVerified": true}),
+	}
+	if _, err := userColl.Indexes().CreateOne(ctx, phoneIndexModel); err != nil {
+		log.Printf("Error creating phone index: %v", err)
+	}
+
+	// Passwordless login links are looked up by the hash of their token
+	loginTokenIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "otpInfo.tokenHash", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	}
+	if _, err := userColl.Indexes().CreateOne(ctx, loginTokenIndexModel); err != nil {
+		log.Printf("Error creating login token index: %v", err)
+	}
+
+	// Accounts created before email verification existed are treated as verified
+	_, err = userColl.UpdateMany(ctx,
+		bson.M{"emailVerified": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"emailVerified": true}},
+	)
+	if err != nil {
+		log.Printf("Error backfilling emailVerified: %v", err)
+	}
+
+	// Reset tokens used to be stored in plain text; they are dropped so only hashed tokens remain
+	_, err = userColl.UpdateMany(ctx,
+		bson.M{"resetPasswordToken": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"resetPasswordToken": "", "resetTokenExpiresAt": ""}},
+	)
+	if err != nil {
+		log.Printf("Error removing plain text reset tokens: %v", err)
+	}
+
+	// Accounts created before identities were tracked get entries for the login methods they already have
+	identityBackfills := []struct {
+		provider string
+		filter   bson.M
+		identity bson.M
+	}{
+		{
+			provider: "password",
+			filter:   bson.M{"password": bson.M{"$exists": true, "$ne": ""}},
+			identity: bson.M{"provider": "password", "linkedAt": "$createdAt"},
+		},
+		{
+			provider: "google",
+			filter:   bson.M{"googleUID": bson.M{"$exists": true, "$ne": ""}},
+			identity: bson.M{"provider": "google", "providerUid": "$googleUID", "email": "$email", "linkedAt": "$createdAt"},
+		},
+	}
+	for _, backfill := range identityBackfills {
+		backfill.filter["identities.provider"] = bson.M{"$ne": backfill.provider}
+		_, err = userColl.UpdateMany(ctx, backfill.filter, mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"identities": bson.M{"$concatArrays": bson.A{
+				bson.M{"$ifNull": bson.A{"$identities", bson.A{}}},
+				bson.A{backfill.identity},
+			}}}}},
+		})
+		if err != nil {
+			log.Printf("Error backfilling %s identities: %v", backfill.provider, err)
+		}
+	}
+
+	// Promote the bootstrap admin so the first admin can manage everyone else
+	if adminEmail := os.Getenv("BOOTSTRAP_ADMIN_EMAIL"); adminEmail != "" {
+		promoteBootstrapAdmin(ctx, userColl, adminEmail)
+	}
+
+	// Session indexes: lookups by user and refresh token, and TTL cleanup of expired sessions
+	sessionColl := db.Collection("sessions")
+	sessionIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "refreshTokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "previousTokenHashes", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "impersonatorId", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	if _, err := sessionColl.Indexes().CreateMany(ctx, sessionIndexes); err != nil {
+		log.Printf("Error creating session indexes: %v", err)
+	}
+
+	// Authentication attempt counters expire on their own so lockouts end without cleanup jobs
+	attemptColl := db.Collection("auth_attempts")
+	attemptIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	if _, err := attemptColl.Indexes().CreateMany(ctx, attemptIndexes); err != nil {
+		log.Printf("Error creating auth attempt indexes: %v", err)
+	}
+
+	// Audit events are append-only and queried newest first by actor, target or action
+	auditColl := db.Collection("audit_events")
+	auditIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "impersonatorId", Value: 1}, {Key: "createdAt", Value: -1}},
+			Options: options.Index().SetSparse(true),
+		},
+	}
+	if _, err := auditColl.Indexes().CreateMany(ctx, auditIndexes); err != nil {
+		log.Printf("Error creating audit event indexes: %v", err)
+	}
+
+	// Data export indexes: a user's recent exports and cleanup of expired ones
+	exportColl := db.Collection("data_exports")
+	exportIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}},
+	}
+	if _, err := exportColl.Indexes().CreateMany(ctx, exportIndexes); err != nil {
+		log.Printf("Error creating data export indexes: %v", err)
+	}
+
+	// API keys are looked up by the hash of the key and listed per company
+	apiKeyColl := db.Collection("api_keys")
+	apiKeyIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "keyHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "companyId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	}
+	if _, err := apiKeyColl.Indexes().CreateMany(ctx, apiKeyIndexes); err != nil {
+		log.Printf("Error creating API key indexes: %v", err)
+	}
+
 	log.Println("Database collections and indexes setup complete")
 }
+
+// promoteBootstrapAdmin makes the verified account with the email an admin. It only runs while no
+// admin exists, so whoever later signs up with the address cannot take over a running system.
+func promoteBootstrapAdmin(ctx context.Context, userColl *mongo.Collection, adminEmail string) {
+	admins, err := userColl.CountDocuments(ctx, bson.M{"userType": "admin"}, options.Count().SetLimit(1))
+	if err != nil {
+		log.Printf("Error checking for an existing admin: %v", err)
+		return
+	}
+	if admins > 0 {
+		return
+	}
+
+	result, err := userColl.UpdateOne(ctx,
+		bson.M{"email": adminEmail, "emailVerified": true},
+		bson.M{"$set": bson.M{"userType": "admin", "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		log.Printf("Error promoting bootstrap admin: %v", err)
+		return
+	}
+	if result.MatchedCount == 0 {
+		log.Printf("WARNING: no admin exists and BOOTSTRAP_ADMIN_EMAIL %s does not match a verified account; "+
+			"sign up and verify that address, then restart", adminEmail)
+		return
+	}
+	log.Printf("Promoted bootstrap admin %s", adminEmail)
+}
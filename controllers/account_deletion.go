@@ -0,0 +1,155 @@
+// controllers/account_deletion.go
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// accountDeletionGracePeriod returns how long a deleted account can still be restored
+func accountDeletionGracePeriod() time.Duration {
+	return config.GetEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour)
+}
+
+// pendingDeletionError rejects a sign-in to an account that is scheduled for deletion
+func pendingDeletionError(user *models.User) error {
+	return models.ErrAccountPendingDeletion.WithData(map[string]interface{}{
+		"deletionScheduledAt": user.DeletionScheduledAt,
+	})
+}
+
+// restoreAccount cancels the pending deletion of an account while its grace period is running
+func restoreAccount(ctx context.Context, db *mongo.Client, userID primitive.ObjectID) (bool, error) {
+	result, err := config.GetCollection(db, "users").UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "deletionScheduledAt": bson.M{"$gt": time.Now()}},
+		bson.M{
+			"$set":   bson.M{"updatedAt": time.Now()},
+			"$unset": bson.M{"deletionRequestedAt": "", "deletionScheduledAt": ""},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// RestoreAccount cancels a pending deletion after the owner proves it is their account.
+// No session is issued; the user logs in normally afterwards, including any second factor.
+func (ac *AuthController) RestoreAccount(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var restoreReq models.RestoreAccountRequest
+	if err := c.Bind(&restoreReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	if restoreReq.IDToken == "" && (restoreReq.Email == "" || restoreReq.Password == "") {
+		return models.ErrMissingFields.WithMessage("Email and password or a Google ID token are required")
+	}
+
+	// Get user collection
+	collection := config.GetCollection(ac.DB, "users")
+
+	var user models.User
+	if restoreReq.IDToken != "" {
+		googleClaims, err := ac.GoogleVerifier.Verify(ctx, restoreReq.IDToken)
+		if err != nil {
+			log.Printf("Google ID token rejected: %v", err)
+			return models.ErrInvalidGoogleToken
+		}
+		err = collection.FindOne(ctx, bson.M{"googleUID": googleClaims.Subject}).Decode(&user)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return models.ErrGoogleAccountNotLinked
+			}
+			return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+		}
+	} else {
+		// Password checks share the login lockout so this cannot be used to guess passwords
+		accountKey := loginAccountKey(restoreReq.Email)
+		ipKey := loginIPKey(c.RealIP())
+		lockedUntil, err := checkLockout(ctx, ac.DB, accountKey, ipKey)
+		if err != nil {
+			return models.ErrInternal.WithMessage("Failed to check login attempts").Wrap(err)
+		}
+		if !lockedUntil.IsZero() {
+			return tooManyRequests(c, lockedUntil, "Too many failed login attempts. Please try again later")
+		}
+
+		err = collection.FindOne(ctx, bson.M{"email": restoreReq.Email}).Decode(&user)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+		}
+		if err == mongo.ErrNoDocuments || utils.CheckPassword(restoreReq.Password, user.Password) != nil {
+			ac.recordLoginFailure(ctx, accountKey, ipKey)
+			return models.ErrInvalidCredentials
+		}
+		if err := clearAttempts(ctx, ac.DB, accountKey); err != nil {
+			log.Printf("Failed to clear login attempts for %s: %v", restoreReq.Email, err)
+		}
+	}
+
+	if !user.PendingDeletion() {
+		return models.ErrAccountNotPendingDeletion
+	}
+
+	restored, err := restoreAccount(ctx, ac.DB, user.ID)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to restore account").Wrap(err)
+	}
+	if !restored {
+		return models.ErrAccountRestoreExpired
+	}
+
+	recordAudit(c, ac.DB, auditUser(models.AuditAccountRestored, &user))
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Account restored successfully. Please log in",
+	})
+}
+
+// RestoreUser lets an admin cancel the pending deletion of an account
+func (ac *AdminController) RestoreUser(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	restored, err := restoreAccount(ctx, ac.DB, userID)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to restore account").Wrap(err)
+	}
+	if !restored {
+		return models.ErrAccountNotRestorable
+	}
+
+	recordAudit(c, ac.DB, models.AuditEvent{
+		Action:     models.AuditAccountRestored,
+		TargetType: "user",
+		TargetID:   userID.Hex(),
+	})
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Account restored successfully",
+	})
+}
@@ -0,0 +1,576 @@
+// controllers/admin_controller.go
+package controllers
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/middleware"
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+// AdminController contains administrative operations on user accounts
+type AdminController struct {
+	DB *mongo.Client
+}
+
+// NewAdminController creates a new admin controller
+func NewAdminController(db *mongo.Client) *AdminController {
+	return &AdminController{DB: db}
+}
+
+// assignableUserTypes lists every account type an admin can assign
+var assignableUserTypes = map[string]bool{
+	"user":            true,
+	"company":         true,
+	"wholesaler":      true,
+	"serviceProvider": true,
+	"admin":           true,
+}
+
+// userSecretsProjection hides credentials and one-time codes from user listings
+var userSecretsProjection = bson.M{
+	"password":          0,
+	"otpInfo":           0,
+	"emailVerification": 0,
+	"emailChange":       0,
+	"phoneVerification": 0,
+	"resetTokenHash":    0,
+	"twoFactor":         0,
+}
+
+// ListUsers returns users filtered by search text, user type and suspension status
+func (ac *AdminController) ListUsers(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Get pagination parameters
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20 // default limit
+	}
+	skip := (page - 1) * limit
+
+	// Build filter
+	filter := bson.M{}
+
+	if userType := c.QueryParam("userType"); userType != "" {
+		filter["userType"] = userType
+	}
+
+	if suspended := c.QueryParam("suspended"); suspended != "" {
+		isSuspended, err := strconv.ParseBool(suspended)
+		if err != nil {
+			return models.ErrInvalidFilter.WithMessage("Invalid suspended filter")
+		}
+		if isSuspended {
+			filter["suspended"] = true
+		} else {
+			filter["suspended"] = bson.M{"$ne": true}
+		}
+	}
+
+	if search := c.QueryParam("q"); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"email": pattern},
+			bson.M{"fullName": pattern},
+			bson.M{"phone": pattern},
+			bson.M{"companyInfo.name": pattern},
+			bson.M{"wholesalerInfo.businessName": pattern},
+		}
+	}
+
+	// Get user collection
+	collection := config.GetCollection(ac.DB, "users")
+
+	opts := options.Find().
+		SetProjection(userSecretsProjection).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to fetch users").Wrap(err)
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return models.ErrInternal.WithMessage("Failed to decode users").Wrap(err)
+	}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to count users").Wrap(err)
+	}
+
+	// Calculate pagination metadata
+	totalPages := int(math.Ceil(float64(totalCount) / float64(limit)))
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Users retrieved successfully",
+		Data: map[string]interface{}{
+			"users": users,
+			"pagination": map[string]interface{}{
+				"totalCount": totalCount,
+				"page":       page,
+				"limit":      limit,
+				"totalPages": totalPages,
+			},
+		},
+	})
+}
+
+// SuspendUser suspends an account and revokes all of its sessions
+func (ac *AdminController) SuspendUser(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	// Admins cannot lock themselves out
+	principal := middleware.GetPrincipal(c)
+	if principal.UserID == userID.Hex() {
+		return models.ErrSelfActionDenied.WithMessage("You cannot suspend your own account")
+	}
+
+	var suspendReq models.SuspendUserRequest
+	if err := c.Bind(&suspendReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	now := time.Now()
+	result, err := config.GetCollection(ac.DB, "users").UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{
+			"suspended":        true,
+			"suspendedAt":      now,
+			"suspensionReason": suspendReq.Reason,
+			"updatedAt":        now,
+		}},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to suspend user").Wrap(err)
+	}
+
+	if result.MatchedCount == 0 {
+		return models.ErrUserNotFound
+	}
+
+	// Existing tokens stop working immediately
+	if err := middleware.RevokeUserSessions(ctx, ac.DB, userID, primitive.NilObjectID); err != nil {
+		log.Printf("Failed to revoke sessions of suspended user %s: %v", userID.Hex(), err)
+	}
+	// Including the sessions a suspended admin opened as other users
+	if err := middleware.RevokeImpersonationSessions(ctx, ac.DB, userID); err != nil {
+		log.Printf("Failed to revoke impersonation sessions of suspended user %s: %v", userID.Hex(), err)
+	}
+
+	recordAudit(c, ac.DB, models.AuditEvent{
+		Action:     models.AuditUserSuspended,
+		TargetType: "user",
+		TargetID:   userID.Hex(),
+		Metadata:   map[string]interface{}{"reason": suspendReq.Reason},
+	})
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "User suspended successfully",
+	})
+}
+
+// UnsuspendUser lifts the suspension of an account
+func (ac *AdminController) UnsuspendUser(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	result, err := config.GetCollection(ac.DB, "users").UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set":   bson.M{"updatedAt": time.Now()},
+			"$unset": bson.M{"suspended": "", "suspendedAt": "", "suspensionReason": ""},
+		},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to unsuspend user").Wrap(err)
+	}
+
+	if result.MatchedCount == 0 {
+		return models.ErrUserNotFound
+	}
+
+	recordAudit(c, ac.DB, models.AuditEvent{
+		Action:     models.AuditUserUnsuspended,
+		TargetType: "user",
+		TargetID:   userID.Hex(),
+	})
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "User unsuspended successfully",
+	})
+}
+
+// ChangeUserType changes an account's type and signs it out so new tokens carry the new type
+func (ac *AdminController) ChangeUserType(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	var changeReq models.ChangeUserTypeRequest
+	if err := c.Bind(&changeReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	if !assignableUserTypes[changeReq.UserType] {
+		return models.ErrInvalidUserType
+	}
+
+	// Admins cannot demote themselves and leave the system without an admin
+	principal := middleware.GetPrincipal(c)
+	if principal.UserID == userID.Hex() {
+		return models.ErrSelfActionDenied.WithMessage("You cannot change your own user type")
+	}
+
+	collection := config.GetCollection(ac.DB, "users")
+	set := bson.M{"userType": changeReq.UserType, "updatedAt": time.Now()}
+	before := auditSnapshot(ctx, collection, bson.M{"_id": userID}, set)
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": set})
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to change user type").Wrap(err)
+	}
+
+	if result.MatchedCount == 0 {
+		return models.ErrUserNotFound
+	}
+
+	if err := middleware.RevokeUserSessions(ctx, ac.DB, userID, primitive.NilObjectID); err != nil {
+		log.Printf("Failed to revoke sessions of user %s: %v", userID.Hex(), err)
+	}
+	// A demoted admin also loses the sessions they opened as other users
+	if err := middleware.RevokeImpersonationSessions(ctx, ac.DB, userID); err != nil {
+		log.Printf("Failed to revoke impersonation sessions of user %s: %v", userID.Hex(), err)
+	}
+
+	recordAudit(c, ac.DB, models.AuditEvent{
+		Action:     models.AuditUserTypeChanged,
+		TargetType: "user",
+		TargetID:   userID.Hex(),
+		Changes:    auditChanges(before, set),
+	})
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "User type changed successfully",
+		Data: map[string]interface{}{
+			"id":       userID.Hex(),
+			"userType": changeReq.UserType,
+		},
+	})
+}
+
+// ImpersonateUser issues a short-lived token that lets support staff act as another account.
+// Requests made with it are flagged in audit events and response headers, and destructive
+// actions stay forbidden.
+func (ac *AdminController) ImpersonateUser(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	var impersonateReq models.ImpersonateUserRequest
+	if err := c.Bind(&impersonateReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	if strings.TrimSpace(impersonateReq.Reason) == "" {
+		return models.ErrMissingFields.WithMessage("A reason is required to impersonate a user")
+	}
+
+	principal := middleware.GetPrincipal(c)
+	adminID, err := primitive.ObjectIDFromHex(principal.UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+	if adminID == userID {
+		return models.ErrImpersonationTarget.WithMessage("You cannot impersonate your own account")
+	}
+
+	var user models.User
+	err = config.GetCollection(ac.DB, "users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrUserNotFound
+		}
+		return models.ErrInternal.WithMessage("Failed to retrieve user").Wrap(err)
+	}
+
+	// Acting as another admin would hand out admin permissions without their credentials
+	if user.UserType == "admin" {
+		return models.ErrImpersonationDenied
+	}
+	if user.Suspended {
+		return models.ErrImpersonationTarget.WithMessage("Suspended accounts cannot be impersonated")
+	}
+
+	tokens, err := middleware.IssueImpersonationToken(ctx, ac.DB, &user, adminID, middleware.DeviceFromRequest(c), c.RealIP())
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to create impersonation token").Wrap(err)
+	}
+
+	recordAudit(c, ac.DB, models.AuditEvent{
+		Action:     models.AuditImpersonationStarted,
+		TargetType: "user",
+		TargetID:   userID.Hex(),
+		Metadata: map[string]interface{}{
+			"reason":    impersonateReq.Reason,
+			"sessionId": tokens.SessionID,
+			"expiresIn": tokens.ExpiresIn,
+		},
+	})
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Impersonation token created successfully",
+		Data: map[string]interface{}{
+			"token":     tokens.AccessToken,
+			"expiresIn": tokens.ExpiresIn,
+			"user": map[string]interface{}{
+				"id":       user.ID.Hex(),
+				"email":    user.Email,
+				"fullName": user.FullName,
+				"userType": user.UserType,
+			},
+		},
+	})
+}
+
+// GetTwoFactorPolicy returns the user types that must use two-factor authentication
+func (ac *AdminController) GetTwoFactorPolicy(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	policy, err := middleware.LoadTwoFactorPolicy(ctx, ac.DB)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to load two-factor policy").Wrap(err)
+	}
+	if policy.RequiredUserTypes == nil {
+		policy.RequiredUserTypes = []string{}
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Two-factor policy retrieved successfully",
+		Data:    policy,
+	})
+}
+
+// UpdateTwoFactorPolicy replaces the user types that must use two-factor authentication
+func (ac *AdminController) UpdateTwoFactorPolicy(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var policyReq models.UpdateTwoFactorPolicyRequest
+	if err := c.Bind(&policyReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	requiredUserTypes := []string{}
+	seen := map[string]bool{}
+	for _, userType := range policyReq.RequiredUserTypes {
+		if !assignableUserTypes[userType] {
+			return models.ErrInvalidUserType.WithMessage("Invalid user type: " + userType)
+		}
+		if !seen[userType] {
+			seen[userType] = true
+			requiredUserTypes = append(requiredUserTypes, userType)
+		}
+	}
+
+	adminID, err := primitive.ObjectIDFromHex(middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	policy := models.TwoFactorPolicy{
+		ID:                models.TwoFactorPolicySettingID,
+		RequiredUserTypes: requiredUserTypes,
+		UpdatedBy:         adminID,
+		UpdatedAt:         time.Now(),
+	}
+	_, err = config.GetCollection(ac.DB, "settings").ReplaceOne(
+		ctx,
+		bson.M{"_id": models.TwoFactorPolicySettingID},
+		policy,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to update two-factor policy").Wrap(err)
+	}
+
+	recordAudit(c, ac.DB, models.AuditEvent{
+		Action:     models.AuditTwoFactorPolicySet,
+		TargetType: "settings",
+		TargetID:   models.TwoFactorPolicySettingID,
+		Metadata:   map[string]interface{}{"requiredUserTypes": requiredUserTypes},
+	})
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Two-factor policy updated successfully",
+		Data:    policy,
+	})
+}
+
+// ListAuditEvents returns audit events filtered by action, actor, target and time range, newest first
+func (ac *AdminController) ListAuditEvents(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Get pagination parameters
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 50 // default limit
+	}
+	skip := (page - 1) * limit
+
+	// Build filter
+	filter := bson.M{}
+
+	if action := c.QueryParam("action"); action != "" {
+		filter["action"] = action
+	}
+	if targetType := c.QueryParam("targetType"); targetType != "" {
+		filter["targetType"] = targetType
+	}
+	if targetID := c.QueryParam("targetId"); targetID != "" {
+		filter["targetId"] = targetID
+	}
+	if ip := c.QueryParam("ip"); ip != "" {
+		filter["ip"] = ip
+	}
+
+	if impersonatorID := c.QueryParam("impersonatorId"); impersonatorID != "" {
+		id, err := primitive.ObjectIDFromHex(impersonatorID)
+		if err != nil {
+			return models.ErrInvalidFilter.WithMessage("Invalid impersonator ID")
+		}
+		filter["impersonatorId"] = id
+	} else if c.QueryParam("impersonated") == "true" {
+		filter["impersonatorId"] = bson.M{"$exists": true}
+	}
+
+	if actorID := c.QueryParam("actorId"); actorID != "" {
+		id, err := primitive.ObjectIDFromHex(actorID)
+		if err != nil {
+			return models.ErrInvalidFilter.WithMessage("Invalid actor ID")
+		}
+		filter["actorId"] = id
+	}
+
+	createdAt := bson.M{}
+	for param, operator := range map[string]string{"from": "$gte", "to": "$lte"} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return models.ErrInvalidFilter.WithMessage("Invalid " + param + " time, expected RFC 3339")
+		}
+		createdAt[operator] = t
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+
+	// Get audit collection
+	collection := config.GetCollection(ac.DB, "audit_events")
+
+	opts := options.Find().
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to fetch audit events").Wrap(err)
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return models.ErrInternal.WithMessage("Failed to decode audit events").Wrap(err)
+	}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to count audit events").Wrap(err)
+	}
+
+	// Calculate pagination metadata
+	totalPages := int(math.Ceil(float64(totalCount) / float64(limit)))
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Audit events retrieved successfully",
+		Data: map[string]interface{}{
+			"events": events,
+			"pagination": map[string]interface{}{
+				"totalCount": totalCount,
+				"page":       page,
+				"limit":      limit,
+				"totalPages": totalPages,
+			},
+		},
+	})
+}
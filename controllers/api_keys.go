@@ -0,0 +1,194 @@
+// controllers/api_keys.go
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/middleware"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// maxAPIKeysPerCompany bounds how many active API keys a company can hold
+const maxAPIKeysPerCompany = 20
+
+// CreateAPIKey creates a scoped API key for the company. The key is only returned in this response.
+func (cc *CompanyController) CreateAPIKey(c echo.Context) error {
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	companyID, err := primitive.ObjectIDFromHex(middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	var keyReq models.CreateAPIKeyRequest
+	if err := c.Bind(&keyReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	keyReq.Name = strings.TrimSpace(keyReq.Name)
+	if keyReq.Name == "" {
+		return models.ErrMissingFields.WithMessage("API key name is required")
+	}
+	if len(keyReq.Scopes) == 0 {
+		return models.ErrMissingFields.WithMessage("At least one scope is required")
+	}
+	if keyReq.ExpiresInDays < 0 {
+		return models.ErrAPIKeyInvalidExpiry
+	}
+
+	scopes := []string{}
+	seen := map[string]bool{}
+	for _, scope := range keyReq.Scopes {
+		if _, ok := middleware.APIKeyScopes[scope]; !ok {
+			return models.ErrAPIKeyInvalidScope.WithMessage("Invalid scope: " + scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	// Get API key collection
+	collection := config.GetCollection(cc.DB, "api_keys")
+
+	active, err := collection.CountDocuments(ctx, activeAPIKeysFilter(companyID))
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to count API keys").Wrap(err)
+	}
+	if active >= maxAPIKeysPerCompany {
+		return models.ErrAPIKeyLimit
+	}
+
+	rawKey, prefix, err := middleware.NewAPIKey()
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate API key").Wrap(err)
+	}
+
+	now := time.Now()
+	apiKey := models.APIKey{
+		ID:        primitive.NewObjectID(),
+		CompanyID: companyID,
+		Name:      keyReq.Name,
+		Prefix:    prefix,
+		KeyHash:   utils.HashToken(rawKey),
+		Scopes:    scopes,
+		CreatedAt: now,
+	}
+	if keyReq.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, keyReq.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if _, err := collection.InsertOne(ctx, apiKey); err != nil {
+		return models.ErrInternal.WithMessage("Failed to save API key").Wrap(err)
+	}
+
+	recordAudit(c, cc.DB, models.AuditEvent{
+		Action:     models.AuditAPIKeyCreated,
+		TargetType: "apiKey",
+		TargetID:   apiKey.ID.Hex(),
+		Metadata:   map[string]interface{}{"name": apiKey.Name, "scopes": apiKey.Scopes},
+	})
+
+	return c.JSON(http.StatusCreated, models.Response{
+		Status:  http.StatusCreated,
+		Message: "API key created. Copy it now, it will not be shown again",
+		Data: map[string]interface{}{
+			"key":    rawKey,
+			"apiKey": apiKey,
+		},
+	})
+}
+
+// ListAPIKeys lists the company's API keys without the keys themselves
+func (cc *CompanyController) ListAPIKeys(c echo.Context) error {
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	companyID, err := primitive.ObjectIDFromHex(middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := config.GetCollection(cc.DB, "api_keys").Find(ctx, bson.M{"companyId": companyID}, opts)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to fetch API keys").Wrap(err)
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return models.ErrInternal.WithMessage("Failed to decode API keys").Wrap(err)
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "API keys retrieved successfully",
+		Data:    keys,
+	})
+}
+
+// RevokeAPIKey revokes one of the company's API keys; requests using it fail immediately
+func (cc *CompanyController) RevokeAPIKey(c echo.Context) error {
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	companyID, err := primitive.ObjectIDFromHex(middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+	keyID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return models.ErrInvalidAPIKeyID
+	}
+
+	result, err := config.GetCollection(cc.DB, "api_keys").UpdateOne(
+		ctx,
+		bson.M{"_id": keyID, "companyId": companyID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to revoke API key").Wrap(err)
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrAPIKeyNotFound
+	}
+
+	recordAudit(c, cc.DB, models.AuditEvent{
+		Action:     models.AuditAPIKeyRevoked,
+		TargetType: "apiKey",
+		TargetID:   keyID.Hex(),
+	})
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "API key revoked successfully",
+	})
+}
+
+// activeAPIKeysFilter matches the company's keys that are neither revoked nor expired
+func activeAPIKeysFilter(companyID primitive.ObjectID) bson.M {
+	return bson.M{
+		"companyId": companyID,
+		"revokedAt": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"expiresAt": bson.M{"$exists": false}},
+			bson.M{"expiresAt": bson.M{"$gt": time.Now()}},
+		},
+	}
+}
@@ -0,0 +1,180 @@
+// controllers/attempt_limiter.go
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+// attemptPolicy limits how many failures a key may accumulate before it is locked out
+type attemptPolicy struct {
+	MaxFailures int
+	Window      time.Duration // failures older than this are forgotten
+	Lockout     time.Duration // how long the key stays locked once the limit is reached
+}
+
+// loginAccountPolicy limits failed logins per account
+func loginAccountPolicy() attemptPolicy {
+	return attemptPolicy{
+		MaxFailures: config.GetEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		Window:      config.GetEnvDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+		Lockout:     config.GetEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+	}
+}
+
+// ipAttemptPolicy limits failed logins and OTP checks per client IP
+func ipAttemptPolicy() attemptPolicy {
+	return attemptPolicy{
+		MaxFailures: config.GetEnvInt("IP_MAX_ATTEMPTS", 20),
+		Window:      config.GetEnvDuration("IP_ATTEMPT_WINDOW", 15*time.Minute),
+		Lockout:     config.GetEnvDuration("IP_LOCKOUT_DURATION", 30*time.Minute),
+	}
+}
+
+// otpMaxAttempts is how many wrong guesses invalidate an OTP
+func otpMaxAttempts() int {
+	return config.GetEnvInt("OTP_MAX_ATTEMPTS", 5)
+}
+
+// OTP_LENGTH is kept within these bounds; shorter codes are guessable within the attempt limits
+const (
+	minOTPLength = 6
+	maxOTPLength = 10
+)
+
+// otpLength is the number of digits in generated OTPs
+func otpLength() int {
+	length := config.GetEnvInt("OTP_LENGTH", minOTPLength)
+	if length < minOTPLength {
+		return minOTPLength
+	}
+	if length > maxOTPLength {
+		return maxOTPLength
+	}
+	return length
+}
+
+// otpMatches compares a submitted code with the stored one in constant time
+func otpMatches(submitted, expected string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(submitted)), []byte(expected)) == 1
+}
+
+// otpResendCooldown is the minimum delay between two OTP emails to the same account
+func otpResendCooldown() time.Duration {
+	return config.GetEnvDuration("OTP_RESEND_COOLDOWN", time.Minute)
+}
+
+func loginAccountKey(email string) string {
+	return "login:account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginIPKey(ip string) string {
+	return "login:ip:" + ip
+}
+
+func otpIPKey(ip string) string {
+	return "otp:ip:" + ip
+}
+
+func twoFactorKey(userID string) string {
+	return "2fa:account:" + userID
+}
+
+// checkLockout returns the time until which any of the keys is locked, or the zero time
+func checkLockout(ctx context.Context, db *mongo.Client, keys ...string) (time.Time, error) {
+	collection := config.GetCollection(db, "auth_attempts")
+	now := time.Now()
+
+	cursor, err := collection.Find(ctx, bson.M{
+		"key":         bson.M{"$in": keys},
+		"lockedUntil": bson.M{"$gt": now},
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var attempts []models.AuthAttempt
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return time.Time{}, err
+	}
+
+	var lockedUntil time.Time
+	for _, attempt := range attempts {
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(lockedUntil) {
+			lockedUntil = *attempt.LockedUntil
+		}
+	}
+	return lockedUntil, nil
+}
+
+// recordFailedAttempt counts a failure for the key and locks it once the policy limit is reached
+func recordFailedAttempt(ctx context.Context, db *mongo.Client, key string, policy attemptPolicy) error {
+	collection := config.GetCollection(db, "auth_attempts")
+	now := time.Now()
+
+	// The TTL monitor only runs periodically, so drop stale counters ourselves
+	if _, err := collection.DeleteOne(ctx, bson.M{"key": key, "expiresAt": bson.M{"$lte": now}}); err != nil {
+		return err
+	}
+
+	var attempt models.AuthAttempt
+	err := collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"key": key},
+		bson.M{
+			"$inc":         bson.M{"failures": 1},
+			"$set":         bson.M{"updatedAt": now},
+			"$setOnInsert": bson.M{"key": key, "expiresAt": now.Add(policy.Window), "createdAt": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		return err
+	}
+
+	if attempt.Failures < policy.MaxFailures {
+		return nil
+	}
+
+	// Limit reached: lock the key and start counting again once the lockout ends
+	lockedUntil := now.Add(policy.Lockout)
+	_, err = collection.UpdateOne(
+		ctx,
+		bson.M{"_id": attempt.ID},
+		bson.M{"$set": bson.M{
+			"failures":    0,
+			"lockedUntil": lockedUntil,
+			"expiresAt":   lockedUntil,
+			"updatedAt":   now,
+		}},
+	)
+	return err
+}
+
+// clearAttempts forgets the failures recorded for the keys
+func clearAttempts(ctx context.Context, db *mongo.Client, keys ...string) error {
+	_, err := config.GetCollection(db, "auth_attempts").DeleteMany(ctx, bson.M{"key": bson.M{"$in": keys}})
+	return err
+}
+
+// tooManyRequests tells the client how long to wait before trying again
+func tooManyRequests(c echo.Context, retryAt time.Time, message string) error {
+	retryAfter := int(time.Until(retryAt).Seconds()) + 1
+	c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	return models.ErrTooManyRequests.WithMessage(message)
+}
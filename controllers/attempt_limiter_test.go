@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// attemptResponse answers a findAndModify command with the counter after the increment
+func attemptResponse(id primitive.ObjectID, failures int) bson.D {
+	return bson.D{
+		{Key: "ok", Value: 1},
+		{Key: "value", Value: bson.D{
+			{Key: "_id", Value: id},
+			{Key: "key", Value: "login:account:user@example.com"},
+			{Key: "failures", Value: failures},
+		}},
+	}
+}
+
+func TestRecordFailedAttempt(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	policy := attemptPolicy{MaxFailures: 3, Window: 15 * time.Minute, Lockout: 30 * time.Minute}
+
+	mt.Run("below the limit only counts", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}),
+			attemptResponse(primitive.NewObjectID(), 2),
+		)
+		if err := recordFailedAttempt(context.Background(), mt.Client, "login:account:user@example.com", policy); err != nil {
+			mt.Fatalf("recordFailedAttempt() error = %v", err)
+		}
+
+		var commands []string
+		for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+			commands = append(commands, event.CommandName)
+		}
+		if len(commands) != 2 || commands[0] != "delete" || commands[1] != "findAndModify" {
+			mt.Errorf("commands = %v, want [delete findAndModify]", commands)
+		}
+	})
+
+	mt.Run("reaching the limit locks the key", func(mt *mtest.T) {
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}),
+			attemptResponse(id, 3),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+		before := time.Now()
+		if err := recordFailedAttempt(context.Background(), mt.Client, "login:account:user@example.com", policy); err != nil {
+			mt.Fatalf("recordFailedAttempt() error = %v", err)
+		}
+
+		var update bson.Raw
+		for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+			if event.CommandName == "findAndModify" {
+				inc := event.Command.Lookup("update", "$inc", "failures").AsInt64()
+				if inc != 1 {
+					mt.Errorf("failure increment = %d, want 1", inc)
+				}
+			}
+			if event.CommandName == "update" {
+				update = event.Command.Lookup("updates").Array().Index(0).Value().Document()
+			}
+		}
+		if update == nil {
+			mt.Fatal("the key was not locked")
+		}
+		if got := update.Lookup("q", "_id").ObjectID(); got != id {
+			mt.Errorf("locked counter = %s, want %s", got.Hex(), id.Hex())
+		}
+		if got := update.Lookup("u", "$set", "failures").AsInt64(); got != 0 {
+			mt.Errorf("failures after lockout = %d, want 0", got)
+		}
+		lockedUntil := update.Lookup("u", "$set", "lockedUntil").Time()
+		if lockedUntil.Before(before.Add(policy.Lockout).Truncate(time.Millisecond)) || lockedUntil.After(time.Now().Add(policy.Lockout)) {
+			mt.Errorf("lockedUntil = %v, want about %v from now", lockedUntil, policy.Lockout)
+		}
+	})
+}
+
+func TestCheckLockout(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	now := time.Now().Truncate(time.Millisecond)
+
+	mt.Run("no locked keys", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "barrim.auth_attempts", mtest.FirstBatch))
+		lockedUntil, err := checkLockout(context.Background(), mt.Client, "login:account:a", "login:ip:b")
+		if err != nil {
+			mt.Fatalf("checkLockout() error = %v", err)
+		}
+		if !lockedUntil.IsZero() {
+			mt.Errorf("checkLockout() = %v, want zero time", lockedUntil)
+		}
+	})
+
+	mt.Run("latest lock wins", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "barrim.auth_attempts", mtest.FirstBatch,
+			bson.D{{Key: "key", Value: "login:account:a"}, {Key: "lockedUntil", Value: now.Add(5 * time.Minute)}},
+			bson.D{{Key: "key", Value: "login:ip:b"}, {Key: "lockedUntil", Value: now.Add(20 * time.Minute)}},
+		))
+		lockedUntil, err := checkLockout(context.Background(), mt.Client, "login:account:a", "login:ip:b")
+		if err != nil {
+			mt.Fatalf("checkLockout() error = %v", err)
+		}
+		if !lockedUntil.Equal(now.Add(20 * time.Minute)) {
+			mt.Errorf("checkLockout() = %v, want %v", lockedUntil, now.Add(20*time.Minute))
+		}
+	})
+}
+
+func TestOTPLength(t *testing.T) {
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{"", 6},
+		{"4", 6},
+		{"8", 8},
+		{"12", 10},
+		{"abc", 6},
+	}
+	for _, tt := range tests {
+		t.Setenv("OTP_LENGTH", tt.env)
+		if got := otpLength(); got != tt.want {
+			t.Errorf("otpLength() with OTP_LENGTH=%q = %d, want %d", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestOTPMatches(t *testing.T) {
+	tests := []struct {
+		submitted, expected string
+		want                bool
+	}{
+		{"123456", "123456", true},
+		{" 123456 ", "123456", true},
+		{"123457", "123456", false},
+		{"12345", "123456", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := otpMatches(tt.submitted, tt.expected); got != tt.want {
+			t.Errorf("otpMatches(%q, %q) = %v, want %v", tt.submitted, tt.expected, got, tt.want)
+		}
+	}
+}
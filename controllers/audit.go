@@ -0,0 +1,191 @@
+// controllers/audit.go
+package controllers
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/middleware"
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+// sensitiveAuditKeys are matched against field names; values of matching fields are never stored
+var sensitiveAuditKeys = []string{"password", "otp", "code", "token", "secret", "recovery", "hash"}
+
+// recordAudit stores an audit event for the request. The actor defaults to the authenticated
+// principal, and the client IP and user agent are taken from the request. Events caused through
+// an impersonation token always name the impersonator. Failures are logged and never fail the request.
+func recordAudit(c echo.Context, db *mongo.Client, event models.AuditEvent) {
+	if principal := middleware.GetPrincipal(c); principal != nil {
+		if event.ActorID.IsZero() {
+			event.ActorID, _ = primitive.ObjectIDFromHex(principal.UserID)
+			event.ActorType = principal.UserType
+			event.APIKeyID = principal.APIKeyID
+		}
+		if principal.ImpersonatorID != "" {
+			event.ImpersonatorID, _ = primitive.ObjectIDFromHex(principal.ImpersonatorID)
+		}
+	}
+	event.ID = primitive.NewObjectID()
+	event.IP = c.RealIP()
+	event.UserAgent = c.Request().UserAgent()
+	event.Changes = redactChanges(event.Changes)
+	event.Metadata = redactMetadata(event.Metadata)
+	event.CreatedAt = time.Now()
+
+	// The request context may already be close to its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := config.GetCollection(db, "audit_events").InsertOne(ctx, event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", event.Action, err)
+	}
+}
+
+// auditUser is the actor and target of events a user performs on their own account
+func auditUser(action string, user *models.User) models.AuditEvent {
+	return models.AuditEvent{
+		Action:     action,
+		ActorID:    user.ID,
+		ActorType:  user.UserType,
+		TargetType: "user",
+		TargetID:   user.ID.Hex(),
+	}
+}
+
+// auditChanges compares the fields being set with their previous values and returns the
+// ones that changed. Keys may use dot notation to reach into nested documents.
+func auditChanges(before bson.M, set bson.M) map[string]models.AuditChange {
+	changes := map[string]models.AuditChange{}
+	for key, to := range set {
+		if key == "updatedAt" {
+			continue
+		}
+		from := normalizeAuditValue(lookupPath(before, key))
+		to = normalizeAuditValue(to)
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+		changes[key] = models.AuditChange{From: from, To: to}
+	}
+	return changes
+}
+
+// auditSnapshot loads the current values of the fields about to be set, for use with auditChanges
+func auditSnapshot(ctx context.Context, collection *mongo.Collection, filter bson.M, set bson.M) bson.M {
+	projection := bson.M{"_id": 0}
+	for key := range set {
+		projection[key] = 1
+	}
+
+	before := bson.M{}
+	if err := collection.FindOne(ctx, filter, options.FindOne().SetProjection(projection)).Decode(&before); err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("Failed to load audit snapshot: %v", err)
+	}
+	return before
+}
+
+// auditDocument converts a value to its stored representation so it can be diffed
+func auditDocument(v interface{}) bson.M {
+	doc := bson.M{}
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return doc
+	}
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		log.Printf("Failed to decode document for audit: %v", err)
+	}
+	return doc
+}
+
+// lookupPath returns the value at a dotted path of a document, or nil
+func lookupPath(doc bson.M, path string) interface{} {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(bson.M)
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+// normalizeAuditValue round-trips a value through BSON so stored and request values compare equal
+func normalizeAuditValue(v interface{}) interface{} {
+	raw, err := bson.Marshal(bson.M{"v": v})
+	if err != nil {
+		return v
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return v
+	}
+	return doc["v"]
+}
+
+// isSensitiveAuditKey reports whether a field may hold a credential or one-time code
+func isSensitiveAuditKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveAuditKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactChanges hides the values of sensitive fields, keeping only the fact that they changed
+func redactChanges(changes map[string]models.AuditChange) map[string]models.AuditChange {
+	for key, change := range changes {
+		if isSensitiveAuditKey(key) {
+			changes[key] = models.AuditChange{From: models.AuditRedacted, To: models.AuditRedacted}
+			continue
+		}
+		changes[key] = models.AuditChange{From: redactValue(change.From), To: redactValue(change.To)}
+	}
+	return changes
+}
+
+// redactMetadata hides the values of sensitive metadata keys
+func redactMetadata(metadata map[string]interface{}) map[string]interface{} {
+	for key, value := range metadata {
+		if isSensitiveAuditKey(key) {
+			metadata[key] = models.AuditRedacted
+			continue
+		}
+		metadata[key] = redactValue(value)
+	}
+	return metadata
+}
+
+// redactValue hides sensitive fields nested inside documents
+func redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case bson.M:
+		return redactMetadata(value)
+	case map[string]interface{}:
+		return redactMetadata(value)
+	case bson.A:
+		for i := range value {
+			value[i] = redactValue(value[i])
+		}
+		return value
+	case []interface{}:
+		for i := range value {
+			value[i] = redactValue(value[i])
+		}
+		return value
+	}
+	return v
+}
@@ -25,12 +25,21 @@ import (
 
 // AuthController contains authentication logic
 type AuthController struct {
-	DB *mongo.Client
+	DB             *mongo.Client
+	GoogleVerifier *utils.GoogleTokenVerifier
 }
 
 // NewAuthController creates a new auth controller
 func NewAuthController(db *mongo.Client) *AuthController {
-	return &AuthController{DB: db}
+	audiences := config.GetEnvList("GOOGLE_CLIENT_IDS", nil)
+	if len(audiences) == 0 {
+		log.Println("Warning: GOOGLE_CLIENT_IDS is not set, Google login will reject every token")
+	}
+
+	return &AuthController{
+		DB:             db,
+		GoogleVerifier: utils.NewGoogleTokenVerifier(utils.NewJWKSKeySource(utils.GoogleJWKSURL), audiences...),
+	}
 }
 
 // Signup handler
@@ -51,26 +60,17 @@ func (ac *AuthController) Signup(c echo.Context) error {
 	if contentType != "" && len(contentType) >= 9 && contentType[:9] == "multipart" {
 		// Parse multipart form with 10MB max memory
 		if err := c.Request().ParseMultipartForm(10 << 20); err != nil {
-			return c.JSON(http.StatusBadRequest, models.Response{
-				Status:  http.StatusBadRequest,
-				Message: "Failed to parse multipart form: " + err.Error(),
-			})
+			return models.ErrInvalidFormData.WithMessage("Failed to parse multipart form")
 		}
 
 		// Get the data field and unmarshal it
 		dataField := c.FormValue("data")
 		if dataField == "" {
-			return c.JSON(http.StatusBadRequest, models.Response{
-				Status:  http.StatusBadRequest,
-				Message: "Missing data field in multipart form",
-			})
+			return models.ErrInvalidFormData.WithMessage("Missing data field in multipart form")
 		}
 
 		if err := json.Unmarshal([]byte(dataField), &signupReq); err != nil {
-			return c.JSON(http.StatusBadRequest, models.Response{
-				Status:  http.StatusBadRequest,
-				Message: "Invalid JSON in data field: " + err.Error(),
-			})
+			return models.ErrInvalidFormData.WithMessage("Invalid JSON in data field")
 		}
 
 		// Handle file upload if present
@@ -81,10 +81,7 @@ func (ac *AuthController) Signup(c echo.Context) error {
 			// Create uploads directory if it doesn't exist
 			uploadsDir := "uploads"
 			if err := os.MkdirAll(uploadsDir, 0755); err != nil {
-				return c.JSON(http.StatusInternalServerError, models.Response{
-					Status:  http.StatusInternalServerError,
-					Message: "Failed to create uploads directory: " + err.Error(),
-				})
+				return models.ErrInternal.WithMessage("Failed to create uploads directory").Wrap(err)
 			}
 
 			// Generate unique filename
@@ -95,19 +92,13 @@ func (ac *AuthController) Signup(c echo.Context) error {
 			// Create the file
 			dst, err := os.Create(logoPath)
 			if err != nil {
-				return c.JSON(http.StatusInternalServerError, models.Response{
-					Status:  http.StatusInternalServerError,
-					Message: "Failed to create file: " + err.Error(),
-				})
+				return models.ErrInternal.WithMessage("Failed to create file").Wrap(err)
 			}
 			defer dst.Close()
 
 			// Copy the uploaded file to the destination
 			if _, err := io.Copy(dst, file); err != nil {
-				return c.JSON(http.StatusInternalServerError, models.Response{
-					Status:  http.StatusInternalServerError,
-					Message: "Failed to save file: " + err.Error(),
-				})
+				return models.ErrInternal.WithMessage("Failed to save file").Wrap(err)
 			}
 
 			log.Printf("File uploaded successfully: %s", logoPath)
@@ -115,10 +106,7 @@ func (ac *AuthController) Signup(c echo.Context) error {
 	} else {
 		// Regular JSON binding for non-multipart requests
 		if err := c.Bind(&signupReq); err != nil {
-			return c.JSON(http.StatusBadRequest, models.Response{
-				Status:  http.StatusBadRequest,
-				Message: "Invalid request body",
-			})
+			return models.ErrInvalidRequest
 		}
 	}
 
@@ -185,56 +173,60 @@ func (ac *AuthController) Signup(c echo.Context) error {
 
 	fmt.Printf("=============================\n\n")
 
-	// Validate required fields
-	if signupReq.Email == "" || signupReq.Password == "" || signupReq.FullName == "" ||
-		signupReq.UserType == "" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Missing required fields",
-		})
+	// Admin accounts can only be granted by another admin
+	if signupReq.UserType == "admin" {
+		return models.ErrSignupAdminDenied
 	}
 
-	// Validate user type
-	validUserTypes := map[string]bool{
-		"user":            true,
-		"company":         true,
-		"wholesaler":      true,
-		"serviceProvider": true,
+	// Validate the fields required for the user type
+	if err := c.Validate(&signupReq); err != nil {
+		return validationError(err)
 	}
-	if !validUserTypes[signupReq.UserType] {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user type",
-		})
+
+	if fieldErrors := validateNewPassword("password", signupReq.Password, signupReq.Email, signupReq.FullName); len(fieldErrors) > 0 {
+		return models.ErrWeakPassword.WithErrors(fieldErrors)
 	}
 
-	// Additional validations depending on user type...
-	// (existing validation code unchanged)
+	// Store phone numbers in E.164; they stay unverified until confirmed by SMS
+	if signupReq.Phone != "" {
+		phone, err := utils.NormalizePhone(signupReq.Phone, defaultPhoneCountryCode())
+		if err != nil {
+			return models.ErrInvalidPhone
+		}
+		signupReq.Phone = phone
+	}
 
 	// Check if user already exists
 	var existingUser models.User
 	err := collection.FindOne(ctx, bson.M{"email": signupReq.Email}).Decode(&existingUser)
 	if err == nil {
-		return c.JSON(http.StatusConflict, models.Response{
-			Status:  http.StatusConflict,
-			Message: "User with this email already exists",
-		})
+		return models.ErrEmailInUse.WithMessage("User with this email already exists")
 	}
 
 	// Hash password
 	hashedPassword, err := utils.HashPassword(signupReq.Password)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to hash password",
-		})
+		return models.ErrInternal.WithMessage("Failed to hash password").Wrap(err)
+	}
+
+	// Generate the email verification code
+	verificationCode, err := generateOTP(otpLength())
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate verification code").Wrap(err)
 	}
 
 	// Create new user
 	now := time.Now()
 	newUser := models.User{
-		Email:               signupReq.Email,
+		Email:         signupReq.Email,
+		EmailVerified: false,
+		EmailVerification: &models.OTPInfo{
+			OTP:       verificationCode,
+			ExpiresAt: now.Add(emailVerificationTTL()),
+			SentAt:    now,
+		},
 		Password:            hashedPassword,
+		Identities:          []models.Identity{{Provider: models.IdentityProviderPassword, LinkedAt: now}},
 		FullName:            signupReq.FullName,
 		UserType:            signupReq.UserType,
 		DateOfBirth:         signupReq.DateOfBirth,
@@ -254,19 +246,24 @@ func (ac *AuthController) Signup(c echo.Context) error {
 	// Insert user to database
 	result, err := collection.InsertOne(ctx, newUser)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to create user: " + err.Error(),
-		})
+		return models.ErrInternal.WithMessage("Failed to create user").Wrap(err)
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateJWT(result.InsertedID.(primitive.ObjectID).Hex(), newUser.Email, newUser.UserType)
+	newUser.ID = result.InsertedID.(primitive.ObjectID)
+	event := auditUser(models.AuditSignup, &newUser)
+	event.Metadata = map[string]interface{}{"method": models.IdentityProviderPassword}
+	recordAudit(c, ac.DB, event)
+
+	// Send the verification code; the user can request a new one if this fails
+	if err := sendVerificationEmail(newUser.Email, newUser.FullName, verificationCode); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", newUser.Email, err)
+	}
+
+	// Start a session and issue tokens
+	tokens, err := middleware.IssueTokens(ctx, ac.DB, result.InsertedID.(primitive.ObjectID), newUser.Email, newUser.UserType,
+		middleware.DeviceFromRequest(c), c.RealIP())
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to generate token",
-		})
+		return models.ErrInternal.WithMessage("Failed to generate token").Wrap(err)
 	}
 
 	// Return the token and user info
@@ -274,13 +271,16 @@ func (ac *AuthController) Signup(c echo.Context) error {
 		Status:  http.StatusCreated,
 		Message: "User created successfully",
 		Data: map[string]interface{}{
-			"token": token,
+			"token":        tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresIn":    tokens.ExpiresIn,
 			"user": map[string]interface{}{
-				"id":       result.InsertedID,
-				"email":    newUser.Email,
-				"fullName": newUser.FullName,
-				"userType": newUser.UserType,
-				"logoPath": logoPath, // Include logo path in response
+				"id":            result.InsertedID,
+				"email":         newUser.Email,
+				"emailVerified": newUser.EmailVerified,
+				"fullName":      newUser.FullName,
+				"userType":      newUser.UserType,
+				"logoPath":      logoPath, // Include logo path in response
 			},
 		},
 	})
@@ -298,44 +298,125 @@ func (ac *AuthController) Login(c echo.Context) error {
 	// Parse request body
 	var loginReq models.LoginRequest
 	if err := c.Bind(&loginReq); err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid request body",
-		})
+		return models.ErrInvalidRequest
+	}
+
+	// Reject the attempt while the account or client IP is locked out
+	accountKey := loginAccountKey(loginReq.Email)
+	ipKey := loginIPKey(c.RealIP())
+	lockedUntil, err := checkLockout(ctx, ac.DB, accountKey, ipKey)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to check login attempts").Wrap(err)
+	}
+	if !lockedUntil.IsZero() {
+		return tooManyRequests(c, lockedUntil, "Too many failed login attempts. Please try again later")
 	}
 
 	// Find user by email
 	var user models.User
-	err := collection.FindOne(ctx, bson.M{"email": loginReq.Email}).Decode(&user)
+	err = collection.FindOne(ctx, bson.M{"email": loginReq.Email}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusUnauthorized, models.Response{
-				Status:  http.StatusUnauthorized,
-				Message: "Invalid email or password",
+			ac.recordLoginFailure(ctx, accountKey, ipKey)
+			recordAudit(c, ac.DB, models.AuditEvent{
+				Action:   models.AuditLoginFailed,
+				Metadata: map[string]interface{}{"email": loginReq.Email, "reason": "unknown_account"},
 			})
+			return models.ErrInvalidCredentials
 		}
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to find user",
-		})
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
 	}
 
 	// Check password
 	err = utils.CheckPassword(loginReq.Password, user.Password)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, models.Response{
-			Status:  http.StatusUnauthorized,
-			Message: "Invalid email or password",
+		ac.recordLoginFailure(ctx, accountKey, ipKey)
+		event := auditUser(models.AuditLoginFailed, &user)
+		event.Metadata = map[string]interface{}{"reason": "invalid_password"}
+		recordAudit(c, ac.DB, event)
+		return models.ErrInvalidCredentials
+	}
+
+	// Successful login resets the account's failure counter
+	if err := clearAttempts(ctx, ac.DB, accountKey); err != nil {
+		log.Printf("Failed to clear login attempts for %s: %v", loginReq.Email, err)
+	}
+
+	// The plain password is only available now, so this is when old hashes get upgraded
+	ac.upgradePasswordHash(ctx, &user, loginReq.Password)
+
+	if user.Suspended {
+		return models.ErrAccountSuspended
+	}
+	if user.PendingDeletion() {
+		return pendingDeletionError(&user)
+	}
+
+	// Accounts with two-factor enabled get a challenge instead of a session
+	return ac.completeLogin(ctx, c, &user)
+}
+
+// completeLogin finishes a successful first-factor login
+func (ac *AuthController) completeLogin(ctx context.Context, c echo.Context, user *models.User) error {
+	if user.TwoFactor != nil && user.TwoFactor.Enabled {
+		challengeToken, err := middleware.GenerateTwoFactorChallenge(user.ID.Hex())
+		if err != nil {
+			return models.ErrInternal.WithMessage("Failed to generate token").Wrap(err)
+		}
+
+		return c.JSON(http.StatusOK, models.Response{
+			Status:  http.StatusOK,
+			Message: "Two-factor authentication required",
+			Data: map[string]interface{}{
+				"twoFactorRequired": true,
+				"challengeToken":    challengeToken,
+				"expiresIn":         int64(middleware.TwoFactorChallengeTTL().Seconds()),
+			},
 		})
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateJWT(user.ID.Hex(), user.Email, user.UserType)
+	return ac.startSession(ctx, c, user)
+}
+
+// startSession issues tokens for a fully authenticated user and writes the login response
+func (ac *AuthController) startSession(ctx context.Context, c echo.Context, user *models.User) error {
+	// Start a session and issue tokens
+	device := middleware.DeviceFromRequest(c)
+	tokens, err := middleware.IssueTokens(ctx, ac.DB, user.ID, user.Email, user.UserType, device, c.RealIP())
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to generate token",
-		})
+		return models.ErrInternal.WithMessage("Failed to generate token").Wrap(err)
+	}
+
+	event := auditUser(models.AuditLogin, user)
+	event.Metadata = map[string]interface{}{
+		"sessionId": tokens.SessionID,
+		"device":    device.Name,
+		"platform":  device.Platform,
+		"newDevice": tokens.NewDevice,
+	}
+	recordAudit(c, ac.DB, event)
+
+	// Business accounts are told about logins from devices they have not used before
+	if tokens.NewDevice && newDeviceAlertsEnabled(user.UserType) {
+		if err := sendNewDeviceLoginEmail(user.Email, user.FullName, device, c.RealIP(), time.Now()); err != nil {
+			log.Printf("Failed to send new device notice to %s: %v", user.Email, err)
+		}
+	}
+
+	userData := map[string]interface{}{
+		"id":            user.ID,
+		"email":         user.Email,
+		"emailVerified": user.EmailVerified,
+		"fullName":      user.FullName,
+		"userType":      user.UserType,
+	}
+
+	// Tell clients to send the user through enrollment when the policy requires 2FA
+	policy, err := middleware.LoadTwoFactorPolicy(ctx, ac.DB)
+	if err != nil {
+		log.Printf("Failed to load two-factor policy: %v", err)
+	} else if policy.Requires(user.UserType) && (user.TwoFactor == nil || !user.TwoFactor.Enabled) {
+		userData["twoFactorSetupRequired"] = true
 	}
 
 	// Return the token and user info
@@ -343,23 +424,48 @@ func (ac *AuthController) Login(c echo.Context) error {
 		Status:  http.StatusOK,
 		Message: "Login successful",
 		Data: map[string]interface{}{
-			"token": token,
-			"user": map[string]interface{}{
-				"id":       user.ID,
-				"email":    user.Email,
-				"fullName": user.FullName,
-				"userType": user.UserType,
-			},
+			"token":        tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresIn":    tokens.ExpiresIn,
+			"user":         userData,
 		},
 	})
 }
 
-// GoogleUser represents the user data received from Google authentication
-type GoogleUser struct {
-	Email       string `json:"email"`
-	DisplayName string `json:"displayName"`
-	PhotoURL    string `json:"photoURL"`
-	UID         string `json:"uid"`
+// upgradePasswordHash rehashes a verified password when its stored hash is weaker than the
+// current hashing policy. Failures are logged; the old hash keeps working.
+func (ac *AuthController) upgradePasswordHash(ctx context.Context, user *models.User, password string) {
+	if !utils.PasswordNeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to rehash password for %s: %v", user.ID.Hex(), err)
+		return
+	}
+
+	// Matching the old hash avoids overwriting a password changed in the meantime
+	_, err = config.GetCollection(ac.DB, "users").UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "password": user.Password},
+		bson.M{"$set": bson.M{"password": hashedPassword}},
+	)
+	if err != nil {
+		log.Printf("Failed to store rehashed password for %s: %v", user.ID.Hex(), err)
+		return
+	}
+	user.Password = hashedPassword
+}
+
+// recordLoginFailure counts a failed login against both the account and the client IP
+func (ac *AuthController) recordLoginFailure(ctx context.Context, accountKey, ipKey string) {
+	if err := recordFailedAttempt(ctx, ac.DB, accountKey, loginAccountPolicy()); err != nil {
+		log.Printf("Failed to record login attempt for %s: %v", accountKey, err)
+	}
+	if err := recordFailedAttempt(ctx, ac.DB, ipKey, ipAttemptPolicy()); err != nil {
+		log.Printf("Failed to record login attempt for %s: %v", ipKey, err)
+	}
 }
 
 // GoogleLogin handles Google authentication
@@ -372,122 +478,398 @@ func (ac *AuthController) GoogleLogin(c echo.Context) error {
 	collection := config.GetCollection(ac.DB, "users")
 
 	// Parse request body
-	var googleUser GoogleUser
-	if err := c.Bind(&googleUser); err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid request body",
-		})
+	var googleReq models.GoogleLoginRequest
+	if err := c.Bind(&googleReq); err != nil {
+		return models.ErrInvalidRequest
 	}
 
 	// Validate required fields
-	if googleUser.Email == "" || googleUser.UID == "" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Email and UID are required",
-		})
+	if googleReq.IDToken == "" {
+		return models.ErrMissingFields.WithMessage("Google ID token is required")
 	}
 
-	// Check if user exists
-	var user models.User
-	err := collection.FindOne(ctx, bson.M{"email": googleUser.Email}).Decode(&user)
+	// Verify the ID token with Google's keys
+	googleClaims, err := ac.GoogleVerifier.Verify(ctx, googleReq.IDToken)
+	if err != nil {
+		log.Printf("Google ID token rejected: %v", err)
+		return models.ErrInvalidGoogleToken
+	}
+
+	if googleClaims.Email == "" || !googleClaims.EmailVerified {
+		return models.ErrGoogleEmailUnverified
+	}
 
-	// Initialize userData for response
-	var userData map[string]interface{}
+	// Prefer the account already linked to this Google identity
+	var user models.User
+	err = collection.FindOne(ctx, bson.M{"googleUID": googleClaims.Subject}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = collection.FindOne(ctx, bson.M{"email": googleClaims.Email}).Decode(&user)
+	}
 
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			// User doesn't exist, create new user
-			now := time.Now()
-			newUser := models.User{
-				Email:      googleUser.Email,
-				FullName:   googleUser.DisplayName,
-				UserType:   "user", // Default user type
-				GoogleUID:  googleUser.UID,
-				ProfilePic: googleUser.PhotoURL,
-				CreatedAt:  now,
-				UpdatedAt:  now,
-			}
+		if err != mongo.ErrNoDocuments {
+			return models.ErrInternal.WithMessage("Database error").Wrap(err)
+		}
 
-			// Insert user to database
-			result, err := collection.InsertOne(ctx, newUser)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, models.Response{
-					Status:  http.StatusInternalServerError,
-					Message: "Failed to create user",
-				})
+		// User doesn't exist, create new user
+		now := time.Now()
+		user = models.User{
+			Email:         googleClaims.Email,
+			EmailVerified: true, // Google has already verified the address
+			FullName:      googleClaims.Name,
+			UserType:      "user", // Default user type
+			GoogleUID:     googleClaims.Subject,
+			Identities:    []models.Identity{googleIdentity(googleClaims, now)},
+			ProfilePic:    googleClaims.Picture,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+
+		// Insert user to database
+		result, err := collection.InsertOne(ctx, user)
+		if err != nil {
+			return models.ErrInternal.WithMessage("Failed to create user").Wrap(err)
+		}
+		user.ID = result.InsertedID.(primitive.ObjectID)
+
+		event := auditUser(models.AuditSignup, &user)
+		event.Metadata = map[string]interface{}{"method": models.IdentityProviderGoogle}
+		recordAudit(c, ac.DB, event)
+	} else if user.GoogleUID != googleClaims.Subject {
+		if user.GoogleUID != "" {
+			return models.ErrGoogleAccountMismatch
+		}
+
+		// Linking Google to a password account requires proving ownership of the password
+		if user.Password != "" {
+			if googleReq.Password == "" {
+				return models.ErrGoogleLinkNeedsPassword
 			}
 
-			// Generate JWT token
-			token, err := middleware.GenerateJWT(result.InsertedID.(primitive.ObjectID).Hex(), newUser.Email, newUser.UserType)
+			accountKey := loginAccountKey(user.Email)
+			ipKey := loginIPKey(c.RealIP())
+			lockedUntil, err := checkLockout(ctx, ac.DB, accountKey, ipKey)
 			if err != nil {
-				return c.JSON(http.StatusInternalServerError, models.Response{
-					Status:  http.StatusInternalServerError,
-					Message: "Failed to generate token",
-				})
+				return models.ErrInternal.WithMessage("Failed to check login attempts").Wrap(err)
+			}
+			if !lockedUntil.IsZero() {
+				return tooManyRequests(c, lockedUntil, "Too many failed login attempts. Please try again later")
 			}
 
-			// Set user data for response
-			userData = map[string]interface{}{
-				"token": token,
-				"user": map[string]interface{}{
-					"id":       result.InsertedID,
-					"email":    newUser.Email,
-					"fullName": newUser.FullName,
-					"userType": newUser.UserType,
-				},
+			if err := utils.CheckPassword(googleReq.Password, user.Password); err != nil {
+				ac.recordLoginFailure(ctx, accountKey, ipKey)
+				event := auditUser(models.AuditLoginFailed, &user)
+				event.Metadata = map[string]interface{}{"reason": "invalid_password", "method": models.IdentityProviderGoogle}
+				recordAudit(c, ac.DB, event)
+				return models.ErrInvalidCredentials
 			}
-		} else {
-			return c.JSON(http.StatusInternalServerError, models.Response{
-				Status:  http.StatusInternalServerError,
-				Message: "Database error",
-			})
+			ac.upgradePasswordHash(ctx, &user, googleReq.Password)
 		}
-	} else {
-		// User exists, update Google info
-		update := bson.M{
-			"$set": bson.M{
-				"googleUID":  googleUser.UID,
-				"fullName":   googleUser.DisplayName,
-				"profilePic": googleUser.PhotoURL,
-				"updatedAt":  time.Now(),
-			},
+
+		// Link the Google identity without overwriting the existing profile
+		set := bson.M{
+			"googleUID":     googleClaims.Subject,
+			"emailVerified": true,
+			"updatedAt":     time.Now(),
+		}
+		if user.ProfilePic == "" && googleClaims.Picture != "" {
+			set["profilePic"] = googleClaims.Picture
 		}
 
-		_, err = collection.UpdateOne(ctx, bson.M{"email": googleUser.Email}, update)
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+			"$set":  set,
+			"$push": bson.M{"identities": googleIdentity(googleClaims, time.Now())},
+		})
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, models.Response{
-				Status:  http.StatusInternalServerError,
-				Message: "Failed to update user",
-			})
+			return models.ErrInternal.WithMessage("Failed to update user").Wrap(err)
 		}
+		user.EmailVerified = true
 
-		// Generate JWT token
-		token, err := middleware.GenerateJWT(user.ID.Hex(), user.Email, user.UserType)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, models.Response{
-				Status:  http.StatusInternalServerError,
-				Message: "Failed to generate token",
-			})
+		event := auditUser(models.AuditIdentityLinked, &user)
+		event.Metadata = map[string]interface{}{"provider": models.IdentityProviderGoogle}
+		recordAudit(c, ac.DB, event)
+	}
+
+	if user.Suspended {
+		return models.ErrAccountSuspended
+	}
+	if user.PendingDeletion() {
+		return pendingDeletionError(&user)
+	}
+
+	// Google proves the first factor only; enrolled accounts still need their code
+	return ac.completeLogin(ctx, c, &user)
+}
+
+// VerifyEmail confirms the user's email address with the code sent at signup
+func (ac *AuthController) VerifyEmail(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Parse request body
+	var verifyReq models.EmailVerificationRequest
+	if err := c.Bind(&verifyReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	if verifyReq.Email == "" || verifyReq.Code == "" {
+		return models.ErrMissingFields.WithMessage("Email and code are required")
+	}
+
+	// Reject the attempt while the client IP is locked out
+	ipKey := otpIPKey(c.RealIP())
+	lockedUntil, err := checkLockout(ctx, ac.DB, ipKey)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to check verification attempts").Wrap(err)
+	}
+	if !lockedUntil.IsZero() {
+		return tooManyRequests(c, lockedUntil, "Too many failed attempts. Please try again later")
+	}
+
+	// Get user collection
+	collection := config.GetCollection(ac.DB, "users")
+
+	var user models.User
+	err = collection.FindOne(ctx, bson.M{"email": verifyReq.Email}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrOTPInvalid.WithMessage("Invalid verification code")
 		}
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+	}
 
-		// Set user data for response
-		userData = map[string]interface{}{
-			"token": token,
-			"user": map[string]interface{}{
-				"id":       user.ID,
-				"email":    user.Email,
-				"fullName": user.FullName,
-				"userType": user.UserType,
-			},
+	if user.EmailVerified {
+		return c.JSON(http.StatusOK, models.Response{
+			Status:  http.StatusOK,
+			Message: "Email is already verified",
+		})
+	}
+
+	if user.EmailVerification == nil {
+		return models.ErrOTPInvalid.WithMessage("Invalid verification code")
+	}
+
+	if time.Now().After(user.EmailVerification.ExpiresAt) {
+		return models.ErrOTPExpired.WithMessage("Verification code has expired. Please request a new one")
+	}
+
+	if !otpMatches(verifyReq.Code, user.EmailVerification.OTP) {
+		if err := recordFailedAttempt(ctx, ac.DB, ipKey, ipAttemptPolicy()); err != nil {
+			log.Printf("Failed to record verification attempt for %s: %v", ipKey, err)
+		}
+
+		// Invalidate the code after too many wrong guesses
+		update := bson.M{"$inc": bson.M{"emailVerification.attempts": 1}}
+		codeErr := models.ErrOTPInvalid.WithMessage("Invalid verification code")
+		if user.EmailVerification.Attempts+1 >= otpMaxAttempts() {
+			update = bson.M{"$unset": bson.M{"emailVerification": ""}}
+			codeErr = models.ErrOTPAttemptsExceeded.WithMessage("Too many invalid attempts. Please request a new verification code")
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			log.Printf("Failed to record verification attempt for %s: %v", user.Email, err)
 		}
+		return codeErr
+	}
+
+	// Mark the email as verified and discard the code
+	_, err = collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID},
+		bson.M{
+			"$set":   bson.M{"emailVerified": true, "updatedAt": time.Now()},
+			"$unset": bson.M{"emailVerification": ""},
+		},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to verify email").Wrap(err)
 	}
 
-	// Return success response
 	return c.JSON(http.StatusOK, models.Response{
 		Status:  http.StatusOK,
-		Message: "Login successful",
-		Data:    userData,
+		Message: "Email verified successfully",
+	})
+}
+
+// ResendVerification sends a new email verification code
+func (ac *AuthController) ResendVerification(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Parse request body
+	var resendReq struct {
+		Email string `json:"email"`
+	}
+	if err := c.Bind(&resendReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	if resendReq.Email == "" {
+		return models.ErrMissingFields.WithMessage("Email is required")
+	}
+
+	// The same response is returned whether or not the account exists
+	response := models.Response{
+		Status:  http.StatusOK,
+		Message: "If the account exists and is unverified, a new verification code has been sent",
+	}
+
+	// Get user collection
+	collection := config.GetCollection(ac.DB, "users")
+
+	var user models.User
+	err := collection.FindOne(ctx, bson.M{"email": resendReq.Email}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.JSON(http.StatusOK, response)
+		}
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+	}
+
+	if user.EmailVerified {
+		return c.JSON(http.StatusOK, response)
+	}
+
+	// Enforce a cooldown between verification emails; a rate-limited request gets the generic
+	// response so it cannot confirm the account exists
+	if user.EmailVerification != nil && time.Since(user.EmailVerification.SentAt) < otpResendCooldown() {
+		log.Printf("Verification code for user %s requested during the resend cooldown", user.ID.Hex())
+		return c.JSON(http.StatusOK, response)
+	}
+
+	code, err := generateOTP(otpLength())
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate verification code").Wrap(err)
+	}
+
+	verification := models.OTPInfo{
+		OTP:       code,
+		ExpiresAt: time.Now().Add(emailVerificationTTL()),
+		SentAt:    time.Now(),
+	}
+
+	_, err = collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"emailVerification": verification, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to save verification code").Wrap(err)
+	}
+
+	if err := sendVerificationEmail(user.Email, user.FullName, code); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID.Hex(), err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// emailVerificationTTL returns how long an email verification code stays valid
+func emailVerificationTTL() time.Duration {
+	return config.GetEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token pair
+func (ac *AuthController) RefreshToken(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Parse request body
+	var refreshReq models.RefreshTokenRequest
+	if err := c.Bind(&refreshReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	if refreshReq.RefreshToken == "" {
+		return models.ErrMissingFields.WithMessage("Refresh token is required")
+	}
+
+	// Rotate the refresh token
+	tokens, err := middleware.RefreshTokens(ctx, ac.DB, refreshReq.RefreshToken, c.RealIP())
+	if err != nil {
+		switch err {
+		case middleware.ErrSessionNotFound, middleware.ErrSessionRevoked,
+			middleware.ErrSessionExpired, middleware.ErrRefreshTokenReused:
+			return models.ErrInvalidRefreshToken
+		case middleware.ErrAccountSuspended:
+			return models.ErrAccountSuspended
+		}
+		log.Printf("Error refreshing token: %v", err)
+		return models.ErrInternal.WithMessage("Failed to refresh token").Wrap(err)
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Token refreshed successfully",
+		Data: map[string]interface{}{
+			"token":        tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresIn":    tokens.ExpiresIn,
+		},
+	})
+}
+
+// Logout revokes the session of the current access token
+func (ac *AuthController) Logout(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Get user information from token
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+	sessionID, err := primitive.ObjectIDFromHex(principal.SessionID)
+	if err != nil {
+		return models.ErrInvalidSessionID
+	}
+
+	if err := middleware.RevokeSession(ctx, ac.DB, userID, sessionID); err != nil && err != middleware.ErrSessionNotFound {
+		return models.ErrInternal.WithMessage("Failed to log out").Wrap(err)
+	}
+
+	recordAudit(c, ac.DB, models.AuditEvent{
+		Action:     models.AuditLogout,
+		TargetType: "session",
+		TargetID:   sessionID.Hex(),
+	})
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Logged out successfully",
+	})
+}
+
+// LogoutAll revokes every session of the current user, logging out all devices
+func (ac *AuthController) LogoutAll(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Get user information from token
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	if err := middleware.RevokeUserSessions(ctx, ac.DB, userID, primitive.NilObjectID); err != nil {
+		return models.ErrInternal.WithMessage("Failed to log out from all devices").Wrap(err)
+	}
+
+	recordAudit(c, ac.DB, models.AuditEvent{
+		Action:     models.AuditLogoutAll,
+		TargetType: "user",
+		TargetID:   userID.Hex(),
+	})
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Logged out from all devices successfully",
 	})
 }
 
@@ -504,9 +886,7 @@ func ServeImage(c echo.Context) error {
 
 	// Check if the file exists
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "Image not found",
-		})
+		return models.ErrNotFound.WithMessage("Image not found")
 	}
 
 	// Serve the file
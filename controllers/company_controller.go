@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -43,29 +42,21 @@ func (cc *CompanyController) GetCompanyData(c echo.Context) error {
 	collection := config.GetCollection(cc.DB, "users")
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
 	}
 
-	// Find user by ID and check if it's a company
+	// Find user by ID; the route already requires the company:read permission.
+	// Accounts scheduled for deletion are hidden during the grace period.
 	var user models.User
-	err = collection.FindOne(ctx, bson.M{"_id": userID, "userType": "company"}).Decode(&user)
+	err = collection.FindOne(ctx, bson.M{"_id": userID, "deletionScheduledAt": bson.M{"$exists": false}}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, models.Response{
-				Status:  http.StatusNotFound,
-				Message: "Company not found",
-			})
+			return models.ErrCompanyNotFound
 		}
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to find company",
-		})
+		return models.ErrInternal.WithMessage("Failed to find company").Wrap(err)
 	}
 
 	// Ensure CompanyInfo is not nil
@@ -102,45 +93,36 @@ func (cc *CompanyController) CreateBranch(c echo.Context) error {
 	collection := config.GetCollection(cc.DB, "users")
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
 	}
 
 	// Parse multipart form
 	form, err := c.MultipartForm()
 	if err != nil {
 		log.Printf("Error parsing multipart form: %v", err)
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Failed to parse form data: " + err.Error(),
-		})
+		return models.ErrInvalidFormData.WithMessage("Failed to parse form data")
 	}
 
 	// Get branch data from form
 	data := form.Value["data"]
 	if len(data) == 0 {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Branch data is required",
-		})
+		return models.ErrInvalidFormData.WithMessage("Branch data is required")
 	}
 
 	// Log the raw data for debugging
 	log.Printf("Raw branch data received: %s", data[0])
 
 	// Parse branch data
-	var branchData map[string]interface{}
-	if err := json.Unmarshal([]byte(data[0]), &branchData); err != nil {
+	var branchReq models.BranchRequest
+	if err := json.Unmarshal([]byte(data[0]), &branchReq); err != nil {
 		log.Printf("Error unmarshaling branch data: %v", err)
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid branch data format: " + err.Error(),
-		})
+		return models.ErrInvalidFormData.WithMessage("Invalid branch data format")
+	}
+	if err := c.Validate(&branchReq); err != nil {
+		return validationError(err)
 	}
 
 	// Handle file uploads
@@ -180,47 +162,22 @@ func (cc *CompanyController) CreateBranch(c echo.Context) error {
 		imagePaths = append(imagePaths, uploadPath)
 	}
 
-	// Create branch object with safer type assertions
+	// Create branch object
 	branch := models.Branch{
 		ID:          primitive.NewObjectID(),
-		Name:        getString(branchData, "name", ""),
-		Location:    getString(branchData, "location", ""),
-		Phone:       getString(branchData, "phone", ""),
-		Category:    getString(branchData, "category", ""),
-		SubCategory: getString(branchData, "subCategory", ""),
-		Description: getString(branchData, "description", ""),
+		Name:        branchReq.Name,
+		Location:    branchReq.Location,
+		Latitude:    float64(branchReq.Latitude),
+		Longitude:   float64(branchReq.Longitude),
+		Phone:       branchReq.Phone,
+		Category:    branchReq.Category,
+		SubCategory: branchReq.SubCategory,
+		Description: branchReq.Description,
 		Images:      imagePaths,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
-	// Handle latitude and longitude with more type safety
-	if lat, ok := branchData["latitude"]; ok && lat != nil {
-		switch v := lat.(type) {
-		case float64:
-			branch.Latitude = v
-		case int:
-			branch.Latitude = float64(v)
-		case string:
-			if f, err := strconv.ParseFloat(v, 64); err == nil {
-				branch.Latitude = f
-			}
-		}
-	}
-
-	if lng, ok := branchData["longitude"]; ok && lng != nil {
-		switch v := lng.(type) {
-		case float64:
-			branch.Longitude = v
-		case int:
-			branch.Longitude = float64(v)
-		case string:
-			if f, err := strconv.ParseFloat(v, 64); err == nil {
-				branch.Longitude = f
-			}
-		}
-	}
-
 	log.Printf("Prepared branch object: %+v", branch)
 
 	// Update user document to add the branch
@@ -233,14 +190,18 @@ func (cc *CompanyController) CreateBranch(c echo.Context) error {
 	result, err := collection.UpdateByID(ctx, userID, update)
 	if err != nil {
 		log.Printf("Error updating database: %v", err)
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to save branch: " + err.Error(),
-		})
+		return models.ErrInternal.WithMessage("Failed to save branch").Wrap(err)
 	}
 
 	log.Printf("Database update result: %+v", result)
 
+	recordAudit(c, cc.DB, models.AuditEvent{
+		Action:     models.AuditBranchCreated,
+		TargetType: "branch",
+		TargetID:   branch.ID.Hex(),
+		Metadata:   map[string]interface{}{"companyId": userID.Hex(), "name": branch.Name},
+	})
+
 	return c.JSON(http.StatusOK, models.Response{
 		Status:  http.StatusOK,
 		Message: "Branch created successfully",
@@ -259,16 +220,6 @@ func (cc *CompanyController) CreateBranch(c echo.Context) error {
 	})
 }
 
-// Helper function to safely get string values from a map
-func getString(data map[string]interface{}, key, defaultValue string) string {
-	if val, ok := data[key]; ok && val != nil {
-		if str, ok := val.(string); ok {
-			return str
-		}
-	}
-	return defaultValue
-}
-
 // GetBranches retrieves all branches for a company
 // GetBranches retrieves branches for a company (can be accessed by any authenticated user)
 // GetBranches retrieves branches for a company
@@ -287,40 +238,33 @@ func (cc *CompanyController) GetBranches(c echo.Context) error {
 		companyID, err = primitive.ObjectIDFromHex(companyIDParam)
 		if err != nil {
 			log.Printf("Invalid company ID format: %s", companyIDParam)
-			return c.JSON(http.StatusBadRequest, models.Response{
-				Status:  http.StatusBadRequest,
-				Message: "Invalid company ID format",
-			})
+			return models.ErrInvalidCompanyID
 		}
 	} else {
 		// Otherwise use the authenticated user's ID
-		claims := middleware.GetUserFromToken(c)
-		log.Printf("No valid companyId provided, using authenticated user ID: %s", claims.UserID)
-		companyID, err = primitive.ObjectIDFromHex(claims.UserID)
+		principal := middleware.GetPrincipal(c)
+		log.Printf("No valid companyId provided, using authenticated user ID: %s", principal.UserID)
+		companyID, err = primitive.ObjectIDFromHex(principal.UserID)
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, models.Response{
-				Status:  http.StatusBadRequest,
-				Message: "Invalid user ID",
-			})
+			return models.ErrInvalidUserID
 		}
 	}
 
-	// Find the company/user by ID
+	// Other companies' branches are visible to anyone allowed to browse companies
+	if !middleware.AuthorizeOwned(c, companyID.Hex(), middleware.PermBranchRead, middleware.PermCompaniesList) {
+		return models.ErrPermissionDenied.WithMessage("You do not have permission to view these branches")
+	}
+
+	// Find the company/user by ID; companies scheduled for deletion are hidden
 	collection := config.GetCollection(cc.DB, "users")
 	var user models.User
-	err = collection.FindOne(ctx, bson.M{"_id": companyID}).Decode(&user)
+	err = collection.FindOne(ctx, bson.M{"_id": companyID, "deletionScheduledAt": bson.M{"$exists": false}}).Decode(&user)
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, models.Response{
-				Status:  http.StatusNotFound,
-				Message: "Company not found",
-			})
+			return models.ErrCompanyNotFound
 		}
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to find company",
-		})
+		return models.ErrInternal.WithMessage("Failed to find company").Wrap(err)
 	}
 
 	// Check if user is a company and has branches
@@ -349,31 +293,22 @@ func (cc *CompanyController) DeleteBranch(c echo.Context) error {
 	collection := config.GetCollection(cc.DB, "users")
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
 	}
 
 	// Get branch ID from URL parameter
 	branchID := c.Param("id")
 	if branchID == "" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Branch ID is required",
-		})
+		return models.ErrMissingFields.WithMessage("Branch ID is required")
 	}
 
 	// Convert string branch ID to ObjectID
 	branchObjectID, err := primitive.ObjectIDFromHex(branchID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid branch ID format",
-		})
+		return models.ErrInvalidBranchID
 	}
 
 	// First, find the branch to get its image paths before deleting
@@ -385,16 +320,10 @@ func (cc *CompanyController) DeleteBranch(c echo.Context) error {
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, models.Response{
-				Status:  http.StatusNotFound,
-				Message: "Branch not found",
-			})
+			return models.ErrBranchNotFound
 		}
 		log.Printf("Error finding branch: %v", err)
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to find branch: " + err.Error(),
-		})
+		return models.ErrInternal.WithMessage("Failed to find branch").Wrap(err)
 	}
 
 	// Find the branch with the matching ID and get its images
@@ -418,17 +347,11 @@ func (cc *CompanyController) DeleteBranch(c echo.Context) error {
 	result, err := collection.UpdateByID(ctx, userID, update)
 	if err != nil {
 		log.Printf("Error deleting branch: %v", err)
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to delete branch: " + err.Error(),
-		})
+		return models.ErrInternal.WithMessage("Failed to delete branch").Wrap(err)
 	}
 
 	if result.ModifiedCount == 0 {
-		return c.JSON(http.StatusNotFound, models.Response{
-			Status:  http.StatusNotFound,
-			Message: "Branch not found or already deleted",
-		})
+		return models.ErrBranchNotFound.WithMessage("Branch not found or already deleted")
 	}
 
 	// Delete image files from filesystem
@@ -447,6 +370,13 @@ func (cc *CompanyController) DeleteBranch(c echo.Context) error {
 		log.Printf("Some image files could not be deleted: %v", deletionErrors)
 	}
 
+	recordAudit(c, cc.DB, models.AuditEvent{
+		Action:     models.AuditBranchDeleted,
+		TargetType: "branch",
+		TargetID:   branchObjectID.Hex(),
+		Metadata:   map[string]interface{}{"companyId": userID.Hex()},
+	})
+
 	return c.JSON(http.StatusOK, models.Response{
 		Status:  http.StatusOK,
 		Message: "Branch deleted successfully",
@@ -466,63 +396,48 @@ func (cc *CompanyController) UpdateBranch(c echo.Context) error {
 	collection := config.GetCollection(cc.DB, "users")
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
 	}
 
 	// Get branch ID from URL parameter
 	branchID := c.Param("id")
 	if branchID == "" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Branch ID is required",
-		})
+		return models.ErrMissingFields.WithMessage("Branch ID is required")
 	}
 
 	// Convert string branch ID to ObjectID
 	branchObjectID, err := primitive.ObjectIDFromHex(branchID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid branch ID format",
-		})
+		return models.ErrInvalidBranchID
 	}
 
 	// Parse multipart form
 	form, err := c.MultipartForm()
 	if err != nil {
 		log.Printf("Error parsing multipart form: %v", err)
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Failed to parse form data: " + err.Error(),
-		})
+		return models.ErrInvalidFormData.WithMessage("Failed to parse form data")
 	}
 
 	// Get branch data from form
 	data := form.Value["data"]
 	if len(data) == 0 {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Branch data is required",
-		})
+		return models.ErrInvalidFormData.WithMessage("Branch data is required")
 	}
 
 	// Log the raw data for debugging
 	log.Printf("Raw branch update data received: %s", data[0])
 
 	// Parse branch data
-	var branchData map[string]interface{}
-	if err := json.Unmarshal([]byte(data[0]), &branchData); err != nil {
+	var branchReq models.BranchUpdateRequest
+	if err := json.Unmarshal([]byte(data[0]), &branchReq); err != nil {
 		log.Printf("Error unmarshaling branch data: %v", err)
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid branch data format: " + err.Error(),
-		})
+		return models.ErrInvalidFormData.WithMessage("Invalid branch data format")
+	}
+	if err := c.Validate(&branchReq); err != nil {
+		return validationError(err)
 	}
 
 	// First, find the existing branch to get current image paths
@@ -534,16 +449,10 @@ func (cc *CompanyController) UpdateBranch(c echo.Context) error {
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, models.Response{
-				Status:  http.StatusNotFound,
-				Message: "Branch not found",
-			})
+			return models.ErrBranchNotFound
 		}
 		log.Printf("Error finding branch: %v", err)
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to find branch: " + err.Error(),
-		})
+		return models.ErrInternal.WithMessage("Failed to find branch").Wrap(err)
 	}
 
 	// Find the existing branch and get its current images
@@ -610,49 +519,34 @@ func (cc *CompanyController) UpdateBranch(c echo.Context) error {
 		finalImagePaths = existingImagePaths
 	}
 
-	// Create updated branch object with safer type assertions
-	updatedBranch := models.Branch{
-		ID:          branchObjectID, // Keep the original ID
-		Name:        getString(branchData, "name", existingBranch.Name),
-		Location:    getString(branchData, "location", existingBranch.Location),
-		Phone:       getString(branchData, "phone", existingBranch.Phone),
-		Category:    getString(branchData, "category", existingBranch.Category),
-		SubCategory: getString(branchData, "subCategory", existingBranch.SubCategory),
-		Description: getString(branchData, "description", existingBranch.Description),
-		Images:      finalImagePaths,
-		CreatedAt:   existingBranch.CreatedAt, // Keep original creation time
-		UpdatedAt:   time.Now(),               // Update the update timestamp
-	}
-
-	// Handle latitude and longitude with more type safety
-	if lat, ok := branchData["latitude"]; ok && lat != nil {
-		switch v := lat.(type) {
-		case float64:
-			updatedBranch.Latitude = v
-		case int:
-			updatedBranch.Latitude = float64(v)
-		case string:
-			if f, err := strconv.ParseFloat(v, 64); err == nil {
-				updatedBranch.Latitude = f
-			}
-		}
-	} else {
-		updatedBranch.Latitude = existingBranch.Latitude
-	}
-
-	if lng, ok := branchData["longitude"]; ok && lng != nil {
-		switch v := lng.(type) {
-		case float64:
-			updatedBranch.Longitude = v
-		case int:
-			updatedBranch.Longitude = float64(v)
-		case string:
-			if f, err := strconv.ParseFloat(v, 64); err == nil {
-				updatedBranch.Longitude = f
-			}
-		}
-	} else {
-		updatedBranch.Longitude = existingBranch.Longitude
+	// Create updated branch object, keeping the values of fields that were not sent
+	updatedBranch := existingBranch
+	updatedBranch.ID = branchObjectID
+	updatedBranch.Images = finalImagePaths
+	updatedBranch.UpdatedAt = time.Now()
+	if branchReq.Name != nil {
+		updatedBranch.Name = *branchReq.Name
+	}
+	if branchReq.Location != nil {
+		updatedBranch.Location = *branchReq.Location
+	}
+	if branchReq.Latitude != nil {
+		updatedBranch.Latitude = float64(*branchReq.Latitude)
+	}
+	if branchReq.Longitude != nil {
+		updatedBranch.Longitude = float64(*branchReq.Longitude)
+	}
+	if branchReq.Phone != nil {
+		updatedBranch.Phone = *branchReq.Phone
+	}
+	if branchReq.Category != nil {
+		updatedBranch.Category = *branchReq.Category
+	}
+	if branchReq.SubCategory != nil {
+		updatedBranch.SubCategory = *branchReq.SubCategory
+	}
+	if branchReq.Description != nil {
+		updatedBranch.Description = *branchReq.Description
 	}
 
 	log.Printf("Prepared updated branch object: %+v", updatedBranch)
@@ -668,10 +562,7 @@ func (cc *CompanyController) UpdateBranch(c echo.Context) error {
 	_, err = collection.UpdateByID(ctx, userID, pull)
 	if err != nil {
 		log.Printf("Error removing old branch: %v", err)
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to update branch: " + err.Error(),
-		})
+		return models.ErrInternal.WithMessage("Failed to update branch").Wrap(err)
 	}
 
 	// Then, add the updated branch
@@ -684,14 +575,21 @@ func (cc *CompanyController) UpdateBranch(c echo.Context) error {
 	result, err := collection.UpdateByID(ctx, userID, push)
 	if err != nil {
 		log.Printf("Error updating branch: %v", err)
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to update branch: " + err.Error(),
-		})
+		return models.ErrInternal.WithMessage("Failed to update branch").Wrap(err)
 	}
 
 	log.Printf("Database update result: %+v", result)
 
+	updated := auditDocument(updatedBranch)
+	delete(updated, "createdAt")
+	recordAudit(c, cc.DB, models.AuditEvent{
+		Action:     models.AuditBranchUpdated,
+		TargetType: "branch",
+		TargetID:   branchObjectID.Hex(),
+		Changes:    auditChanges(auditDocument(existingBranch), updated),
+		Metadata:   map[string]interface{}{"companyId": userID.Hex()},
+	})
+
 	return c.JSON(http.StatusOK, models.Response{
 		Status:  http.StatusOK,
 		Message: "Branch updated successfully",
@@ -719,39 +617,28 @@ func (cc *CompanyController) GetCompanyBranches(c echo.Context) error {
 	// Get company ID from URL parameter
 	companyID := c.Param("id")
 	if companyID == "" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Company ID is required",
-		})
+		return models.ErrMissingFields.WithMessage("Company ID is required")
 	}
 
 	// Convert string company ID to ObjectID
 	objectID, err := primitive.ObjectIDFromHex(companyID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid company ID format",
-		})
+		return models.ErrInvalidCompanyID
 	}
 
-	// Find user by ID without restricting to company type
+	// Find user by ID without restricting to company type; companies scheduled for deletion are hidden
 	collection := config.GetCollection(cc.DB, "users")
 	var user models.User
 	err = collection.FindOne(ctx, bson.M{
-		"_id": objectID,
+		"_id":                 objectID,
+		"deletionScheduledAt": bson.M{"$exists": false},
 	}).Decode(&user)
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, models.Response{
-				Status:  http.StatusNotFound,
-				Message: "Company not found",
-			})
+			return models.ErrCompanyNotFound
 		}
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to find company",
-		})
+		return models.ErrInternal.WithMessage("Failed to find company").Wrap(err)
 	}
 
 	// Ensure CompanyInfo exists
@@ -773,37 +660,33 @@ func (cc *CompanyController) GetCompanyBranches(c echo.Context) error {
 }
 
 func (cc *CompanyController) UpdateCompanyData(c echo.Context) error {
-	// Get user ID from JWT token
-	userID, err := middleware.ExtractUserID(c)
-	if err != nil {
-		return c.JSON(http.StatusUnauthorized, models.Response{
-			Status:  http.StatusUnauthorized,
-			Message: "Invalid or expired token: " + err.Error(),
-		})
-	}
+	// Get user ID from the authenticated principal
+	userID := middleware.GetPrincipal(c).UserID
 
 	// Parse the request body
 	var updateData map[string]interface{}
 	if err := c.Bind(&updateData); err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid request format: " + err.Error(),
-		})
+		return models.ErrInvalidRequest
 	}
 
 	// Convert userID string to ObjectID
 	objectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID format: " + err.Error(),
-		})
+		return models.ErrInvalidUserID
+	}
+
+	// The phone number only changes through verification, so a new one must not look accepted
+	if _, ok := updateData["phone"]; ok {
+		return models.ErrValidationFailed.WithErrors([]models.FieldError{{
+			Field:   "phone",
+			Code:    "not_allowed",
+			Message: "Change your phone number with POST /api/users/phone/send-code and /api/users/phone/verify",
+		}})
 	}
 
 	// Create update document with the fields to update
 	update := bson.M{
 		"$set": bson.M{
-			"phone":     updateData["phone"],
 			"whatsapp":  updateData["whatsapp"],
 			"website":   updateData["website"],
 			"facebook":  updateData["facebook"],
@@ -818,12 +701,10 @@ func (cc *CompanyController) UpdateCompanyData(c echo.Context) error {
 	// Use the correct database and collection names
 	collection := cc.DB.Database("barrim").Collection("users")
 
+	before := auditSnapshot(context.Background(), collection, filter, update["$set"].(bson.M))
 	result, err := collection.UpdateOne(context.Background(), filter, update)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to update company data: " + err.Error(),
-		})
+		return models.ErrInternal.WithMessage("Failed to update company data").Wrap(err)
 	}
 
 	if result.ModifiedCount == 0 {
@@ -831,10 +712,7 @@ func (cc *CompanyController) UpdateCompanyData(c echo.Context) error {
 		var user models.User
 		err = collection.FindOne(context.Background(), filter).Decode(&user)
 		if err != nil {
-			return c.JSON(http.StatusNotFound, models.Response{
-				Status:  http.StatusNotFound,
-				Message: "User not found",
-			})
+			return models.ErrUserNotFound
 		}
 
 		// User exists but no changes were made
@@ -844,6 +722,13 @@ func (cc *CompanyController) UpdateCompanyData(c echo.Context) error {
 		})
 	}
 
+	recordAudit(c, cc.DB, models.AuditEvent{
+		Action:     models.AuditCompanyDataUpdated,
+		TargetType: "user",
+		TargetID:   userID,
+		Changes:    auditChanges(before, update["$set"].(bson.M)),
+	})
+
 	return c.JSON(http.StatusOK, models.Response{
 		Status:  http.StatusOK,
 		Message: "Company data updated successfully",
@@ -0,0 +1,344 @@
+// controllers/data_export.go
+package controllers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/jobs"
+	"github.com/HSouheill/barrim_backend/middleware"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// dataExportBuildTimeout bounds how long building one archive may take
+const dataExportBuildTimeout = 5 * time.Minute
+
+// dataExportTTL returns how long a finished export can be downloaded
+func dataExportTTL() time.Duration {
+	return config.GetEnvDuration("DATA_EXPORT_TTL", 7*24*time.Hour)
+}
+
+// dataExportCooldown returns the minimum time between two export requests of a user
+func dataExportCooldown() time.Duration {
+	return config.GetEnvDuration("DATA_EXPORT_COOLDOWN", 24*time.Hour)
+}
+
+// dataExportLink builds the download link for an export from PUBLIC_BASE_URL. The request
+// Host header is never used, since a client could point the emailed link at another server.
+func dataExportLink(exportID primitive.ObjectID, token string) string {
+	base := strings.TrimSuffix(config.GetEnv("PUBLIC_BASE_URL", "http://localhost:"+config.GetEnv("PORT", "8080")), "/")
+	return base + "/api/data-exports/" + exportID.Hex() + "/download?token=" + url.QueryEscape(token)
+}
+
+// RequestDataExport starts building an archive of everything stored about the current user.
+// The archive is built in the background and the user is emailed a download link when it is ready.
+func (uc *UserController) RequestDataExport(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	// Get data export collection
+	collection := config.GetCollection(uc.DB, "data_exports")
+
+	// One request per cooldown period; the archive can be large
+	var latest models.DataExport
+	err = collection.FindOne(ctx,
+		bson.M{"userId": userID, "createdAt": bson.M{"$gt": time.Now().Add(-dataExportCooldown())}},
+		options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	).Decode(&latest)
+	if err == nil {
+		if latest.Status == models.DataExportPending {
+			return models.ErrDataExportPending.WithData(latest)
+		}
+		return tooManyRequests(c, latest.CreatedAt.Add(dataExportCooldown()),
+			"You have already requested a data export recently. Please try again later")
+	}
+	if err != mongo.ErrNoDocuments {
+		return models.ErrInternal.WithMessage("Failed to check previous data exports").Wrap(err)
+	}
+
+	token, err := generateResetToken()
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to create data export").Wrap(err)
+	}
+
+	// Pending exports expire too, so a build that never finishes is still cleaned up
+	now := time.Now()
+	export := models.DataExport{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Status:    models.DataExportPending,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: now.Add(dataExportTTL()),
+		CreatedAt: now,
+	}
+	if _, err := collection.InsertOne(ctx, export); err != nil {
+		return models.ErrInternal.WithMessage("Failed to create data export").Wrap(err)
+	}
+
+	link := dataExportLink(export.ID, token)
+	go uc.buildDataExport(export, link)
+
+	recordAudit(c, uc.DB, models.AuditEvent{
+		Action:     models.AuditDataExportRequested,
+		TargetType: "user",
+		TargetID:   userID.Hex(),
+		Metadata:   map[string]interface{}{"exportId": export.ID.Hex()},
+	})
+
+	return c.JSON(http.StatusAccepted, models.Response{
+		Status:  http.StatusAccepted,
+		Message: "Your data export is being prepared. We will email you when it is ready",
+		// The download link is only sent by email, to the account's address
+		Data: map[string]interface{}{
+			"export": export,
+		},
+	})
+}
+
+// ListDataExports returns the current user's data exports, newest first
+func (uc *UserController) ListDataExports(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	exports := []models.DataExport{}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	if err := findAll(ctx, config.GetCollection(uc.DB, "data_exports"), bson.M{"userId": userID}, opts, &exports); err != nil {
+		return models.ErrInternal.WithMessage("Failed to retrieve data exports").Wrap(err)
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Data exports retrieved successfully",
+		Data:    exports,
+	})
+}
+
+// DownloadDataExport serves a finished archive. The link's token authorizes the download,
+// so it works from the notification email without logging in.
+func (uc *UserController) DownloadDataExport(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	exportID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	token := c.QueryParam("token")
+	if err != nil || token == "" {
+		return models.ErrDataExportNotFound
+	}
+
+	var export models.DataExport
+	err = config.GetCollection(uc.DB, "data_exports").FindOne(ctx, bson.M{
+		"_id":       exportID,
+		"tokenHash": utils.HashToken(token),
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&export)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrDataExportNotFound
+		}
+		return models.ErrInternal.WithMessage("Failed to retrieve data export").Wrap(err)
+	}
+
+	if export.Status != models.DataExportReady {
+		return models.ErrDataExportNotReady.WithData(export)
+	}
+
+	recordAudit(c, uc.DB, models.AuditEvent{
+		Action:     models.AuditDataExportDownloaded,
+		ActorID:    export.UserID,
+		TargetType: "user",
+		TargetID:   export.UserID.Hex(),
+		Metadata:   map[string]interface{}{"exportId": export.ID.Hex()},
+	})
+
+	return c.Attachment(export.FilePath, "barrim-data-export-"+export.CreatedAt.Format("20060102")+".zip")
+}
+
+// buildDataExport writes the archive for an export and emails the user the download link
+func (uc *UserController) buildDataExport(export models.DataExport, link string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dataExportBuildTimeout)
+	defer cancel()
+
+	collection := config.GetCollection(uc.DB, "data_exports")
+	path := filepath.Join(jobs.DataExportUserDir(export.UserID), export.ID.Hex()+".zip")
+
+	user, err := writeDataExport(ctx, uc.DB, export.UserID, path)
+	if err != nil {
+		log.Printf("Failed to build data export %s: %v", export.ID.Hex(), err)
+		os.Remove(path)
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": export.ID}, bson.M{"$set": bson.M{"status": models.DataExportFailed}}); err != nil {
+			log.Printf("Failed to mark data export %s as failed: %v", export.ID.Hex(), err)
+		}
+		return
+	}
+
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(dataExportTTL())
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": export.ID}, bson.M{"$set": bson.M{
+		"status":      models.DataExportReady,
+		"filePath":    path,
+		"size":        size,
+		"completedAt": now,
+		"expiresAt":   expiresAt,
+	}})
+	if err != nil {
+		log.Printf("Failed to mark data export %s as ready: %v", export.ID.Hex(), err)
+		return
+	}
+
+	if err := sendDataExportReadyEmail(user.Email, user.FullName, link, expiresAt); err != nil {
+		log.Printf("Failed to send data export email to %s: %v", user.Email, err)
+	}
+}
+
+// writeDataExport writes a ZIP archive with the user's account, related records and uploaded files.
+// Credentials and one-time codes are left out.
+func writeDataExport(ctx context.Context, db *mongo.Client, userID primitive.ObjectID, path string) (*models.User, error) {
+	raw, err := config.GetCollection(db, "users").FindOne(ctx, bson.M{"_id": userID},
+		options.FindOne().SetProjection(userSecretsProjection)).DecodeBytes()
+	if err != nil {
+		return nil, err
+	}
+	var profile bson.M
+	if err := bson.Unmarshal(raw, &profile); err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := bson.Unmarshal(raw, &user); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	if err := writeJSONEntry(archive, "profile.json", profile); err != nil {
+		return nil, err
+	}
+	if user.CompanyInfo != nil {
+		if err := writeJSONEntry(archive, "branches.json", user.CompanyInfo.Branches); err != nil {
+			return nil, err
+		}
+	}
+
+	// Related records, each in its own file
+	records := []struct {
+		name       string
+		collection string
+		filter     bson.M
+		out        interface{}
+	}{
+		{"sessions.json", "sessions", bson.M{"userId": userID}, &[]models.Session{}},
+		{"api_keys.json", "api_keys", bson.M{"companyId": userID}, &[]models.APIKey{}},
+		{"audit_events.json", "audit_events", bson.M{"$or": bson.A{
+			bson.M{"actorId": userID},
+			bson.M{"targetType": "user", "targetId": userID.Hex()},
+		}}, &[]models.AuditEvent{}},
+		{"companies.json", "companies", bson.M{"userId": userID}, &[]bson.M{}},
+		{"service_providers.json", "serviceProviders", bson.M{"userId": userID}, &[]bson.M{}},
+		{"wholesalers.json", "wholesalers", bson.M{"userId": userID}, &[]bson.M{}},
+	}
+	for _, record := range records {
+		opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+		if err := findAll(ctx, config.GetCollection(db, record.collection), record.filter, opts, record.out); err != nil {
+			return nil, err
+		}
+		if err := writeJSONEntry(archive, record.name, record.out); err != nil {
+			return nil, err
+		}
+	}
+
+	// Uploaded images keep their path below uploads/
+	for _, upload := range jobs.AccountFiles(&user) {
+		if err := writeFileEntry(archive, filepath.ToSlash(filepath.Join("files", upload)), upload); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return &user, file.Close()
+}
+
+// writeJSONEntry adds v to the archive as an indented JSON file
+func writeJSONEntry(archive *zip.Writer, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// writeFileEntry copies a file from disk into the archive
+func writeFileEntry(archive *zip.Writer, name, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// findAll decodes every document matching filter into out, which must point to a slice
+func findAll(ctx context.Context, collection *mongo.Collection, filter bson.M, opts *options.FindOptions, out interface{}) error {
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, out)
+}
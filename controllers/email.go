@@ -0,0 +1,220 @@
+// controllers/email.go
+package controllers
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"time"
+
+	"gopkg.in/gomail.v2"
+
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+// sendEmail sends an HTML email using the SMTP settings from the environment
+func sendEmail(to, subject, body string) error {
+	// Get email configuration from environment variables
+	smtpHost := os.Getenv("SMTP_HOST")
+	smtpPort := os.Getenv("SMTP_PORT")
+	smtpUser := os.Getenv("SMTP_USER")
+	smtpPass := os.Getenv("SMTP_PASS")
+	fromEmail := os.Getenv("FROM_EMAIL")
+
+	// Fallback to default values if not set
+	if smtpHost == "" {
+		smtpHost = "smtp.gmail.com"
+	}
+	if smtpPort == "" {
+		smtpPort = "587"
+	}
+	if fromEmail == "" {
+		fromEmail = "husseinsouheil15@gmail.com"
+	}
+
+	// Convert port to int
+	portInt := 587 // default
+	fmt.Sscanf(smtpPort, "%d", &portInt)
+
+	// Set up the gomail message
+	m := gomail.NewMessage()
+	m.SetHeader("From", fromEmail)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/html", body)
+
+	// Dialer
+	d := gomail.NewDialer(smtpHost, portInt, smtpUser, smtpPass)
+
+	// Send email
+	if err := d.DialAndSend(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendOTPByEmail sends the OTP to the user's email
+func sendOTPByEmail(email, name, otp string) error {
+	// Email content
+	subject := "Password Reset OTP"
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Reset Your Password</h2>
+			<p>Hello %s,</p>
+			<p>You have requested to reset your password. Please use the following OTP code to verify your request:</p>
+			<h3 style="background-color: #f0f0f0; padding: 10px; font-size: 24px; letter-spacing: 5px; text-align: center;">%s</h3>
+			<p>This code will expire in 15 minutes.</p>
+			<p>If you did not request a password reset, please ignore this email or contact support if you have concerns.</p>
+			<p>Thank you,<br>The Barrim Team</p>
+		</body>
+		</html>
+	`, name, otp)
+
+	return sendEmail(email, subject, body)
+}
+
+// sendVerificationEmail sends the email verification code to a newly registered address
+func sendVerificationEmail(email, name, code string) error {
+	subject := "Verify Your Email Address"
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Verify Your Email</h2>
+			<p>Hello %s,</p>
+			<p>Thank you for joining Barrim. Please use the following code to verify your email address:</p>
+			<h3 style="background-color: #f0f0f0; padding: 10px; font-size: 24px; letter-spacing: 5px; text-align: center;">%s</h3>
+			<p>This code will expire in %s.</p>
+			<p>If you did not create an account, please ignore this email.</p>
+			<p>Thank you,<br>The Barrim Team</p>
+		</body>
+		</html>
+	`, name, code, formatDuration(emailVerificationTTL()))
+
+	return sendEmail(email, subject, body)
+}
+
+// sendLoginCodeEmail sends a one-time login code and, when a link is given, a one-click sign-in link
+func sendLoginCodeEmail(email, name, code, link string) error {
+	subject := "Your Barrim Login Code"
+	linkHTML := ""
+	if link != "" {
+		linkHTML = fmt.Sprintf(`<p>Or sign in directly with this link: <a href="%s">Sign in to Barrim</a></p>`, html.EscapeString(link))
+	}
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Sign In to Barrim</h2>
+			<p>Hello %s,</p>
+			<p>Use the following code to sign in:</p>
+			<h3 style="background-color: #f0f0f0; padding: 10px; font-size: 24px; letter-spacing: 5px; text-align: center;">%s</h3>
+			%s
+			<p>This code will expire in %s and can only be used once.</p>
+			<p>If you did not try to sign in, please ignore this email.</p>
+			<p>Thank you,<br>The Barrim Team</p>
+		</body>
+		</html>
+	`, name, code, linkHTML, formatDuration(passwordlessTTL()))
+
+	return sendEmail(email, subject, body)
+}
+
+// sendEmailChangeCode sends the confirmation code for an email change to the new address
+func sendEmailChangeCode(newEmail, name, code string) error {
+	subject := "Confirm Your New Email Address"
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Confirm Your New Email</h2>
+			<p>Hello %s,</p>
+			<p>You have requested to use this address for your Barrim account. Please use the following code to confirm the change:</p>
+			<h3 style="background-color: #f0f0f0; padding: 10px; font-size: 24px; letter-spacing: 5px; text-align: center;">%s</h3>
+			<p>This code will expire in %s.</p>
+			<p>If you did not request this change, please ignore this email.</p>
+			<p>Thank you,<br>The Barrim Team</p>
+		</body>
+		</html>
+	`, name, code, formatDuration(emailChangeTTL()))
+
+	return sendEmail(newEmail, subject, body)
+}
+
+// sendEmailChangeNotice warns the current address that a change to another address was requested
+func sendEmailChangeNotice(oldEmail, name, newEmail string) error {
+	subject := "Email Change Requested"
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Email Change Requested</h2>
+			<p>Hello %s,</p>
+			<p>A request was made to change the email address of your Barrim account to %s.</p>
+			<p>The change only takes effect once it is confirmed from the new address.</p>
+			<p>If you did not request this change, please change your password and contact support immediately.</p>
+			<p>Thank you,<br>The Barrim Team</p>
+		</body>
+		</html>
+	`, name, maskEmail(newEmail))
+
+	return sendEmail(oldEmail, subject, body)
+}
+
+// sendNewDeviceLoginEmail tells the account owner about a login from a device not seen before
+func sendNewDeviceLoginEmail(email, name string, device models.DeviceInfo, ip string, at time.Time) error {
+	subject := "New Login to Your Barrim Account"
+	deviceName := device.Name
+	if deviceName == "" {
+		deviceName = "Unknown device"
+	}
+	if device.Platform != "" {
+		deviceName += " (" + device.Platform + ")"
+	}
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>New Login Detected</h2>
+			<p>Hello %s,</p>
+			<p>Your Barrim account was just signed in to from a new device:</p>
+			<p>Device: %s<br>IP address: %s<br>Time: %s</p>
+			<p>If this was you, no action is needed. Otherwise, sign out of the session from your account settings and change your password immediately.</p>
+			<p>Thank you,<br>The Barrim Team</p>
+		</body>
+		</html>
+	`, name, html.EscapeString(deviceName), html.EscapeString(ip), at.UTC().Format("2006-01-02 15:04 MST"))
+
+	return sendEmail(email, subject, body)
+}
+
+// sendDataExportReadyEmail sends the download link of a finished personal data export
+func sendDataExportReadyEmail(email, name, link string, expiresAt time.Time) error {
+	subject := "Your Barrim Data Export Is Ready"
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Your Data Export Is Ready</h2>
+			<p>Hello %s,</p>
+			<p>The copy of your Barrim data you requested is ready to download:</p>
+			<p><a href="%s">Download your data</a></p>
+			<p>The link expires on %s. If you did not request this export, change your password immediately.</p>
+			<p>Thank you,<br>The Barrim Team</p>
+		</body>
+		</html>
+	`, name, html.EscapeString(link), expiresAt.UTC().Format("2006-01-02 15:04 MST"))
+
+	return sendEmail(email, subject, body)
+}
+
+// formatDuration renders a duration as a human readable string for emails
+func formatDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		hours := int(d / time.Hour)
+		if hours == 1 {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", hours)
+	}
+	minutes := int(d / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
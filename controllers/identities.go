@@ -0,0 +1,229 @@
+// controllers/identities.go
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/middleware"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// googleIdentity builds the identity entry for a verified Google account
+func googleIdentity(claims *utils.GoogleClaims, linkedAt time.Time) models.Identity {
+	return models.Identity{
+		Provider:    models.IdentityProviderGoogle,
+		ProviderUID: claims.Subject,
+		Email:       claims.Email,
+		LinkedAt:    linkedAt,
+	}
+}
+
+// GetIdentities lists the login methods linked to the current account
+func (ac *AuthController) GetIdentities(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := ac.findUserByHex(ctx, middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrUserNotFound
+	}
+
+	identities := user.Identities
+	if identities == nil {
+		identities = []models.Identity{}
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Identities retrieved successfully",
+		Data:    identities,
+	})
+}
+
+// LinkGoogle links a Google account to the current account
+func (ac *AuthController) LinkGoogle(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var linkReq models.LinkGoogleRequest
+	if err := c.Bind(&linkReq); err != nil || linkReq.IDToken == "" {
+		return models.ErrMissingFields.WithMessage("Google ID token is required")
+	}
+
+	googleClaims, err := ac.GoogleVerifier.Verify(ctx, linkReq.IDToken)
+	if err != nil {
+		log.Printf("Google ID token rejected: %v", err)
+		return models.ErrInvalidGoogleToken
+	}
+
+	user, err := ac.findUserByHex(ctx, middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrUserNotFound
+	}
+
+	if user.HasIdentity(models.IdentityProviderGoogle) || user.GoogleUID != "" {
+		return models.ErrIdentityAlreadyLinked
+	}
+
+	// Get user collection
+	collection := config.GetCollection(ac.DB, "users")
+
+	count, err := collection.CountDocuments(ctx, bson.M{"googleUID": googleClaims.Subject})
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to check Google account").Wrap(err)
+	}
+	if count > 0 {
+		return models.ErrIdentityLinkedElsewhere
+	}
+
+	identity := googleIdentity(googleClaims, time.Now())
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "googleUID": bson.M{"$in": bson.A{nil, ""}}},
+		bson.M{
+			"$set":  bson.M{"googleUID": googleClaims.Subject, "updatedAt": time.Now()},
+			"$push": bson.M{"identities": identity},
+		},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to link Google account").Wrap(err)
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrIdentityAlreadyLinked
+	}
+
+	event := auditUser(models.AuditIdentityLinked, user)
+	event.Metadata = map[string]interface{}{"provider": models.IdentityProviderGoogle}
+	recordAudit(c, ac.DB, event)
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Google account linked successfully",
+		Data:    identity,
+	})
+}
+
+// UnlinkIdentity removes a login method, refusing to remove the last one
+func (ac *AuthController) UnlinkIdentity(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	provider := c.Param("provider")
+	principal := middleware.GetPrincipal(c)
+	user, err := ac.findUserByHex(ctx, principal.UserID)
+	if err != nil {
+		return models.ErrUserNotFound
+	}
+
+	if !user.HasIdentity(provider) {
+		return models.ErrIdentityNotFound
+	}
+
+	update := bson.M{
+		"$set":  bson.M{"updatedAt": time.Now()},
+		"$pull": bson.M{"identities": bson.M{"provider": provider}},
+	}
+	switch provider {
+	case models.IdentityProviderPassword:
+		update["$unset"] = bson.M{"password": ""}
+	case models.IdentityProviderGoogle:
+		update["$unset"] = bson.M{"googleUID": ""}
+	}
+
+	// Requiring another provider in the filter keeps the guard correct under concurrent unlinks
+	result, err := config.GetCollection(ac.DB, "users").UpdateOne(
+		ctx,
+		bson.M{
+			"_id":        user.ID,
+			"identities": bson.M{"$elemMatch": bson.M{"provider": bson.M{"$ne": provider}}},
+		},
+		update,
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to unlink login method").Wrap(err)
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrIdentityLastMethod
+	}
+
+	// Sessions opened with the removed method on other devices are signed out
+	if sessionID, err := primitive.ObjectIDFromHex(principal.SessionID); err == nil {
+		if err := middleware.RevokeUserSessions(ctx, ac.DB, user.ID, sessionID); err != nil {
+			log.Printf("Failed to revoke sessions after unlinking %s for %s: %v", provider, user.ID.Hex(), err)
+		}
+	}
+
+	event := auditUser(models.AuditIdentityUnlinked, user)
+	event.Metadata = map[string]interface{}{"provider": provider}
+	recordAudit(c, ac.DB, event)
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Login method removed successfully",
+	})
+}
+
+// SetPassword adds a password to an account that only signs in with another provider
+func (ac *AuthController) SetPassword(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var setReq models.SetPasswordRequest
+	if err := c.Bind(&setReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	user, err := ac.findUserByHex(ctx, middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrUserNotFound
+	}
+
+	if user.Password != "" || user.HasIdentity(models.IdentityProviderPassword) {
+		return models.ErrPasswordAlreadySet
+	}
+
+	if fieldErrors := validateNewPassword("newPassword", setReq.NewPassword, user.Email, user.FullName); len(fieldErrors) > 0 {
+		return models.ErrWeakPassword.WithErrors(fieldErrors)
+	}
+
+	hashedPassword, err := utils.HashPassword(setReq.NewPassword)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to hash password").Wrap(err)
+	}
+
+	now := time.Now()
+	result, err := config.GetCollection(ac.DB, "users").UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "identities.provider": bson.M{"$ne": models.IdentityProviderPassword}},
+		bson.M{
+			"$set":  bson.M{"password": hashedPassword, "updatedAt": now},
+			"$push": bson.M{"identities": models.Identity{Provider: models.IdentityProviderPassword, LinkedAt: now}},
+		},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to set password").Wrap(err)
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrPasswordAlreadySet
+	}
+
+	recordAudit(c, ac.DB, auditUser(models.AuditPasswordSet, user))
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Password set successfully",
+	})
+}
@@ -0,0 +1,17 @@
+// controllers/jwks_controller.go
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/HSouheill/barrim_backend/middleware"
+)
+
+// ServeJWKS publishes the public keys that verify Barrim access tokens
+func ServeJWKS(c echo.Context) error {
+	// Verifiers may cache the set briefly; rotated-in keys should be published before they sign
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, middleware.SigningKeys().JWKS())
+}
@@ -4,10 +4,10 @@ package controllers
 import (
 	"context"
 	"crypto/rand"
-	"fmt"
+	"encoding/hex"
+	"log"
 	"math/big"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -15,21 +15,35 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"gopkg.in/gomail.v2"
 
 	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/middleware"
 	"github.com/HSouheill/barrim_backend/models"
 	"github.com/HSouheill/barrim_backend/utils"
 )
 
 // PasswordController handles password reset functionality
 type PasswordController struct {
-	DB *mongo.Client
+	DB  *mongo.Client
+	SMS utils.SMSSender
 }
 
 // NewPasswordController creates a new password controller
 func NewPasswordController(db *mongo.Client) *PasswordController {
-	return &PasswordController{DB: db}
+	return &PasswordController{DB: db, SMS: utils.NewSMSSenderFromEnv()}
+}
+
+// resetAccountFilter finds the account a reset request refers to. Email takes precedence;
+// a phone only finds accounts that verified it.
+func resetAccountFilter(email, phone string) (bson.M, bool, error) {
+	if email != "" {
+		return bson.M{"email": email}, false, nil
+	}
+	normalized, err := utils.NormalizePhone(phone, defaultPhoneCountryCode())
+	if err != nil {
+		return nil, true, err
+	}
+	return bson.M{"phone": normalized, "phoneVerified": true}, true, nil
 }
 
 // ForgetPassword initiates the password reset process
@@ -41,95 +55,103 @@ func (pc *PasswordController) ForgetPassword(c echo.Context) error {
 	// Parse request body
 	var forgetPassReq struct {
 		Email string `json:"email"`
+		Phone string `json:"phone"` // alternative channel: the account's verified phone
 	}
 	if err := c.Bind(&forgetPassReq); err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid request body",
-		})
+		return models.ErrInvalidRequest
+	}
+
+	// Validate email or phone
+	if forgetPassReq.Email == "" && forgetPassReq.Phone == "" {
+		return models.ErrMissingFields.WithMessage("Email or phone number is required")
 	}
 
-	// Validate email
-	if forgetPassReq.Email == "" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Email is required",
-		})
+	filter, viaSMS, err := resetAccountFilter(forgetPassReq.Email, forgetPassReq.Phone)
+	if err != nil {
+		return models.ErrInvalidPhone
+	}
+
+	// The same response is returned whether or not the account exists
+	response := models.Response{
+		Status:  http.StatusOK,
+		Message: "If an account matches, a password reset OTP has been sent to it",
 	}
 
 	// Get user collection
 	collection := config.GetCollection(pc.DB, "users")
 
-	// Check if user with email exists
+	// Check if the user exists
 	var user models.User
-	err := collection.FindOne(ctx, bson.M{"email": forgetPassReq.Email}).Decode(&user)
+	err = collection.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, models.Response{
-				Status:  http.StatusNotFound,
-				Message: "No account associated with this email",
-			})
+			return c.JSON(http.StatusOK, response)
 		}
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to check user",
-		})
+		return models.ErrInternal.WithMessage("Failed to check user").Wrap(err)
 	}
 
-	// Generate a 4-digit OTP
-	otp, err := generateOTP(4)
+	// Enforce a cooldown between OTP emails; a rate-limited request gets the generic response
+	// so it cannot confirm the account exists
+	if user.OTPInfo != nil && time.Since(user.OTPInfo.SentAt) < otpResendCooldown() {
+		log.Printf("Password reset for user %s requested during the OTP cooldown", user.ID.Hex())
+		return c.JSON(http.StatusOK, response)
+	}
+
+	// Generate the OTP
+	otp, err := generateOTP(otpLength())
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to generate OTP",
-		})
+		return models.ErrInternal.WithMessage("Failed to generate OTP").Wrap(err)
 	}
 
 	// Set OTP expiry time (15 minutes from now)
 	expiryTime := time.Now().Add(15 * time.Minute)
 
-	// Store OTP and expiry in database
+	// Store OTP and expiry in database; a new OTP also resets the attempt counter
 	otpInfo := models.OTPInfo{
 		OTP:       otp,
+		Purpose:   models.OTPPurposePasswordReset,
 		ExpiresAt: expiryTime,
+		SentAt:    time.Now(),
 	}
 
-	// Update user with OTP info
+	// Update user with OTP info; a newer request invalidates any reset token already issued
 	_, err = collection.UpdateOne(
 		ctx,
 		bson.M{"_id": user.ID},
-		bson.M{"$set": bson.M{"otpInfo": otpInfo, "updatedAt": time.Now()}},
+		bson.M{
+			"$set":   bson.M{"otpInfo": otpInfo, "updatedAt": time.Now()},
+			"$unset": bson.M{"resetTokenHash": "", "resetTokenExpiresAt": ""},
+		},
 	)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to save OTP information",
-		})
+		return models.ErrInternal.WithMessage("Failed to save OTP information").Wrap(err)
 	}
 
-	// Send OTP via email
-	err = sendOTPByEmail(user.Email, user.FullName, otp)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to send OTP email",
-		})
+	channel := "email"
+	if viaSMS {
+		channel = "sms"
 	}
+	event := auditUser(models.AuditPasswordResetSent, &user)
+	event.Metadata = map[string]interface{}{"channel": channel}
+	recordAudit(c, pc.DB, event)
 
-	// Return masked email for UI
-	maskedEmail := maskEmail(user.Email)
+	// Send OTP via SMS to the verified phone
+	if viaSMS {
+		if err := sendOTPBySMS(ctx, pc.SMS, user.Phone, otp); err != nil {
+			log.Printf("Failed to send reset SMS to %s: %v", utils.MaskPhone(user.Phone), err)
+		}
+		return c.JSON(http.StatusOK, response)
+	}
 
-	return c.JSON(http.StatusOK, models.Response{
-		Status:  http.StatusOK,
-		Message: "Password reset OTP sent successfully",
-		Data: map[string]interface{}{
-			"email":  maskedEmail,
-			"userId": user.ID.Hex(),
-		},
-	})
+	// Send OTP via email
+	if err := sendOTPByEmail(user.Email, user.FullName, otp); err != nil {
+		log.Printf("Failed to send reset email to user %s: %v", user.ID.Hex(), err)
+	}
+
+	return c.JSON(http.StatusOK, response)
 }
 
-// VerifyOTP verifies the OTP provided by the user
+// VerifyOTP verifies the OTP provided by the user and exchanges it for a single-use reset token
 func (pc *PasswordController) VerifyOTP(c echo.Context) error {
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -137,31 +159,32 @@ func (pc *PasswordController) VerifyOTP(c echo.Context) error {
 
 	// Parse request body
 	var verifyOTPReq struct {
-		UserID string `json:"userId"`
-		OTP    string `json:"otp"`
+		Email string `json:"email"`
+		Phone string `json:"phone"`
+		OTP   string `json:"otp"`
 	}
 	if err := c.Bind(&verifyOTPReq); err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid request body",
-		})
+		return models.ErrInvalidRequest
 	}
 
 	// Validate required fields
-	if verifyOTPReq.UserID == "" || verifyOTPReq.OTP == "" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "User ID and OTP are required",
-		})
+	if (verifyOTPReq.Email == "" && verifyOTPReq.Phone == "") || verifyOTPReq.OTP == "" {
+		return models.ErrMissingFields.WithMessage("Email or phone number and OTP are required")
+	}
+
+	filter, _, err := resetAccountFilter(verifyOTPReq.Email, verifyOTPReq.Phone)
+	if err != nil {
+		return models.ErrInvalidPhone
 	}
 
-	// Convert user ID to ObjectID
-	userID, err := primitive.ObjectIDFromHex(verifyOTPReq.UserID)
+	// Reject the attempt while the client IP is locked out
+	ipKey := otpIPKey(c.RealIP())
+	lockedUntil, err := checkLockout(ctx, pc.DB, ipKey)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInternal.WithMessage("Failed to check OTP attempts").Wrap(err)
+	}
+	if !lockedUntil.IsZero() {
+		return tooManyRequests(c, lockedUntil, "Too many failed attempts. Please try again later")
 	}
 
 	// Get user collection
@@ -169,65 +192,67 @@ func (pc *PasswordController) VerifyOTP(c echo.Context) error {
 
 	// Find user with OTP
 	var user models.User
-	err = collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, models.Response{
-				Status:  http.StatusNotFound,
-				Message: "User not found",
-			})
-		}
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to retrieve user",
-		})
+	err = collection.FindOne(ctx, filter).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return models.ErrInternal.WithMessage("Failed to retrieve user").Wrap(err)
 	}
 
-	// Check if OTP info exists
-	if user.OTPInfo == nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "No OTP request found. Please request a new OTP",
-		})
+	// Unknown accounts get the same answer as accounts without a pending OTP;
+	// login codes cannot be used to reset a password
+	if err == mongo.ErrNoDocuments || user.OTPInfo == nil || user.OTPInfo.Purpose == models.OTPPurposeLogin {
+		return models.ErrOTPNotFound
 	}
 
 	// Check if OTP is expired
 	if time.Now().After(user.OTPInfo.ExpiresAt) {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "OTP has expired. Please request a new OTP",
-		})
+		return models.ErrOTPExpired
 	}
 
 	// Verify OTP
-	if user.OTPInfo.OTP != verifyOTPReq.OTP {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid OTP",
-		})
+	if !otpMatches(verifyOTPReq.OTP, user.OTPInfo.OTP) {
+		if err := recordFailedAttempt(ctx, pc.DB, ipKey, ipAttemptPolicy()); err != nil {
+			log.Printf("Failed to record OTP attempt for %s: %v", ipKey, err)
+		}
+
+		// Invalidate the OTP after too many wrong guesses
+		update := bson.M{"$inc": bson.M{"otpInfo.attempts": 1}}
+		codeErr := models.ErrOTPInvalid.WithMessage("Invalid OTP")
+		if user.OTPInfo.Attempts+1 >= otpMaxAttempts() {
+			update = bson.M{"$unset": bson.M{"otpInfo": ""}}
+			codeErr = models.ErrOTPAttemptsExceeded.WithMessage("Too many invalid attempts. Please request a new OTP")
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			log.Printf("Failed to record OTP attempt for user %s: %v", user.ID.Hex(), err)
+		}
+
+		return codeErr
 	}
 
-	// Generate a reset token
-	resetToken := generateResetToken()
-	tokenExpiry := time.Now().Add(1 * time.Hour)
+	// Generate a signed reset token; only its hash is stored
+	resetToken, tokenExpiry, err := middleware.GeneratePasswordResetToken(user.ID.Hex())
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate reset token").Wrap(err)
+	}
 
-	// Update user with reset token
-	_, err = collection.UpdateOne(
+	// Consuming this exact OTP binds the token to its verification; a newer OTP or a
+	// concurrent verification of the same one leaves nothing to match
+	result, err := collection.UpdateOne(
 		ctx,
-		bson.M{"_id": user.ID},
+		bson.M{"_id": user.ID, "otpInfo.otp": user.OTPInfo.OTP, "otpInfo.sentAt": user.OTPInfo.SentAt},
 		bson.M{
 			"$set": bson.M{
-				"resetPasswordToken":  resetToken,
+				"resetTokenHash":      utils.HashToken(resetToken),
 				"resetTokenExpiresAt": tokenExpiry,
 				"updatedAt":           time.Now(),
 			},
+			"$unset": bson.M{"otpInfo": ""},
 		},
 	)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to update reset token",
-		})
+		return models.ErrInternal.WithMessage("Failed to update reset token").Wrap(err)
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrOTPNotFound
 	}
 
 	return c.JSON(http.StatusOK, models.Response{
@@ -235,12 +260,12 @@ func (pc *PasswordController) VerifyOTP(c echo.Context) error {
 		Message: "OTP verified successfully",
 		Data: map[string]interface{}{
 			"resetToken": resetToken,
-			"userId":     user.ID.Hex(),
+			"expiresAt":  tokenExpiry,
 		},
 	})
 }
 
-// ResetPassword resets the user's password
+// ResetPassword resets the user's password with a reset token; each token works once
 func (pc *PasswordController) ResetPassword(c echo.Context) error {
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -248,82 +273,169 @@ func (pc *PasswordController) ResetPassword(c echo.Context) error {
 
 	// Parse request body
 	var resetPassReq struct {
-		UserID      string `json:"userId"`
 		ResetToken  string `json:"resetToken"`
 		NewPassword string `json:"newPassword"`
 	}
 	if err := c.Bind(&resetPassReq); err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid request body",
-		})
+		return models.ErrInvalidRequest
 	}
 
 	// Validate required fields
-	if resetPassReq.UserID == "" || resetPassReq.ResetToken == "" || resetPassReq.NewPassword == "" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "User ID, reset token, and new password are required",
-		})
+	if resetPassReq.ResetToken == "" || resetPassReq.NewPassword == "" {
+		return models.ErrMissingFields.WithMessage("Reset token and new password are required")
 	}
 
-	// Password validation
-	if len(resetPassReq.NewPassword) < 8 {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Password must be at least 8 characters long",
-		})
+	// The signature proves the token was issued here and names the user it belongs to
+	subject, err := middleware.ParsePasswordResetToken(resetPassReq.ResetToken)
+	if err != nil {
+		return models.ErrResetTokenInvalid
 	}
-
-	// Convert user ID to ObjectID
-	userID, err := primitive.ObjectIDFromHex(resetPassReq.UserID)
+	userID, err := primitive.ObjectIDFromHex(subject)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrResetTokenInvalid
 	}
 
 	// Get user collection
 	collection := config.GetCollection(pc.DB, "users")
 
-	// Find user with token
+	// Find user with token; a used or superseded token no longer matches the stored hash
+	tokenFilter := bson.M{
+		"_id":                 userID,
+		"resetTokenHash":      utils.HashToken(resetPassReq.ResetToken),
+		"resetTokenExpiresAt": bson.M{"$gt": time.Now()},
+	}
 	var user models.User
-	err = collection.FindOne(ctx, bson.M{
-		"_id":                userID,
-		"resetPasswordToken": resetPassReq.ResetToken,
-	}).Decode(&user)
+	err = collection.FindOne(ctx, tokenFilter).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusBadRequest, models.Response{
-				Status:  http.StatusBadRequest,
-				Message: "Invalid or expired reset token",
-			})
+			return models.ErrResetTokenInvalid
 		}
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to retrieve user",
-		})
+		return models.ErrInternal.WithMessage("Failed to retrieve user").Wrap(err)
 	}
 
-	// Check if token is expired
-	if user.ResetTokenExpiresAt.Before(time.Now()) {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Reset token has expired. Please request a new password reset",
-		})
+	if fieldErrors := validateNewPassword("newPassword", resetPassReq.NewPassword, user.Email, user.FullName); len(fieldErrors) > 0 {
+		return models.ErrWeakPassword.WithErrors(fieldErrors)
 	}
 
 	// Hash the new password
 	hashedPassword, err := utils.HashPassword(resetPassReq.NewPassword)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to hash password",
-		})
+		return models.ErrInternal.WithMessage("Failed to hash password").Wrap(err)
 	}
 
 	// Update user's password and clear reset token fields
+	update := bson.M{
+		"$set": bson.M{
+			"password":  hashedPassword,
+			"updatedAt": time.Now(),
+		},
+		"$unset": bson.M{
+			"resetTokenHash":      "",
+			"resetTokenExpiresAt": "",
+			"otpInfo":             "",
+		},
+	}
+	// A Google-only account that resets its password gains password login
+	if !user.HasIdentity(models.IdentityProviderPassword) {
+		update["$push"] = bson.M{"identities": models.Identity{Provider: models.IdentityProviderPassword, LinkedAt: time.Now()}}
+	}
+	// Matching the token hash again makes the token single-use under concurrent requests
+	result, err := collection.UpdateOne(ctx, tokenFilter, update)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to update password").Wrap(err)
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrResetTokenInvalid
+	}
+
+	// Whoever knew the old password is signed out everywhere
+	if err := middleware.RevokeUserSessions(ctx, pc.DB, user.ID, primitive.NilObjectID); err != nil {
+		log.Printf("Failed to revoke sessions after password reset for %s: %v", user.ID.Hex(), err)
+	}
+
+	recordAudit(c, pc.DB, auditUser(models.AuditPasswordReset, &user))
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Password reset successfully",
+	})
+}
+
+// Helper functions
+
+// ChangePassword changes the password of the authenticated user after checking the current one
+func (pc *PasswordController) ChangePassword(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Parse request body
+	var changeReq models.ChangePasswordRequest
+	if err := c.Bind(&changeReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	// Validate required fields
+	if changeReq.CurrentPassword == "" || changeReq.NewPassword == "" {
+		return models.ErrMissingFields.WithMessage("Current password and new password are required")
+	}
+
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+	sessionID, err := primitive.ObjectIDFromHex(principal.SessionID)
+	if err != nil {
+		return models.ErrInvalidSessionID
+	}
+
+	// Get user collection
+	collection := config.GetCollection(pc.DB, "users")
+
+	var user models.User
+	if err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrUserNotFound
+		}
+		return models.ErrInternal.WithMessage("Failed to retrieve user").Wrap(err)
+	}
+
+	if user.Password == "" {
+		return models.ErrPasswordNotSet
+	}
+
+	// Wrong current passwords count like failed logins so a stolen token cannot brute force it
+	accountKey := loginAccountKey(user.Email)
+	lockedUntil, err := checkLockout(ctx, pc.DB, accountKey)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to check login attempts").Wrap(err)
+	}
+	if !lockedUntil.IsZero() {
+		return tooManyRequests(c, lockedUntil, "Too many failed attempts. Please try again later")
+	}
+
+	if err := utils.CheckPassword(changeReq.CurrentPassword, user.Password); err != nil {
+		if err := recordFailedAttempt(ctx, pc.DB, accountKey, loginAccountPolicy()); err != nil {
+			log.Printf("Failed to record password attempt for %s: %v", accountKey, err)
+		}
+		return models.ErrCurrentPasswordIncorrect
+	}
+
+	if changeReq.CurrentPassword == changeReq.NewPassword {
+		return models.ErrPasswordUnchanged
+	}
+
+	if fieldErrors := validateNewPassword("newPassword", changeReq.NewPassword, user.Email, user.FullName); len(fieldErrors) > 0 {
+		return models.ErrWeakPassword.WithErrors(fieldErrors)
+	}
+
+	// Hash the new password
+	hashedPassword, err := utils.HashPassword(changeReq.NewPassword)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to hash password").Wrap(err)
+	}
+
 	_, err = collection.UpdateOne(
 		ctx,
 		bson.M{"_id": user.ID},
@@ -333,27 +445,29 @@ func (pc *PasswordController) ResetPassword(c echo.Context) error {
 				"updatedAt": time.Now(),
 			},
 			"$unset": bson.M{
-				"resetPasswordToken":  "",
+				"resetTokenHash":      "",
 				"resetTokenExpiresAt": "",
 				"otpInfo":             "",
 			},
 		},
 	)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to update password",
-		})
+		return models.ErrInternal.WithMessage("Failed to update password").Wrap(err)
+	}
+
+	// Sign out every other device; the session making the change stays logged in
+	if err := middleware.RevokeUserSessions(ctx, pc.DB, user.ID, sessionID); err != nil {
+		log.Printf("Failed to revoke sessions after password change for %s: %v", user.ID.Hex(), err)
 	}
 
+	recordAudit(c, pc.DB, auditUser(models.AuditPasswordChanged, &user))
+
 	return c.JSON(http.StatusOK, models.Response{
 		Status:  http.StatusOK,
-		Message: "Password reset successfully",
+		Message: "Password changed successfully",
 	})
 }
 
-// Helper functions
-
 // generateOTP generates a random OTP of the specified length
 func generateOTP(length int) (string, error) {
 	const digits = "0123456789"
@@ -368,66 +482,6 @@ func generateOTP(length int) (string, error) {
 	return string(result), nil
 }
 
-// sendOTPByEmail sends the OTP to the user's email
-// sendOTPByEmail sends the OTP to the user's email
-func sendOTPByEmail(email, name, otp string) error {
-	// Get email configuration from environment variables
-	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPort := os.Getenv("SMTP_PORT")
-	smtpUser := os.Getenv("SMTP_USER")
-	smtpPass := os.Getenv("SMTP_PASS")
-	fromEmail := os.Getenv("FROM_EMAIL")
-
-	// Fallback to default values if not set
-	if smtpHost == "" {
-		smtpHost = "smtp.gmail.com"
-	}
-	if smtpPort == "" {
-		smtpPort = "587"
-	}
-	if fromEmail == "" {
-		fromEmail = "husseinsouheil15@gmail.com"
-	}
-
-	fmt.Println("SMTP Config:", smtpHost, smtpPort, smtpUser, smtpPass, fromEmail)
-
-	// Email content
-	subject := "Password Reset OTP"
-	body := fmt.Sprintf(`
-		<html>
-		<body>
-			<h2>Reset Your Password</h2>
-			<p>Hello %s,</p>
-			<p>You have requested to reset your password. Please use the following OTP code to verify your request:</p>
-			<h3 style="background-color: #f0f0f0; padding: 10px; font-size: 24px; letter-spacing: 5px; text-align: center;">%s</h3>
-			<p>This code will expire in 15 minutes.</p>
-			<p>If you did not request a password reset, please ignore this email or contact support if you have concerns.</p>
-			<p>Thank you,<br>The Barrim Team</p>
-		</body>
-		</html>
-	`, name, otp)
-
-	// Convert port to int
-	portInt := 587 // default
-	fmt.Sscanf(smtpPort, "%d", &portInt)
-
-	// Set up the gomail message
-	m := gomail.NewMessage()
-	m.SetHeader("From", fromEmail)
-	m.SetHeader("To", email)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", body)
-
-	// Dialer
-	d := gomail.NewDialer(smtpHost, portInt, smtpUser, smtpPass)
-
-	// Send email
-	if err := d.DialAndSend(m); err != nil {
-		return err
-	}
-	return nil
-}
-
 // maskEmail partially masks an email address for privacy
 func maskEmail(email string) string {
 	parts := strings.Split(email, "@")
@@ -445,9 +499,11 @@ func maskEmail(email string) string {
 	return name[:2] + strings.Repeat("*", len(name)-2) + "@" + domain
 }
 
-// generateResetToken generates a random token for password reset
-func generateResetToken() string {
+// generateResetToken generates a random single-use token, such as a login link token
+func generateResetToken() (string, error) {
 	b := make([]byte, 32)
-	rand.Read(b)
-	return fmt.Sprintf("%x", b)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
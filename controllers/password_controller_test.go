@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/HSouheill/barrim_backend/middleware"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+func TestResetPasswordIsSingleUse(t *testing.T) {
+	t.Setenv("JWT_EPHEMERAL_KEYS", "true")
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	userID := primitive.NewObjectID()
+	resetToken, _, err := middleware.GeneratePasswordResetToken(userID.Hex())
+	if err != nil {
+		t.Fatalf("GeneratePasswordResetToken() error = %v", err)
+	}
+	userDoc := bson.D{
+		{Key: "_id", Value: userID},
+		{Key: "email", Value: "user@example.com"},
+		{Key: "fullName", Value: "Test User"},
+		{Key: "userType", Value: "user"},
+		{Key: "resetTokenHash", Value: utils.HashToken(resetToken)},
+		{Key: "resetTokenExpiresAt", Value: time.Now().Add(time.Hour)},
+	}
+	updated := func(n int) bson.D {
+		return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+	}
+
+	resetPassword := func(mt *mtest.T) error {
+		body := `{"resetToken":"` + resetToken + `","newPassword":"Quiet-Harbor-Lantern-42"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/reset-password", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		return (&PasswordController{DB: mt.Client}).ResetPassword(c)
+	}
+
+	mt.Run("reset consumes the token and signs out every session", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "barrim.users", mtest.FirstBatch, userDoc),
+			updated(1),
+			updated(3),
+			mtest.CreateSuccessResponse(),
+		)
+		if err := resetPassword(mt); err != nil {
+			mt.Fatalf("ResetPassword() error = %v", err)
+		}
+
+		var updates []bson.Raw
+		for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+			if event.CommandName == "update" {
+				updates = append(updates, event.Command)
+			}
+		}
+		if len(updates) != 2 {
+			mt.Fatalf("sent %d update commands, want 2", len(updates))
+		}
+
+		password := updates[0].Lookup("updates").Array().Index(0).Value().Document()
+		if got := password.Lookup("q", "resetTokenHash").StringValue(); got != utils.HashToken(resetToken) {
+			mt.Errorf("password update filter hash = %q, want the token's hash", got)
+		}
+		if _, err := password.LookupErr("u", "$unset", "resetTokenHash"); err != nil {
+			mt.Error("password update does not clear the reset token")
+		}
+
+		revoke := updates[1]
+		if got := revoke.Lookup("update").StringValue(); got != "sessions" {
+			mt.Errorf("second update targets %q, want sessions", got)
+		}
+		statement := revoke.Lookup("updates").Array().Index(0).Value().Document()
+		if got := statement.Lookup("q", "userId").ObjectID(); got != userID {
+			mt.Errorf("revoked sessions of %s, want %s", got.Hex(), userID.Hex())
+		}
+		if _, err := statement.LookupErr("q", "_id"); err == nil {
+			mt.Error("reset kept one of the user's sessions")
+		}
+		if !statement.Lookup("multi").Boolean() {
+			mt.Error("session revocation does not cover every session")
+		}
+	})
+
+	mt.Run("used token is rejected", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "barrim.users", mtest.FirstBatch))
+		if err := resetPassword(mt); err != models.ErrResetTokenInvalid {
+			mt.Fatalf("ResetPassword() error = %v, want %v", err, models.ErrResetTokenInvalid)
+		}
+	})
+
+	mt.Run("concurrent reset with the same token is rejected", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "barrim.users", mtest.FirstBatch, userDoc),
+			updated(0),
+		)
+		if err := resetPassword(mt); err != models.ErrResetTokenInvalid {
+			mt.Fatalf("ResetPassword() error = %v, want %v", err, models.ErrResetTokenInvalid)
+		}
+		for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+			if event.CommandName == "update" && event.Command.Lookup("update").StringValue() == "sessions" {
+				mt.Error("the losing request revoked sessions")
+			}
+		}
+	})
+
+	mt.Run("tampered token is rejected before any lookup", func(mt *mtest.T) {
+		// Change a character in the middle of the signature
+		i := len(resetToken) - 10
+		flipped := byte('A')
+		if resetToken[i] == 'A' {
+			flipped = 'B'
+		}
+		resetToken = resetToken[:i] + string(flipped) + resetToken[i+1:]
+		if err := resetPassword(mt); err != models.ErrResetTokenInvalid {
+			mt.Fatalf("ResetPassword() error = %v, want %v", err, models.ErrResetTokenInvalid)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("sent %s for a tampered token", event.CommandName)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+// controllers/password_policy.go
+package controllers
+
+import (
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// passwordPolicy returns the password policy configured by the PASSWORD_* variables
+func passwordPolicy() utils.PasswordPolicy {
+	return utils.PasswordPolicy{
+		MinLength:        config.GetEnvInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:        72,
+		RequireUppercase: config.GetEnvBool("PASSWORD_REQUIRE_UPPERCASE", true),
+		RequireLowercase: config.GetEnvBool("PASSWORD_REQUIRE_LOWERCASE", true),
+		RequireDigit:     config.GetEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol:    config.GetEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		RejectCommon:     config.GetEnvBool("PASSWORD_REJECT_COMMON", true),
+	}
+}
+
+// validateNewPassword checks a new password against the policy and returns one field error per
+// rule it breaks. The account's email and name must not appear in the password.
+func validateNewPassword(field, password, email, fullName string) []models.FieldError {
+	var fieldErrors []models.FieldError
+	for _, violation := range passwordPolicy().Validate(password, email, fullName) {
+		fieldErrors = append(fieldErrors, models.FieldError{
+			Field:   field,
+			Code:    violation.Code,
+			Message: violation.Message,
+		})
+	}
+	return fieldErrors
+}
@@ -0,0 +1,226 @@
+// controllers/passwordless.go
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// passwordlessTTL is how long an emailed login code and link stay valid
+func passwordlessTTL() time.Duration {
+	return config.GetEnvDuration("PASSWORDLESS_TTL", 15*time.Minute)
+}
+
+// passwordlessAllowed reports whether accounts of the type may sign in with an emailed code.
+// The policy is read from PASSWORDLESS_LOGIN_FOR.
+func passwordlessAllowed(userType string) bool {
+	for _, t := range config.GetEnvList("PASSWORDLESS_LOGIN_FOR", []string{"user"}) {
+		if t == userType {
+			return true
+		}
+	}
+	return false
+}
+
+// passwordlessLink builds the sign-in link, or returns "" when PASSWORDLESS_LINK_URL is not configured
+func passwordlessLink(token string) string {
+	base := config.GetEnv("PASSWORDLESS_LINK_URL", "")
+	if base == "" {
+		return ""
+	}
+	separator := "?"
+	if strings.Contains(base, "?") {
+		separator = "&"
+	}
+	return base + separator + "token=" + url.QueryEscape(token)
+}
+
+// RequestLoginCode emails a one-time login code and link
+func (ac *AuthController) RequestLoginCode(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Parse request body
+	var loginReq models.PasswordlessLoginRequest
+	if err := c.Bind(&loginReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	if loginReq.Email == "" {
+		return models.ErrMissingFields.WithMessage("Email is required")
+	}
+
+	// The same response is returned whether or not the account exists
+	response := models.Response{
+		Status:  http.StatusOK,
+		Message: "If an account can sign in with a code, one has been sent to this email",
+	}
+
+	// Get user collection
+	collection := config.GetCollection(ac.DB, "users")
+
+	var user models.User
+	err := collection.FindOne(ctx, bson.M{"email": loginReq.Email}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.JSON(http.StatusOK, response)
+		}
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+	}
+
+	if user.Suspended || user.PendingDeletion() || !passwordlessAllowed(user.UserType) {
+		return c.JSON(http.StatusOK, response)
+	}
+
+	// Enforce a cooldown between codes; the OTP slot is shared with password reset. A
+	// rate-limited request gets the generic response so it cannot confirm the account exists.
+	if user.OTPInfo != nil && time.Since(user.OTPInfo.SentAt) < otpResendCooldown() {
+		log.Printf("Login code for user %s requested during the OTP cooldown", user.ID.Hex())
+		return c.JSON(http.StatusOK, response)
+	}
+
+	code, err := generateOTP(otpLength())
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate code").Wrap(err)
+	}
+	linkToken, err := generateResetToken()
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate code").Wrap(err)
+	}
+
+	// Only the hash of the link token is stored, like refresh tokens
+	now := time.Now()
+	otpInfo := models.OTPInfo{
+		OTP:       code,
+		Purpose:   models.OTPPurposeLogin,
+		TokenHash: utils.HashToken(linkToken),
+		ExpiresAt: now.Add(passwordlessTTL()),
+		SentAt:    now,
+	}
+	_, err = collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"otpInfo": otpInfo, "updatedAt": now}},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to save login code").Wrap(err)
+	}
+
+	if err := sendLoginCodeEmail(user.Email, user.FullName, code, passwordlessLink(linkToken)); err != nil {
+		log.Printf("Failed to send login code to user %s: %v", user.ID.Hex(), err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// VerifyLoginCode exchanges an emailed code or link token for the same payload Login returns
+func (ac *AuthController) VerifyLoginCode(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Parse request body
+	var verifyReq models.PasswordlessVerifyRequest
+	if err := c.Bind(&verifyReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	viaLink := verifyReq.Token != ""
+	if !viaLink && (verifyReq.Email == "" || verifyReq.Code == "") {
+		return models.ErrMissingFields.WithMessage("Email and code, or a login link token, are required")
+	}
+
+	// Reject the attempt while the client IP is locked out
+	ipKey := otpIPKey(c.RealIP())
+	lockedUntil, err := checkLockout(ctx, ac.DB, ipKey)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to check login attempts").Wrap(err)
+	}
+	if !lockedUntil.IsZero() {
+		return tooManyRequests(c, lockedUntil, "Too many failed attempts. Please try again later")
+	}
+
+	invalid := models.ErrLoginCodeInvalid
+
+	// Get user collection
+	collection := config.GetCollection(ac.DB, "users")
+
+	filter := bson.M{"email": verifyReq.Email, "otpInfo.purpose": models.OTPPurposeLogin}
+	if viaLink {
+		filter = bson.M{"otpInfo.tokenHash": utils.HashToken(verifyReq.Token), "otpInfo.purpose": models.OTPPurposeLogin}
+	}
+
+	var user models.User
+	err = collection.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			if err := recordFailedAttempt(ctx, ac.DB, ipKey, ipAttemptPolicy()); err != nil {
+				log.Printf("Failed to record login code attempt for %s: %v", ipKey, err)
+			}
+			return invalid
+		}
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+	}
+
+	if time.Now().After(user.OTPInfo.ExpiresAt) {
+		return invalid
+	}
+
+	if !viaLink && !otpMatches(verifyReq.Code, user.OTPInfo.OTP) {
+		if err := recordFailedAttempt(ctx, ac.DB, ipKey, ipAttemptPolicy()); err != nil {
+			log.Printf("Failed to record login code attempt for %s: %v", ipKey, err)
+		}
+
+		// Invalidate the code after too many wrong guesses
+		update := bson.M{"$inc": bson.M{"otpInfo.attempts": 1}}
+		if user.OTPInfo.Attempts+1 >= otpMaxAttempts() {
+			update = bson.M{"$unset": bson.M{"otpInfo": ""}}
+			invalid = invalid.WithMessage("Too many invalid attempts. Please request a new code")
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			log.Printf("Failed to record login code attempt for user %s: %v", user.ID.Hex(), err)
+		}
+		return invalid
+	}
+
+	// Consume the code: only the request that removes it may log in. Receiving the email
+	// also proves the address, so it is marked verified.
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "otpInfo.tokenHash": user.OTPInfo.TokenHash, "otpInfo.purpose": models.OTPPurposeLogin},
+		bson.M{
+			"$set":   bson.M{"emailVerified": true, "updatedAt": time.Now()},
+			"$unset": bson.M{"otpInfo": "", "emailVerification": ""},
+		},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to verify login code").Wrap(err)
+	}
+	if result.ModifiedCount == 0 {
+		return invalid
+	}
+	user.EmailVerified = true
+
+	if user.Suspended {
+		return models.ErrAccountSuspended
+	}
+	if user.PendingDeletion() {
+		return pendingDeletionError(&user)
+	}
+
+	// Accounts with two-factor enabled still get a challenge
+	return ac.completeLogin(ctx, c, &user)
+}
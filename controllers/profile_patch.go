@@ -0,0 +1,304 @@
+// controllers/profile_patch.go
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+// maxProfilePatchSize limits the size of a profile update body
+const maxProfilePatchSize = 64 << 10
+
+// patchKind is the JSON type a patchable profile field accepts
+type patchKind int
+
+const (
+	patchString patchKind = iota
+	patchDate
+	patchNumber
+	patchInt
+	patchBool
+)
+
+// patchField describes one profile field that may be changed through a merge patch.
+// Keys of the field maps are dot paths, identical in JSON and in the stored document.
+type patchField struct {
+	Kind      patchKind
+	Required  bool // cannot be cleared with null or set to an empty string
+	MaxLength int
+	Min, Max  float64 // bounds of numeric fields
+}
+
+// basePatchFields can be changed by every account
+var basePatchFields = map[string]patchField{
+	"fullName":            {Kind: patchString, Required: true, MaxLength: 100},
+	"dateOfBirth":         {Kind: patchDate},
+	"gender":              {Kind: patchString, MaxLength: 32},
+	"location.city":       {Kind: patchString, MaxLength: 100},
+	"location.country":    {Kind: patchString, MaxLength: 100},
+	"location.district":   {Kind: patchString, MaxLength: 100},
+	"location.street":     {Kind: patchString, MaxLength: 200},
+	"location.postalCode": {Kind: patchString, MaxLength: 20},
+	"location.lat":        {Kind: patchNumber, Min: -90, Max: 90},
+	"location.lng":        {Kind: patchNumber, Min: -180, Max: 180},
+	"location.allowed":    {Kind: patchBool},
+}
+
+// rolePatchFields are the extra fields each user type may change. Branches, logos and photos
+// have their own endpoints and are never reachable through a profile patch.
+var rolePatchFields = map[string]map[string]patchField{
+	"company": {
+		"companyInfo.name":           {Kind: patchString, Required: true, MaxLength: 100},
+		"companyInfo.Category":       {Kind: patchString, Required: true, MaxLength: 64},
+		"companyInfo.customCategory": {Kind: patchString, MaxLength: 64},
+		"companyInfo.subCategory":    {Kind: patchString, MaxLength: 64},
+	},
+	"wholesaler": {
+		"wholesalerInfo.businessName": {Kind: patchString, Required: true, MaxLength: 100},
+		"wholesalerInfo.Category":     {Kind: patchString, Required: true, MaxLength: 64},
+	},
+	"serviceProvider": {
+		"serviceProviderInfo.serviceType":       {Kind: patchString, Required: true, MaxLength: 64},
+		"serviceProviderInfo.customServiceType": {Kind: patchString, MaxLength: 64},
+		"serviceProviderInfo.yearsExperience":   {Kind: patchInt, Min: 0, Max: 80},
+	},
+}
+
+// clearablePatchObjects may be removed as a whole by patching them with null
+var clearablePatchObjects = map[string]bool{
+	"location": true,
+}
+
+// profilePatchFields returns the fields the user type may patch
+func profilePatchFields(userType string) map[string]patchField {
+	fields := make(map[string]patchField, len(basePatchFields))
+	for path, field := range basePatchFields {
+		fields[path] = field
+	}
+	for path, field := range rolePatchFields[userType] {
+		fields[path] = field
+	}
+	return fields
+}
+
+// profilePatch is a JSON Merge Patch (RFC 7396) translated into update operators
+type profilePatch struct {
+	Set   bson.M
+	Unset bson.M
+}
+
+// Empty reports whether the patch changes nothing
+func (p *profilePatch) Empty() bool {
+	return len(p.Set) == 0 && len(p.Unset) == 0
+}
+
+// Paths returns every changed path; unset paths map to nil, for diffing against the stored document
+func (p *profilePatch) Paths() bson.M {
+	paths := bson.M{}
+	for path, value := range p.Set {
+		paths[path] = value
+	}
+	for path := range p.Unset {
+		paths[path] = nil
+	}
+	return paths
+}
+
+// parseProfilePatch decodes a merge patch body and checks it against the allowed fields.
+// Nested objects are merged field by field, so patching companyInfo.name leaves the rest of
+// companyInfo untouched; null removes a field.
+func parseProfilePatch(body []byte, fields map[string]patchField) (*profilePatch, []models.FieldError, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil || doc == nil || decoder.More() {
+		return nil, nil, fmt.Errorf("request body must be a JSON object")
+	}
+
+	patch := &profilePatch{Set: bson.M{}, Unset: bson.M{}}
+	var fieldErrors []models.FieldError
+	flattenProfilePatch("", doc, fields, patch, &fieldErrors)
+	return patch, fieldErrors, nil
+}
+
+// flattenProfilePatch walks one level of the patch document
+func flattenProfilePatch(prefix string, doc map[string]interface{}, fields map[string]patchField, patch *profilePatch, fieldErrors *[]models.FieldError) {
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		value := doc[key]
+
+		if _, isLeaf := fields[path]; !isLeaf && hasPatchChildren(fields, path) {
+			switch nested := value.(type) {
+			case map[string]interface{}:
+				flattenProfilePatch(path, nested, fields, patch, fieldErrors)
+			case nil:
+				if clearablePatchObjects[path] {
+					patch.Unset[path] = ""
+				} else {
+					*fieldErrors = append(*fieldErrors, models.FieldError{Field: path, Code: "required", Message: "This field cannot be removed"})
+				}
+			default:
+				*fieldErrors = append(*fieldErrors, models.FieldError{Field: path, Code: "invalid_type", Message: "Must be an object"})
+			}
+			continue
+		}
+
+		field, ok := fields[path]
+		if !ok {
+			*fieldErrors = append(*fieldErrors, models.FieldError{Field: path, Code: "not_allowed", Message: "This field cannot be updated"})
+			continue
+		}
+
+		if value == nil {
+			if field.Required {
+				*fieldErrors = append(*fieldErrors, models.FieldError{Field: path, Code: "required", Message: "This field cannot be removed"})
+				continue
+			}
+			patch.Unset[path] = ""
+			continue
+		}
+
+		converted, fieldError := field.convert(path, value)
+		if fieldError != nil {
+			*fieldErrors = append(*fieldErrors, *fieldError)
+			continue
+		}
+		patch.Set[path] = converted
+	}
+}
+
+// hasPatchChildren reports whether any allowed field lies below path
+func hasPatchChildren(fields map[string]patchField, path string) bool {
+	for candidate := range fields {
+		if strings.HasPrefix(candidate, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// convert checks a JSON value against the field and returns the value to store
+func (f patchField) convert(path string, value interface{}) (interface{}, *models.FieldError) {
+	invalid := func(code, message string) (interface{}, *models.FieldError) {
+		return nil, &models.FieldError{Field: path, Code: code, Message: message}
+	}
+
+	switch f.Kind {
+	case patchString, patchDate:
+		s, ok := value.(string)
+		if !ok {
+			return invalid("invalid_type", "Must be a string")
+		}
+		s = strings.TrimSpace(s)
+		if s == "" && f.Required {
+			return invalid("required", "This field cannot be empty")
+		}
+		if f.MaxLength > 0 && len(s) > f.MaxLength {
+			return invalid("too_long", fmt.Sprintf("Must be at most %d characters", f.MaxLength))
+		}
+		if f.Kind == patchDate && s != "" {
+			date, err := time.Parse("2006-01-02", s)
+			if err != nil {
+				return invalid("invalid_format", "Must be a date in YYYY-MM-DD format")
+			}
+			if date.After(time.Now()) {
+				return invalid("invalid_value", "Must be in the past")
+			}
+		}
+		return s, nil
+
+	case patchNumber, patchInt:
+		number, ok := value.(json.Number)
+		if !ok {
+			return invalid("invalid_type", "Must be a number")
+		}
+		n, err := number.Float64()
+		if err != nil || math.IsInf(n, 0) {
+			return invalid("invalid_type", "Must be a number")
+		}
+		if f.Kind == patchInt && n != math.Trunc(n) {
+			return invalid("invalid_type", "Must be a whole number")
+		}
+		if n < f.Min || n > f.Max {
+			return invalid("out_of_range", fmt.Sprintf("Must be between %g and %g", f.Min, f.Max))
+		}
+		if f.Kind == patchInt {
+			return int(n), nil
+		}
+		return n, nil
+
+	case patchBool:
+		b, ok := value.(bool)
+		if !ok {
+			return invalid("invalid_type", "Must be true or false")
+		}
+		return b, nil
+	}
+	return invalid("not_allowed", "This field cannot be updated")
+}
+
+// validateProfileRules checks rules spanning several fields against the profile as it will be
+// after the patch: choosing "Other" requires the matching custom value.
+func validateProfileRules(user *models.User, patch *profilePatch) []models.FieldError {
+	var fieldErrors []models.FieldError
+
+	var category, customCategory string
+	if user.CompanyInfo != nil {
+		category, customCategory = user.CompanyInfo.Category, user.CompanyInfo.CustomCategory
+	}
+	category = patchedString(patch, "companyInfo.Category", category)
+	customCategory = patchedString(patch, "companyInfo.customCategory", customCategory)
+	if category == "Other" && customCategory == "" {
+		fieldErrors = append(fieldErrors, models.FieldError{
+			Field:   "companyInfo.customCategory",
+			Code:    "required",
+			Message: "Please specify your Category type",
+		})
+	}
+
+	var serviceType, customServiceType string
+	if user.ServiceProviderInfo != nil {
+		serviceType, customServiceType = user.ServiceProviderInfo.ServiceType, user.ServiceProviderInfo.CustomServiceType
+	}
+	serviceType = patchedString(patch, "serviceProviderInfo.serviceType", serviceType)
+	customServiceType = patchedString(patch, "serviceProviderInfo.customServiceType", customServiceType)
+	if serviceType == "Other" && customServiceType == "" {
+		fieldErrors = append(fieldErrors, models.FieldError{
+			Field:   "serviceProviderInfo.customServiceType",
+			Code:    "required",
+			Message: "Please specify your service type",
+		})
+	}
+
+	return fieldErrors
+}
+
+// patchedString returns the value a string field will have after the patch
+func patchedString(patch *profilePatch, path, current string) string {
+	if value, ok := patch.Set[path].(string); ok {
+		return value
+	}
+	if _, ok := patch.Unset[path]; ok {
+		return ""
+	}
+	return current
+}
@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HSouheill/barrim_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestProfilePatchFields(t *testing.T) {
+	tests := []struct {
+		userType string
+		path     string
+		want     bool
+	}{
+		{"user", "fullName", true},
+		{"user", "location.city", true},
+		{"user", "companyInfo.name", false},
+		{"user", "email", false},
+		{"user", "password", false},
+		{"user", "userType", false},
+		{"company", "fullName", true},
+		{"company", "companyInfo.name", true},
+		{"company", "companyInfo.branches", false},
+		{"company", "companyInfo.logo", false},
+		{"company", "serviceProviderInfo.serviceType", false},
+		{"wholesaler", "wholesalerInfo.businessName", true},
+		{"wholesaler", "companyInfo.name", false},
+		{"serviceProvider", "serviceProviderInfo.yearsExperience", true},
+		{"serviceProvider", "serviceProviderInfo.profilePhoto", false},
+		{"admin", "fullName", true},
+		{"admin", "companyInfo.name", false},
+	}
+	for _, tt := range tests {
+		_, got := profilePatchFields(tt.userType)[tt.path]
+		if got != tt.want {
+			t.Errorf("profilePatchFields(%q) allows %q = %v, want %v", tt.userType, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseProfilePatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		userType   string
+		body       string
+		wantSet    bson.M
+		wantUnset  bson.M
+		wantErrors []models.FieldError
+	}{
+		{
+			name:      "top-level string is trimmed",
+			userType:  "user",
+			body:      `{"fullName": "  Jane Doe  "}`,
+			wantSet:   bson.M{"fullName": "Jane Doe"},
+			wantUnset: bson.M{},
+		},
+		{
+			name:      "nested fields are merged by path",
+			userType:  "company",
+			body:      `{"companyInfo": {"name": "Acme", "subCategory": null}}`,
+			wantSet:   bson.M{"companyInfo.name": "Acme"},
+			wantUnset: bson.M{"companyInfo.subCategory": ""},
+		},
+		{
+			name:      "numbers and booleans",
+			userType:  "serviceProvider",
+			body:      `{"location": {"lat": 33.9, "lng": 35.5, "allowed": true}, "serviceProviderInfo": {"yearsExperience": 5}}`,
+			wantSet:   bson.M{"location.lat": 33.9, "location.lng": 35.5, "location.allowed": true, "serviceProviderInfo.yearsExperience": 5},
+			wantUnset: bson.M{},
+		},
+		{
+			name:      "clearable object removed with null",
+			userType:  "user",
+			body:      `{"location": null}`,
+			wantSet:   bson.M{},
+			wantUnset: bson.M{"location": ""},
+		},
+		{
+			name:      "optional field cleared with null",
+			userType:  "user",
+			body:      `{"gender": null, "dateOfBirth": "1990-05-01"}`,
+			wantSet:   bson.M{"dateOfBirth": "1990-05-01"},
+			wantUnset: bson.M{"gender": ""},
+		},
+		{
+			name:       "field outside the whitelist",
+			userType:   "user",
+			body:       `{"email": "a@b.c", "userType": "admin"}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "email", Code: "not_allowed", Message: "This field cannot be updated"}, {Field: "userType", Code: "not_allowed", Message: "This field cannot be updated"}},
+		},
+		{
+			name:       "field of another role",
+			userType:   "user",
+			body:       `{"companyInfo": {"name": "Acme"}}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "companyInfo", Code: "not_allowed", Message: "This field cannot be updated"}},
+		},
+		{
+			name:       "nested field outside the whitelist",
+			userType:   "company",
+			body:       `{"companyInfo": {"branches": []}}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "companyInfo.branches", Code: "not_allowed", Message: "This field cannot be updated"}},
+		},
+		{
+			name:       "required field cleared",
+			userType:   "user",
+			body:       `{"fullName": null}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "fullName", Code: "required", Message: "This field cannot be removed"}},
+		},
+		{
+			name:       "required field emptied",
+			userType:   "user",
+			body:       `{"fullName": "   "}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "fullName", Code: "required", Message: "This field cannot be empty"}},
+		},
+		{
+			name:       "object that cannot be removed",
+			userType:   "company",
+			body:       `{"companyInfo": null}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "companyInfo", Code: "required", Message: "This field cannot be removed"}},
+		},
+		{
+			name:       "scalar in place of an object",
+			userType:   "user",
+			body:       `{"location": "Beirut"}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "location", Code: "invalid_type", Message: "Must be an object"}},
+		},
+		{
+			name:       "wrong value types",
+			userType:   "user",
+			body:       `{"fullName": 5, "location": {"lat": "33", "allowed": "yes"}}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "fullName", Code: "invalid_type", Message: "Must be a string"}, {Field: "location.allowed", Code: "invalid_type", Message: "Must be true or false"}, {Field: "location.lat", Code: "invalid_type", Message: "Must be a number"}},
+		},
+		{
+			name:       "string too long",
+			userType:   "user",
+			body:       `{"fullName": "` + strings.Repeat("a", 101) + `"}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "fullName", Code: "too_long", Message: "Must be at most 100 characters"}},
+		},
+		{
+			name:       "invalid date",
+			userType:   "user",
+			body:       `{"dateOfBirth": "01/05/1990"}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "dateOfBirth", Code: "invalid_format", Message: "Must be a date in YYYY-MM-DD format"}},
+		},
+		{
+			name:       "date in the future",
+			userType:   "user",
+			body:       `{"dateOfBirth": "2999-01-01"}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "dateOfBirth", Code: "invalid_value", Message: "Must be in the past"}},
+		},
+		{
+			name:       "number out of range",
+			userType:   "user",
+			body:       `{"location": {"lat": 91}}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "location.lat", Code: "out_of_range", Message: "Must be between -90 and 90"}},
+		},
+		{
+			name:       "fractional whole number",
+			userType:   "serviceProvider",
+			body:       `{"serviceProviderInfo": {"yearsExperience": 2.5}}`,
+			wantSet:    bson.M{},
+			wantUnset:  bson.M{},
+			wantErrors: []models.FieldError{{Field: "serviceProviderInfo.yearsExperience", Code: "invalid_type", Message: "Must be a whole number"}},
+		},
+		{
+			name:      "empty patch",
+			userType:  "user",
+			body:      `{}`,
+			wantSet:   bson.M{},
+			wantUnset: bson.M{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, fieldErrors, err := parseProfilePatch([]byte(tt.body), profilePatchFields(tt.userType))
+			if err != nil {
+				t.Fatalf("parseProfilePatch() error = %v", err)
+			}
+			if !reflect.DeepEqual(fieldErrors, tt.wantErrors) {
+				t.Errorf("field errors = %+v, want %+v", fieldErrors, tt.wantErrors)
+			}
+			if !reflect.DeepEqual(patch.Set, tt.wantSet) {
+				t.Errorf("Set = %v, want %v", patch.Set, tt.wantSet)
+			}
+			if !reflect.DeepEqual(patch.Unset, tt.wantUnset) {
+				t.Errorf("Unset = %v, want %v", patch.Unset, tt.wantUnset)
+			}
+		})
+	}
+}
+
+func TestParseProfilePatchRejectsNonObjects(t *testing.T) {
+	for _, body := range []string{``, `null`, `[]`, `"name"`, `{"fullName": "a"} {}`, `{"fullName":`} {
+		if _, _, err := parseProfilePatch([]byte(body), profilePatchFields("user")); err == nil {
+			t.Errorf("parseProfilePatch(%q) accepted a body that is not a single JSON object", body)
+		}
+	}
+}
+
+func TestProfilePatchPaths(t *testing.T) {
+	patch := &profilePatch{Set: bson.M{"fullName": "Jane"}, Unset: bson.M{"gender": ""}}
+	want := bson.M{"fullName": "Jane", "gender": nil}
+	if got := patch.Paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Paths() = %v, want %v", got, want)
+	}
+	if patch.Empty() {
+		t.Error("Empty() = true for a patch with changes")
+	}
+	if !(&profilePatch{Set: bson.M{}, Unset: bson.M{}}).Empty() {
+		t.Error("Empty() = false for a patch without changes")
+	}
+}
+
+func TestValidateProfileRules(t *testing.T) {
+	company := &models.User{CompanyInfo: &models.CompanyInfo{Category: "Food"}}
+	otherCompany := &models.User{CompanyInfo: &models.CompanyInfo{Category: "Other", CustomCategory: "Crafts"}}
+	provider := &models.User{ServiceProviderInfo: &models.ServiceProviderInfo{ServiceType: "Plumber"}}
+	otherProvider := &models.User{ServiceProviderInfo: &models.ServiceProviderInfo{ServiceType: "Other", CustomServiceType: "Tiling"}}
+
+	missingCategory := []models.FieldError{{Field: "companyInfo.customCategory", Code: "required", Message: "Please specify your Category type"}}
+	missingServiceType := []models.FieldError{{Field: "serviceProviderInfo.customServiceType", Code: "required", Message: "Please specify your service type"}}
+
+	tests := []struct {
+		name  string
+		user  *models.User
+		set   bson.M
+		unset bson.M
+		want  []models.FieldError
+	}{
+		{"unrelated change", company, bson.M{"fullName": "Jane"}, bson.M{}, nil},
+		{"other without custom category", company, bson.M{"companyInfo.Category": "Other"}, bson.M{}, missingCategory},
+		{"other with custom category", company, bson.M{"companyInfo.Category": "Other", "companyInfo.customCategory": "Crafts"}, bson.M{}, nil},
+		{"custom category already stored", company, bson.M{}, bson.M{}, nil},
+		{"stored other keeps custom category", otherCompany, bson.M{"companyInfo.name": "Acme"}, bson.M{}, nil},
+		{"custom category removed", otherCompany, bson.M{}, bson.M{"companyInfo.customCategory": ""}, missingCategory},
+		{"custom category emptied", otherCompany, bson.M{"companyInfo.customCategory": ""}, bson.M{}, missingCategory},
+		{"leaving other", otherCompany, bson.M{"companyInfo.Category": "Food"}, bson.M{"companyInfo.customCategory": ""}, nil},
+		{"other without custom service type", provider, bson.M{"serviceProviderInfo.serviceType": "Other"}, bson.M{}, missingServiceType},
+		{"other with custom service type", provider, bson.M{"serviceProviderInfo.serviceType": "Other", "serviceProviderInfo.customServiceType": "Tiling"}, bson.M{}, nil},
+		{"custom service type removed", otherProvider, bson.M{}, bson.M{"serviceProviderInfo.customServiceType": ""}, missingServiceType},
+		{"account without role info", &models.User{}, bson.M{"fullName": "Jane"}, bson.M{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateProfileRules(tt.user, &profilePatch{Set: tt.set, Unset: tt.unset})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateProfileRules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// controllers/sessions.go
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/middleware"
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+// newDeviceAlertsEnabled reports whether accounts of the type are emailed about logins from new devices.
+// The policy is read from NEW_DEVICE_ALERTS_FOR.
+func newDeviceAlertsEnabled(userType string) bool {
+	for _, t := range config.GetEnvList("NEW_DEVICE_ALERTS_FOR", []string{"company", "wholesaler", "serviceProvider"}) {
+		if t == userType {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSessions lists the devices the current user is logged in on
+func (ac *AuthController) GetSessions(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	sessions, err := middleware.ListUserSessions(ctx, ac.DB, userID)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to retrieve sessions").Wrap(err)
+	}
+
+	summaries := make([]models.SessionSummary, 0, len(sessions))
+	for _, session := range sessions {
+		summaries = append(summaries, models.SessionSummary{
+			ID:           session.ID,
+			Device:       session.Device,
+			IP:           session.IP,
+			LastSeenAt:   session.LastSeenAt,
+			CreatedAt:    session.CreatedAt,
+			Current:      session.ID.Hex() == principal.SessionID,
+			Impersonated: !session.ImpersonatorID.IsZero(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Sessions retrieved successfully",
+		Data:    summaries,
+	})
+}
+
+// RevokeUserSession logs the current user out of one of their sessions
+func (ac *AuthController) RevokeUserSession(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return models.ErrInvalidSessionID
+	}
+
+	// Filtering on the owner means another user's session reads as not found
+	if err := middleware.RevokeSession(ctx, ac.DB, userID, sessionID); err != nil {
+		if err == middleware.ErrSessionNotFound {
+			return models.ErrSessionNotFound
+		}
+		return models.ErrInternal.WithMessage("Failed to revoke session").Wrap(err)
+	}
+
+	recordAudit(c, ac.DB, models.AuditEvent{
+		Action:     models.AuditSessionRevoked,
+		TargetType: "session",
+		TargetID:   sessionID.Hex(),
+	})
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Session revoked successfully",
+	})
+}
@@ -0,0 +1,33 @@
+// controllers/sms.go
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// defaultPhoneCountryCode is the country code assumed for numbers entered without one
+func defaultPhoneCountryCode() string {
+	return config.GetEnv("DEFAULT_PHONE_COUNTRY_CODE", "961")
+}
+
+// phoneVerificationTTL is how long an SMS verification code stays valid
+func phoneVerificationTTL() time.Duration {
+	return config.GetEnvDuration("PHONE_VERIFICATION_TTL", 10*time.Minute)
+}
+
+// sendPhoneVerificationSMS sends the code confirming ownership of a phone number
+func sendPhoneVerificationSMS(ctx context.Context, sender utils.SMSSender, phone, code string) error {
+	message := fmt.Sprintf("Your Barrim verification code is %s. It expires in %s.", code, formatDuration(phoneVerificationTTL()))
+	return sender.Send(ctx, phone, message)
+}
+
+// sendOTPBySMS sends the password reset OTP to the user's verified phone
+func sendOTPBySMS(ctx context.Context, sender utils.SMSSender, phone, otp string) error {
+	message := fmt.Sprintf("Your Barrim password reset code is %s. It expires in 15 minutes. If you did not request it, ignore this message.", otp)
+	return sender.Send(ctx, phone, message)
+}
@@ -0,0 +1,402 @@
+// controllers/two_factor.go
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/middleware"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// recoveryCodeCount is how many recovery codes are issued at enrollment
+const recoveryCodeCount = 10
+
+// totpIssuer is the account issuer shown in authenticator apps
+func totpIssuer() string {
+	return config.GetEnv("TOTP_ISSUER", "Barrim")
+}
+
+// VerifyTwoFactor completes a login by exchanging a challenge token and a TOTP or recovery code for a session
+func (ac *AuthController) VerifyTwoFactor(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var verifyReq models.TwoFactorLoginRequest
+	if err := c.Bind(&verifyReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	userIDHex, err := middleware.ParseTwoFactorChallenge(verifyReq.ChallengeToken)
+	if err != nil {
+		return models.ErrTwoFactorChallenge
+	}
+
+	// Codes are short, so guesses count against the account and the client IP
+	accountKey := twoFactorKey(userIDHex)
+	ipKey := loginIPKey(c.RealIP())
+	lockedUntil, err := checkLockout(ctx, ac.DB, accountKey, ipKey)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to check login attempts").Wrap(err)
+	}
+	if !lockedUntil.IsZero() {
+		return tooManyRequests(c, lockedUntil, "Too many invalid codes. Please try again later")
+	}
+
+	user, err := ac.findUserByHex(ctx, userIDHex)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrTwoFactorChallenge
+		}
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+	}
+
+	if user.Suspended {
+		return models.ErrAccountSuspended
+	}
+	if user.PendingDeletion() {
+		return pendingDeletionError(user)
+	}
+
+	if user.TwoFactor == nil || !user.TwoFactor.Enabled {
+		return models.ErrTwoFactorNotEnabled
+	}
+
+	valid, err := ac.consumeTwoFactorCode(ctx, user, verifyReq.Code)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to verify code").Wrap(err)
+	}
+	if !valid {
+		ac.recordLoginFailure(ctx, accountKey, ipKey)
+		event := auditUser(models.AuditLoginFailed, user)
+		event.Metadata = map[string]interface{}{"reason": "invalid_2fa_code"}
+		recordAudit(c, ac.DB, event)
+		return models.ErrTwoFactorCodeInvalid
+	}
+
+	if err := clearAttempts(ctx, ac.DB, accountKey); err != nil {
+		log.Printf("Failed to clear two-factor attempts for %s: %v", userIDHex, err)
+	}
+
+	return ac.startSession(ctx, c, user)
+}
+
+// SetupTwoFactor generates a new TOTP secret awaiting confirmation
+func (ac *AuthController) SetupTwoFactor(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := ac.findUserByHex(ctx, middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrUserNotFound
+	}
+
+	if user.TwoFactor != nil && user.TwoFactor.Enabled {
+		return models.ErrTwoFactorEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate secret").Wrap(err)
+	}
+
+	_, err = config.GetCollection(ac.DB, "users").UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{
+			"twoFactor.enabled":       false,
+			"twoFactor.pendingSecret": secret,
+			"updatedAt":               time.Now(),
+		}},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to start two-factor setup").Wrap(err)
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Scan the QR code with your authenticator app, then confirm with a code",
+		Data: map[string]interface{}{
+			"secret":     secret,
+			"otpauthUri": utils.TOTPProvisioningURI(secret, totpIssuer(), user.Email),
+		},
+	})
+}
+
+// EnableTwoFactor confirms the pending secret with a first code and returns the recovery codes
+func (ac *AuthController) EnableTwoFactor(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var codeReq models.TwoFactorCodeRequest
+	if err := c.Bind(&codeReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	user, err := ac.findUserByHex(ctx, middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrUserNotFound
+	}
+
+	if user.TwoFactor == nil || user.TwoFactor.PendingSecret == "" {
+		return models.ErrTwoFactorNotStarted
+	}
+
+	step, ok := utils.ValidateTOTP(user.TwoFactor.PendingSecret, codeReq.Code, time.Now())
+	if !ok {
+		return models.ErrOTPInvalid.WithMessage("Invalid authentication code")
+	}
+
+	recoveryCodes, hashes, err := generateHashedRecoveryCodes()
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate recovery codes").Wrap(err)
+	}
+
+	now := time.Now()
+	_, err = config.GetCollection(ac.DB, "users").UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "twoFactor.pendingSecret": user.TwoFactor.PendingSecret},
+		bson.M{"$set": bson.M{
+			"twoFactor": models.TwoFactorInfo{
+				Enabled:       true,
+				Secret:        user.TwoFactor.PendingSecret,
+				RecoveryCodes: hashes,
+				LastUsedStep:  step,
+				EnabledAt:     &now,
+			},
+			"updatedAt": now,
+		}},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to enable two-factor authentication").Wrap(err)
+	}
+
+	recordAudit(c, ac.DB, auditUser(models.AuditTwoFactorEnabled, user))
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Two-factor authentication enabled. Store your recovery codes somewhere safe",
+		Data: map[string]interface{}{
+			"recoveryCodes": recoveryCodes,
+		},
+	})
+}
+
+// DisableTwoFactor turns two-factor authentication off unless the account's type requires it
+func (ac *AuthController) DisableTwoFactor(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var disableReq models.TwoFactorDisableRequest
+	if err := c.Bind(&disableReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	user, err := ac.findUserByHex(ctx, middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrUserNotFound
+	}
+
+	if user.TwoFactor == nil || !user.TwoFactor.Enabled {
+		return models.ErrTwoFactorNotEnabled
+	}
+
+	policy, err := middleware.LoadTwoFactorPolicy(ctx, ac.DB)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to load two-factor policy").Wrap(err)
+	}
+	if policy.Requires(user.UserType) {
+		return models.ErrTwoFactorRequired
+	}
+
+	// Accounts created through Google have no password to confirm
+	if user.Password != "" {
+		if err := utils.CheckPassword(disableReq.Password, user.Password); err != nil {
+			return models.ErrInvalidPassword
+		}
+	}
+
+	if resp := ac.requireTwoFactorCode(ctx, c, user, disableReq.Code); resp != nil {
+		return resp
+	}
+
+	_, err = config.GetCollection(ac.DB, "users").UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID},
+		bson.M{
+			"$set":   bson.M{"updatedAt": time.Now()},
+			"$unset": bson.M{"twoFactor": ""},
+		},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to disable two-factor authentication").Wrap(err)
+	}
+
+	recordAudit(c, ac.DB, auditUser(models.AuditTwoFactorDisabled, user))
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after a valid code is presented
+func (ac *AuthController) RegenerateRecoveryCodes(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var codeReq models.TwoFactorCodeRequest
+	if err := c.Bind(&codeReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	user, err := ac.findUserByHex(ctx, middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrUserNotFound
+	}
+
+	if user.TwoFactor == nil || !user.TwoFactor.Enabled {
+		return models.ErrTwoFactorNotEnabled
+	}
+
+	if resp := ac.requireTwoFactorCode(ctx, c, user, codeReq.Code); resp != nil {
+		return resp
+	}
+
+	recoveryCodes, hashes, err := generateHashedRecoveryCodes()
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate recovery codes").Wrap(err)
+	}
+
+	_, err = config.GetCollection(ac.DB, "users").UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"twoFactor.recoveryCodes": hashes, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to save recovery codes").Wrap(err)
+	}
+
+	recordAudit(c, ac.DB, auditUser(models.AuditRecoveryCodesReset, user))
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Recovery codes regenerated. Previous codes no longer work",
+		Data: map[string]interface{}{
+			"recoveryCodes": recoveryCodes,
+		},
+	})
+}
+
+// requireTwoFactorCode checks a code for a sensitive 2FA change under the same lockout as login.
+// It returns a catalog error when the code is not accepted, and nil when it is.
+func (ac *AuthController) requireTwoFactorCode(ctx context.Context, c echo.Context, user *models.User, code string) error {
+	accountKey := twoFactorKey(user.ID.Hex())
+	lockedUntil, err := checkLockout(ctx, ac.DB, accountKey)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to check attempts").Wrap(err)
+	}
+	if !lockedUntil.IsZero() {
+		return tooManyRequests(c, lockedUntil, "Too many invalid codes. Please try again later")
+	}
+
+	valid, err := ac.consumeTwoFactorCode(ctx, user, code)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to verify code").Wrap(err)
+	}
+	if !valid {
+		if err := recordFailedAttempt(ctx, ac.DB, accountKey, loginAccountPolicy()); err != nil {
+			log.Printf("Failed to record two-factor attempt for %s: %v", accountKey, err)
+		}
+		return models.ErrTwoFactorCodeInvalid
+	}
+	return nil
+}
+
+// consumeTwoFactorCode accepts a TOTP code or a recovery code exactly once
+func (ac *AuthController) consumeTwoFactorCode(ctx context.Context, user *models.User, code string) (bool, error) {
+	collection := config.GetCollection(ac.DB, "users")
+	code = strings.TrimSpace(code)
+
+	if step, ok := utils.ValidateTOTP(user.TwoFactor.Secret, code, time.Now()); ok {
+		// Only a step later than the last accepted one counts, so a seen code cannot be replayed
+		result, err := collection.UpdateOne(
+			ctx,
+			bson.M{"_id": user.ID, "twoFactor.lastUsedStep": bson.M{"$lt": step}},
+			bson.M{"$set": bson.M{"twoFactor.lastUsedStep": step}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+
+	// Recovery codes are long enough to tell apart from TOTP codes
+	if len(code) == utils.TOTPDigits {
+		return false, nil
+	}
+	code = strings.ToLower(code)
+	for _, hash := range user.TwoFactor.RecoveryCodes {
+		if utils.CheckPassword(code, hash) != nil {
+			continue
+		}
+		// Pulling the matched hash makes the code single-use even under concurrent requests
+		result, err := collection.UpdateOne(
+			ctx,
+			bson.M{"_id": user.ID, "twoFactor.recoveryCodes": hash},
+			bson.M{"$pull": bson.M{"twoFactor.recoveryCodes": hash}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+	return false, nil
+}
+
+// findUserByHex loads a user by hex ID
+func (ac *AuthController) findUserByHex(ctx context.Context, userIDHex string) (*models.User, error) {
+	userID, err := primitive.ObjectIDFromHex(userIDHex)
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	var user models.User
+	if err := config.GetCollection(ac.DB, "users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// generateHashedRecoveryCodes returns fresh recovery codes and their bcrypt hashes
+func generateHashedRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hash, err := utils.HashPassword(code)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashes[i] = hash
+	}
+	return codes, hashes, nil
+}
@@ -4,8 +4,10 @@ package controllers
 import (
 	"context"
 	"io"
+	"log"
 	"math"
 	"net/http"
+	"net/mail"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -21,16 +23,18 @@ import (
 	"github.com/HSouheill/barrim_backend/config"
 	"github.com/HSouheill/barrim_backend/middleware"
 	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
 )
 
 // UserController contains user management logic
 type UserController struct {
-	DB *mongo.Client
+	DB  *mongo.Client
+	SMS utils.SMSSender
 }
 
 // NewUserController creates a new user controller
 func NewUserController(db *mongo.Client) *UserController {
-	return &UserController{DB: db}
+	return &UserController{DB: db, SMS: utils.NewSMSSenderFromEnv()}
 }
 
 // GetProfile handler gets the current user's profile
@@ -43,13 +47,10 @@ func (uc *UserController) GetProfile(c echo.Context) error {
 	collection := config.GetCollection(uc.DB, "users")
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
 	}
 
 	// Find user by ID
@@ -57,15 +58,9 @@ func (uc *UserController) GetProfile(c echo.Context) error {
 	err = collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, models.Response{
-				Status:  http.StatusNotFound,
-				Message: "User not found",
-			})
+			return models.ErrUserNotFound
 		}
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to find user",
-		})
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
 	}
 
 	// Remove password from response
@@ -89,30 +84,21 @@ func (uc *UserController) UpdateLocation(c echo.Context) error {
 	collection := config.GetCollection(uc.DB, "users")
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
 	}
 
 	// Parse request body
 	var locReq models.UpdateLocationRequest
 	if err := c.Bind(&locReq); err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid request body",
-		})
+		return models.ErrInvalidRequest
 	}
 
 	// Validate location
-	if locReq.Location == nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Location is required",
-		})
+	if err := c.Validate(&locReq); err != nil {
+		return validationError(err)
 	}
 
 	// Update user location
@@ -125,26 +111,29 @@ func (uc *UserController) UpdateLocation(c echo.Context) error {
 
 	result, err := collection.UpdateOne(ctx, bson.M{"_id": userID}, update)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to update location",
-		})
+		return models.ErrInternal.WithMessage("Failed to update location").Wrap(err)
 	}
 
 	if result.MatchedCount == 0 {
-		return c.JSON(http.StatusNotFound, models.Response{
-			Status:  http.StatusNotFound,
-			Message: "User not found",
-		})
+		return models.ErrUserNotFound
 	}
 
+	// Coordinates are not copied into the audit log
+	recordAudit(c, uc.DB, models.AuditEvent{
+		Action:     models.AuditLocationUpdated,
+		TargetType: "user",
+		TargetID:   userID.Hex(),
+	})
+
 	return c.JSON(http.StatusOK, models.Response{
 		Status:  http.StatusOK,
 		Message: "Location updated successfully",
 	})
 }
 
-// UpdateProfile handler updates a user's profile
+// UpdateProfile applies a JSON Merge Patch (RFC 7396) to the current user's profile.
+// Only the fields allowed for the user type can be changed, null clears a field, and the
+// updated profile is returned.
 func (uc *UserController) UpdateProfile(c echo.Context) error {
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -154,104 +143,81 @@ func (uc *UserController) UpdateProfile(c echo.Context) error {
 	collection := config.GetCollection(uc.DB, "users")
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
 	}
 
 	// Parse request body
-	var user models.User
-	if err := c.Bind(&user); err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid request body",
-		})
-	}
-
-	// Build update document
-	update := bson.M{
-		"$set": bson.M{
-			"updatedAt": time.Now(),
-		},
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxProfilePatchSize))
+	if err != nil {
+		return models.ErrInvalidRequest
 	}
 
-	// Only allow updating specific fields
-	if user.FullName != "" {
-		update["$set"].(bson.M)["fullname"] = user.FullName
+	var user models.User
+	if err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrUserNotFound
+		}
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
 	}
 
-	if user.Gender != "" {
-		update["$set"].(bson.M)["gender"] = user.Gender
+	patch, fieldErrors, err := parseProfilePatch(body, profilePatchFields(user.UserType))
+	if err != nil {
+		return models.ErrInvalidRequest.WithMessage("Request body must be a JSON object")
 	}
-
-	if user.DateOfBirth != "" {
-		update["$set"].(bson.M)["dateOfBirth"] = user.DateOfBirth
+	if len(fieldErrors) == 0 {
+		fieldErrors = validateProfileRules(&user, patch)
 	}
-
-	if user.CompanyInfo != nil {
-		update["$set"].(bson.M)["companyInfo"] = user.CompanyInfo
+	if len(fieldErrors) > 0 {
+		return models.ErrValidationFailed.WithMessage("Invalid profile update").WithErrors(fieldErrors)
 	}
 
-	if user.Location != nil {
-		update["$set"].(bson.M)["location"] = user.Location
-	}
-
-	if user.ServiceProviderInfo != nil {
-		// If service type is "Other", ensure custom service type is provided
-		if user.ServiceProviderInfo.ServiceType == "Other" &&
-			user.ServiceProviderInfo.CustomServiceType == "" {
-			return c.JSON(http.StatusBadRequest, models.Response{
-				Status:  http.StatusBadRequest,
-				Message: "Please specify your service type",
-			})
+	if !patch.Empty() {
+		update := bson.M{"$set": bson.M{"updatedAt": time.Now()}}
+		for path, value := range patch.Set {
+			update["$set"].(bson.M)[path] = value
 		}
-
-		update["$set"].(bson.M)["serviceProviderInfo"] = user.ServiceProviderInfo
-	}
-
-	if user.CompanyInfo != nil {
-		// If updating Category type to "Other", ensure customCategory is provided
-		if user.CompanyInfo.Category == "Other" && user.CompanyInfo.Category == "" {
-			return c.JSON(http.StatusBadRequest, models.Response{
-				Status:  http.StatusBadRequest,
-				Message: "Please specify your Category type",
-			})
+		if len(patch.Unset) > 0 {
+			update["$unset"] = patch.Unset
 		}
 
-		update["$set"].(bson.M)["companyInfo"] = user.CompanyInfo
-	}
+		// Update user
+		changed := patch.Paths()
+		before := auditSnapshot(ctx, collection, bson.M{"_id": userID}, changed)
+		result, err := collection.UpdateOne(ctx, bson.M{"_id": userID}, update)
+		if err != nil {
+			return models.ErrInternal.WithMessage("Failed to update profile").Wrap(err)
+		}
 
-	if user.Location != nil {
-		update["$set"].(bson.M)["location"] = user.Location
-	}
+		if result.MatchedCount == 0 {
+			return models.ErrUserNotFound
+		}
 
-	// Update user
-	result, err := collection.UpdateOne(ctx, bson.M{"_id": userID}, update)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to update profile",
+		recordAudit(c, uc.DB, models.AuditEvent{
+			Action:     models.AuditProfileUpdated,
+			TargetType: "user",
+			TargetID:   userID.Hex(),
+			Changes:    auditChanges(before, changed),
 		})
-	}
 
-	if result.MatchedCount == 0 {
-		return c.JSON(http.StatusNotFound, models.Response{
-			Status:  http.StatusNotFound,
-			Message: "User not found",
-		})
+		if err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+			return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+		}
 	}
 
+	// Remove password from response
+	user.Password = ""
+
 	return c.JSON(http.StatusOK, models.Response{
 		Status:  http.StatusOK,
 		Message: "Profile updated successfully",
+		Data:    user,
 	})
 }
 
-// DeleteUser handler deletes the current user
+// DeleteUser handler schedules the current user for deletion after a grace period
 func (uc *UserController) DeleteUser(c echo.Context) error {
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -261,34 +227,50 @@ func (uc *UserController) DeleteUser(c echo.Context) error {
 	collection := config.GetCollection(uc.DB, "users")
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
+	}
+
+	// The account is only marked; a background job purges it and its files after the grace period
+	now := time.Now()
+	scheduledAt := now.Add(accountDeletionGracePeriod())
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "deletionScheduledAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"deletionRequestedAt": now,
+			"deletionScheduledAt": scheduledAt,
+			"updatedAt":           now,
+		}},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to delete user").Wrap(err)
 	}
 
-	// Delete user
-	result, err := collection.DeleteOne(ctx, bson.M{"_id": userID})
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to delete user",
-		})
+	if result.MatchedCount == 0 {
+		return models.ErrUserNotFound
 	}
 
-	if result.DeletedCount == 0 {
-		return c.JSON(http.StatusNotFound, models.Response{
-			Status:  http.StatusNotFound,
-			Message: "User not found",
-		})
+	// Every session ends now, including the one making the request
+	if err := middleware.RevokeUserSessions(ctx, uc.DB, userID, primitive.NilObjectID); err != nil {
+		log.Printf("Failed to revoke sessions of deleted user %s: %v", userID.Hex(), err)
 	}
 
+	recordAudit(c, uc.DB, models.AuditEvent{
+		Action:     models.AuditAccountDeleted,
+		TargetType: "user",
+		TargetID:   userID.Hex(),
+		Metadata:   map[string]interface{}{"deletionScheduledAt": scheduledAt},
+	})
+
 	return c.JSON(http.StatusOK, models.Response{
 		Status:  http.StatusOK,
-		Message: "User deleted successfully",
+		Message: "Account scheduled for deletion. It can be restored until the deletion date",
+		Data: map[string]interface{}{
+			"deletionScheduledAt": scheduledAt,
+		},
 	})
 }
 
@@ -314,7 +296,7 @@ func (uc *UserController) GetAllUsers(c echo.Context) error {
 
 	// Set up options to exclude password field and apply pagination
 	opts := options.Find().
-		SetProjection(bson.M{"password": 0}).
+		SetProjection(userSecretsProjection).
 		SetSkip(int64(skip)).
 		SetLimit(int64(limit)).
 		SetSort(bson.D{{Key: "createdAt", Value: -1}}) // Sort by creation date, newest first
@@ -322,29 +304,20 @@ func (uc *UserController) GetAllUsers(c echo.Context) error {
 	// Find all users
 	cursor, err := collection.Find(ctx, bson.M{}, opts)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to fetch users",
-		})
+		return models.ErrInternal.WithMessage("Failed to fetch users").Wrap(err)
 	}
 	defer cursor.Close(ctx)
 
 	// Decode all users
-	var users []models.User
+	users := []models.User{}
 	if err := cursor.All(ctx, &users); err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to decode users",
-		})
+		return models.ErrInternal.WithMessage("Failed to decode users").Wrap(err)
 	}
 
 	// Get total count for pagination info
 	totalCount, err := collection.CountDocuments(ctx, bson.M{})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to count users",
-		})
+		return models.ErrInternal.WithMessage("Failed to count users").Wrap(err)
 	}
 
 	// Calculate pagination metadata
@@ -373,13 +346,10 @@ func (uc *UserController) UploadCompanyLogo(c echo.Context) error {
 	defer cancel()
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
 	}
 
 	// Get user collection
@@ -389,43 +359,28 @@ func (uc *UserController) UploadCompanyLogo(c echo.Context) error {
 	var user models.User
 	err = collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, models.Response{
-			Status:  http.StatusNotFound,
-			Message: "User not found",
-		})
+		return models.ErrUserNotFound
 	}
 
-	if user.UserType != "company" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Only company accounts can upload a logo",
-		})
+	if !middleware.Authorize(c, middleware.PermCompanyLogoWrite) {
+		return models.ErrPermissionDenied.WithMessage("Only company accounts can upload a logo")
 	}
 
 	// Get file from form
 	file, err := c.FormFile("logo")
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "No file uploaded or invalid file",
-		})
+		return models.ErrFileRequired
 	}
 
 	// Validate file type (only images allowed)
 	if !strings.HasPrefix(file.Header.Get("Content-Type"), "image/") {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Only image files are allowed",
-		})
+		return models.ErrUnsupportedFile
 	}
 
 	// Open the file
 	src, err := file.Open()
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to open uploaded file",
-		})
+		return models.ErrInternal.WithMessage("Failed to open uploaded file").Wrap(err)
 	}
 	defer src.Close()
 
@@ -439,19 +394,13 @@ func (uc *UserController) UploadCompanyLogo(c echo.Context) error {
 	// Create destination file
 	dst, err := os.Create(storagePath)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to create destination file",
-		})
+		return models.ErrInternal.WithMessage("Failed to create destination file").Wrap(err)
 	}
 	defer dst.Close()
 
 	// Copy the uploaded file to the destination file
 	if _, err = io.Copy(dst, src); err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to save uploaded file",
-		})
+		return models.ErrInternal.WithMessage("Failed to save uploaded file").Wrap(err)
 	}
 
 	// Update the user's companyInfo with the logo path
@@ -467,10 +416,7 @@ func (uc *UserController) UploadCompanyLogo(c echo.Context) error {
 
 	_, err = collection.UpdateOne(ctx, bson.M{"_id": userID}, update)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to update company logo",
-		})
+		return models.ErrInternal.WithMessage("Failed to update company logo").Wrap(err)
 	}
 
 	return c.JSON(http.StatusOK, models.Response{
@@ -489,13 +435,10 @@ func (uc *UserController) UploadProfilePhoto(c echo.Context) error {
 	defer cancel()
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
 	}
 
 	// Get user collection
@@ -505,43 +448,28 @@ func (uc *UserController) UploadProfilePhoto(c echo.Context) error {
 	var user models.User
 	err = collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, models.Response{
-			Status:  http.StatusNotFound,
-			Message: "User not found",
-		})
+		return models.ErrUserNotFound
 	}
 
-	if user.UserType != "serviceProvider" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Only service providers can upload a profile photo",
-		})
+	if !middleware.Authorize(c, middleware.PermProviderPhotoWrite) {
+		return models.ErrPermissionDenied.WithMessage("Only service providers can upload a profile photo")
 	}
 
 	// Get file from form
 	file, err := c.FormFile("photo")
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "No file uploaded or invalid file",
-		})
+		return models.ErrFileRequired
 	}
 
 	// Validate file type (only images allowed)
 	if !strings.HasPrefix(file.Header.Get("Content-Type"), "image/") {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Only image files are allowed",
-		})
+		return models.ErrUnsupportedFile
 	}
 
 	// Open the file
 	src, err := file.Open()
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to open uploaded file",
-		})
+		return models.ErrInternal.WithMessage("Failed to open uploaded file").Wrap(err)
 	}
 	defer src.Close()
 
@@ -554,19 +482,13 @@ func (uc *UserController) UploadProfilePhoto(c echo.Context) error {
 	// Create destination file
 	dst, err := os.Create(storagePath)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to create destination file",
-		})
+		return models.ErrInternal.WithMessage("Failed to create destination file").Wrap(err)
 	}
 	defer dst.Close()
 
 	// Copy the uploaded file to the destination file
 	if _, err = io.Copy(dst, src); err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to save uploaded file",
-		})
+		return models.ErrInternal.WithMessage("Failed to save uploaded file").Wrap(err)
 	}
 
 	// Update the user's serviceProviderInfo with the photo path
@@ -589,10 +511,7 @@ func (uc *UserController) UploadProfilePhoto(c echo.Context) error {
 
 	_, err = collection.UpdateOne(ctx, bson.M{"_id": userID}, update)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to update profile photo",
-		})
+		return models.ErrInternal.WithMessage("Failed to update profile photo").Wrap(err)
 	}
 
 	return c.JSON(http.StatusOK, models.Response{
@@ -611,13 +530,10 @@ func (uc *UserController) UpdateAvailability(c echo.Context) error {
 	defer cancel()
 
 	// Get user information from token
-	claims := middleware.GetUserFromToken(c)
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid user ID",
-		})
+		return models.ErrInvalidUserID
 	}
 
 	// Get user collection
@@ -627,38 +543,22 @@ func (uc *UserController) UpdateAvailability(c echo.Context) error {
 	var user models.User
 	err = collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, models.Response{
-			Status:  http.StatusNotFound,
-			Message: "User not found",
-		})
+		return models.ErrUserNotFound
 	}
 
-	if user.UserType != "serviceProvider" {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Only service providers can update availability",
-		})
+	if !middleware.Authorize(c, middleware.PermProviderAvailabilityWrite) {
+		return models.ErrPermissionDenied.WithMessage("Only service providers can update availability")
 	}
 
 	// Parse request body
-	var availabilityReq struct {
-		AvailableDays  []string `json:"availableDays"`
-		AvailableHours []string `json:"availableHours"`
-	}
-
+	var availabilityReq models.AvailabilityRequest
 	if err := c.Bind(&availabilityReq); err != nil {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Invalid request body",
-		})
+		return models.ErrInvalidRequest
 	}
 
 	// Validate availability data
-	if len(availabilityReq.AvailableDays) == 0 || len(availabilityReq.AvailableHours) == 0 {
-		return c.JSON(http.StatusBadRequest, models.Response{
-			Status:  http.StatusBadRequest,
-			Message: "Available days and hours are required",
-		})
+	if err := c.Validate(&availabilityReq); err != nil {
+		return validationError(err)
 	}
 
 	// Update the service provider's availability
@@ -680,10 +580,7 @@ func (uc *UserController) UpdateAvailability(c echo.Context) error {
 
 	_, err = collection.UpdateOne(ctx, bson.M{"_id": userID}, update)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to update availability",
-		})
+		return models.ErrInternal.WithMessage("Failed to update availability").Wrap(err)
 	}
 
 	return c.JSON(http.StatusOK, models.Response{
@@ -718,7 +615,7 @@ func (uc *UserController) SearchServiceProviders(c echo.Context) error {
 	country := c.QueryParam("country")
 
 	// Build filter
-	filter := bson.M{"userType": "serviceProvider"}
+	filter := bson.M{"userType": "serviceProvider", "deletionScheduledAt": bson.M{"$exists": false}}
 
 	if serviceType != "" {
 		filter["serviceProviderInfo.serviceType"] = serviceType
@@ -734,7 +631,7 @@ func (uc *UserController) SearchServiceProviders(c echo.Context) error {
 
 	// Set up options to exclude password field and apply pagination
 	opts := options.Find().
-		SetProjection(bson.M{"password": 0}).
+		SetProjection(userSecretsProjection).
 		SetSkip(int64(skip)).
 		SetLimit(int64(limit)).
 		SetSort(bson.D{{Key: "createdAt", Value: -1}})
@@ -742,29 +639,20 @@ func (uc *UserController) SearchServiceProviders(c echo.Context) error {
 	// Find service providers matching the criteria
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to fetch service providers",
-		})
+		return models.ErrInternal.WithMessage("Failed to fetch service providers").Wrap(err)
 	}
 	defer cursor.Close(ctx)
 
 	// Decode all service providers
-	var providers []models.User
+	providers := []models.User{}
 	if err := cursor.All(ctx, &providers); err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to decode service providers",
-		})
+		return models.ErrInternal.WithMessage("Failed to decode service providers").Wrap(err)
 	}
 
 	// Get total count for pagination info
 	totalCount, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to count service providers",
-		})
+		return models.ErrInternal.WithMessage("Failed to count service providers").Wrap(err)
 	}
 
 	// Calculate pagination metadata
@@ -797,35 +685,26 @@ func (uc *UserController) GetCompaniesWithLocations(c echo.Context) error {
 
 	// Set up filter for companies with location data
 	filter := bson.M{
-		"userType": "company",
-		"location": bson.M{"$exists": true, "$ne": nil},
+		"userType":            "company",
+		"emailVerified":       true,
+		"location":            bson.M{"$exists": true, "$ne": nil},
+		"deletionScheduledAt": bson.M{"$exists": false},
 	}
 
 	// Set up options to exclude sensitive data
-	opts := options.Find().
-		SetProjection(bson.M{
-			"password":           0,
-			"resetPasswordToken": 0,
-			"otpInfo":            0,
-		})
+	opts := options.Find().SetProjection(userSecretsProjection)
 
 	// Find companies
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to fetch companies",
-		})
+		return models.ErrInternal.WithMessage("Failed to fetch companies").Wrap(err)
 	}
 	defer cursor.Close(ctx)
 
 	// Decode all companies
-	var companies []models.User
+	companies := []models.User{}
 	if err := cursor.All(ctx, &companies); err != nil {
-		return c.JSON(http.StatusInternalServerError, models.Response{
-			Status:  http.StatusInternalServerError,
-			Message: "Failed to decode companies",
-		})
+		return models.ErrInternal.WithMessage("Failed to decode companies").Wrap(err)
 	}
 
 	// Return companies
@@ -835,3 +714,383 @@ func (uc *UserController) GetCompaniesWithLocations(c echo.Context) error {
 		Data:    companies,
 	})
 }
+
+// emailChangeTTL is how long the code confirming a new email address stays valid
+func emailChangeTTL() time.Duration {
+	return config.GetEnvDuration("EMAIL_CHANGE_TTL", time.Hour)
+}
+
+// RequestEmailChange sends a confirmation code to the new address and a notice to the current one
+func (uc *UserController) RequestEmailChange(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Parse request body
+	var changeReq models.EmailChangeRequest
+	if err := c.Bind(&changeReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	newEmail := strings.TrimSpace(changeReq.NewEmail)
+	if addr, err := mail.ParseAddress(newEmail); err != nil || addr.Address != newEmail {
+		return models.ErrInvalidEmail
+	}
+
+	// Get user collection
+	collection := config.GetCollection(uc.DB, "users")
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	var user models.User
+	if err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrUserNotFound
+		}
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+	}
+
+	if strings.EqualFold(newEmail, user.Email) {
+		return models.ErrEmailUnchanged
+	}
+
+	// Accounts with a password must confirm it, like any other credential change
+	if user.Password != "" {
+		accountKey := loginAccountKey(user.Email)
+		lockedUntil, err := checkLockout(ctx, uc.DB, accountKey)
+		if err != nil {
+			return models.ErrInternal.WithMessage("Failed to check login attempts").Wrap(err)
+		}
+		if !lockedUntil.IsZero() {
+			return tooManyRequests(c, lockedUntil, "Too many failed attempts. Please try again later")
+		}
+
+		if err := utils.CheckPassword(changeReq.Password, user.Password); err != nil {
+			if err := recordFailedAttempt(ctx, uc.DB, accountKey, loginAccountPolicy()); err != nil {
+				log.Printf("Failed to record password attempt for %s: %v", accountKey, err)
+			}
+			return models.ErrInvalidPassword
+		}
+	}
+
+	// Throttle how often codes are sent
+	if user.EmailChange != nil && time.Since(user.EmailChange.SentAt) < otpResendCooldown() {
+		return tooManyRequests(c, user.EmailChange.SentAt.Add(otpResendCooldown()), "Please wait before requesting a new code")
+	}
+
+	count, err := collection.CountDocuments(ctx, bson.M{"email": newEmail})
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to check email").Wrap(err)
+	}
+	if count > 0 {
+		return models.ErrEmailInUse
+	}
+
+	code, err := generateOTP(otpLength())
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate code").Wrap(err)
+	}
+
+	now := time.Now()
+	emailChange := models.EmailChange{
+		NewEmail: newEmail,
+		OTPInfo: models.OTPInfo{
+			OTP:       code,
+			ExpiresAt: now.Add(emailChangeTTL()),
+			SentAt:    now,
+		},
+	}
+	_, err = collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"emailChange": emailChange, "updatedAt": now}},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to start email change").Wrap(err)
+	}
+
+	if err := sendEmailChangeCode(newEmail, user.FullName, code); err != nil {
+		log.Printf("Failed to send email change code to %s: %v", newEmail, err)
+		return models.ErrInternal.WithMessage("Failed to send confirmation email").Wrap(err)
+	}
+	if err := sendEmailChangeNotice(user.Email, user.FullName, newEmail); err != nil {
+		log.Printf("Failed to send email change notice to %s: %v", user.Email, err)
+	}
+
+	event := auditUser(models.AuditEmailChangeStarted, &user)
+	event.Metadata = map[string]interface{}{"newEmail": newEmail}
+	recordAudit(c, uc.DB, event)
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "A confirmation code has been sent to " + maskEmail(newEmail),
+	})
+}
+
+// ConfirmEmailChange swaps in the new email address once the code sent to it is confirmed
+func (uc *UserController) ConfirmEmailChange(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Parse request body
+	var confirmReq models.EmailChangeConfirmRequest
+	if err := c.Bind(&confirmReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	// Get user collection
+	collection := config.GetCollection(uc.DB, "users")
+
+	principal := middleware.GetPrincipal(c)
+	userID, err := primitive.ObjectIDFromHex(principal.UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+	sessionID, err := primitive.ObjectIDFromHex(principal.SessionID)
+	if err != nil {
+		return models.ErrInvalidSessionID
+	}
+
+	var user models.User
+	if err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrUserNotFound
+		}
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+	}
+
+	pending := user.EmailChange
+	if pending == nil || time.Now().After(pending.ExpiresAt) {
+		return models.ErrEmailChangeNotPending
+	}
+
+	if !otpMatches(confirmReq.Code, pending.OTP) {
+		// Too many wrong codes invalidate the pending change
+		update := bson.M{"$inc": bson.M{"emailChange.attempts": 1}}
+		codeErr := models.ErrOTPInvalid.WithMessage("Invalid code")
+		if pending.Attempts+1 >= otpMaxAttempts() {
+			update = bson.M{"$unset": bson.M{"emailChange": ""}}
+			codeErr = models.ErrOTPAttemptsExceeded.WithMessage("Too many invalid attempts. Please request a new code")
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			log.Printf("Failed to record email change attempt for %s: %v", user.ID.Hex(), err)
+		}
+		return codeErr
+	}
+
+	// Matching the code in the filter keeps the swap single-use; the unique index catches races
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "emailChange.otp": pending.OTP},
+		bson.M{
+			"$set": bson.M{
+				"email":         pending.NewEmail,
+				"emailVerified": true,
+				"updatedAt":     time.Now(),
+			},
+			"$unset": bson.M{"emailChange": ""},
+		},
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return models.ErrEmailInUse
+		}
+		return models.ErrInternal.WithMessage("Failed to change email").Wrap(err)
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrEmailChangeNotPending
+	}
+
+	// Sign out every other device; the session making the change stays logged in
+	if err := middleware.RevokeUserSessions(ctx, uc.DB, user.ID, sessionID); err != nil {
+		log.Printf("Failed to revoke sessions after email change for %s: %v", user.ID.Hex(), err)
+	}
+
+	event := auditUser(models.AuditEmailChanged, &user)
+	event.Changes = map[string]models.AuditChange{"email": {From: user.Email, To: pending.NewEmail}}
+	recordAudit(c, uc.DB, event)
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Email changed successfully",
+		Data: map[string]interface{}{
+			"email": pending.NewEmail,
+		},
+	})
+}
+
+// SendPhoneVerification normalizes a phone number and texts it a verification code
+func (uc *UserController) SendPhoneVerification(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Parse request body
+	var phoneReq models.PhoneVerificationRequest
+	if err := c.Bind(&phoneReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	phone, err := utils.NormalizePhone(phoneReq.Phone, defaultPhoneCountryCode())
+	if err != nil {
+		return models.ErrInvalidPhone
+	}
+
+	// Get user collection
+	collection := config.GetCollection(uc.DB, "users")
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	var user models.User
+	if err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrUserNotFound
+		}
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+	}
+
+	if user.PhoneVerified && user.Phone == phone {
+		return models.ErrPhoneVerified
+	}
+
+	// Throttle how often codes are sent
+	if user.PhoneVerification != nil && time.Since(user.PhoneVerification.SentAt) < otpResendCooldown() {
+		return tooManyRequests(c, user.PhoneVerification.SentAt.Add(otpResendCooldown()), "Please wait before requesting a new code")
+	}
+
+	// A verified number belongs to one account only, since it can be used to reset passwords
+	count, err := collection.CountDocuments(ctx, bson.M{"phone": phone, "phoneVerified": true, "_id": bson.M{"$ne": user.ID}})
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to check phone number").Wrap(err)
+	}
+	if count > 0 {
+		return models.ErrPhoneInUse
+	}
+
+	code, err := generateOTP(otpLength())
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to generate code").Wrap(err)
+	}
+
+	now := time.Now()
+	verification := models.PhoneVerification{
+		Phone: phone,
+		OTPInfo: models.OTPInfo{
+			OTP:       code,
+			ExpiresAt: now.Add(phoneVerificationTTL()),
+			SentAt:    now,
+		},
+	}
+	_, err = collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"phoneVerification": verification, "updatedAt": now}},
+	)
+	if err != nil {
+		return models.ErrInternal.WithMessage("Failed to start phone verification").Wrap(err)
+	}
+
+	if err := sendPhoneVerificationSMS(ctx, uc.SMS, phone, code); err != nil {
+		log.Printf("Failed to send verification SMS to %s: %v", utils.MaskPhone(phone), err)
+		return models.ErrInternal.WithMessage("Failed to send verification SMS").Wrap(err)
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "A verification code has been sent to " + utils.MaskPhone(phone),
+		Data: map[string]interface{}{
+			"phone": phone,
+		},
+	})
+}
+
+// VerifyPhone confirms the pending phone number and marks it verified
+func (uc *UserController) VerifyPhone(c echo.Context) error {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Parse request body
+	var confirmReq models.PhoneVerificationConfirmRequest
+	if err := c.Bind(&confirmReq); err != nil {
+		return models.ErrInvalidRequest
+	}
+
+	// Get user collection
+	collection := config.GetCollection(uc.DB, "users")
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetPrincipal(c).UserID)
+	if err != nil {
+		return models.ErrInvalidUserID
+	}
+
+	var user models.User
+	if err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.ErrUserNotFound
+		}
+		return models.ErrInternal.WithMessage("Failed to find user").Wrap(err)
+	}
+
+	pending := user.PhoneVerification
+	if pending == nil || time.Now().After(pending.ExpiresAt) {
+		return models.ErrPhoneVerifyNotPending
+	}
+
+	if !otpMatches(confirmReq.Code, pending.OTP) {
+		// Too many wrong codes invalidate the pending verification
+		update := bson.M{"$inc": bson.M{"phoneVerification.attempts": 1}}
+		codeErr := models.ErrOTPInvalid.WithMessage("Invalid code")
+		if pending.Attempts+1 >= otpMaxAttempts() {
+			update = bson.M{"$unset": bson.M{"phoneVerification": ""}}
+			codeErr = models.ErrOTPAttemptsExceeded.WithMessage("Too many invalid attempts. Please request a new code")
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			log.Printf("Failed to record phone verification attempt for %s: %v", user.ID.Hex(), err)
+		}
+		return codeErr
+	}
+
+	// The partial unique index on verified phones catches two accounts racing for one number
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "phoneVerification.otp": pending.OTP},
+		bson.M{
+			"$set": bson.M{
+				"phone":         pending.Phone,
+				"phoneVerified": true,
+				"updatedAt":     time.Now(),
+			},
+			"$unset": bson.M{"phoneVerification": ""},
+		},
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return models.ErrPhoneInUse
+		}
+		return models.ErrInternal.WithMessage("Failed to verify phone number").Wrap(err)
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrPhoneVerifyNotPending
+	}
+
+	event := auditUser(models.AuditPhoneVerified, &user)
+	event.Changes = map[string]models.AuditChange{"phone": {From: user.Phone, To: pending.Phone}}
+	recordAudit(c, uc.DB, event)
+
+	return c.JSON(http.StatusOK, models.Response{
+		Status:  http.StatusOK,
+		Message: "Phone number verified successfully",
+		Data: map[string]interface{}{
+			"phone":         pending.Phone,
+			"phoneVerified": true,
+		},
+	})
+}
@@ -0,0 +1,27 @@
+// controllers/validation.go
+package controllers
+
+import (
+	"errors"
+
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// validationError converts the result of c.Validate into a catalog error listing every rejected field
+func validationError(err error) error {
+	var violations utils.ValidationErrors
+	if !errors.As(err, &violations) {
+		return models.ErrInternal.WithMessage("Failed to validate request").Wrap(err)
+	}
+
+	fieldErrors := make([]models.FieldError, len(violations))
+	for i, violation := range violations {
+		fieldErrors[i] = models.FieldError{
+			Field:   violation.Field,
+			Code:    violation.Code,
+			Message: violation.Message,
+		}
+	}
+	return models.ErrValidationFailed.WithErrors(fieldErrors)
+}
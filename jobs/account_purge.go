@@ -0,0 +1,151 @@
+// jobs/account_purge.go
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+// uploadsDir is where uploaded files live; only paths inside it are ever removed
+const uploadsDir = "uploads"
+
+// purgeBatchSize bounds how many accounts one run loads at a time
+const purgeBatchSize = 100
+
+// userOwnedCollections hold documents keyed by the owning user that go with the account
+var userOwnedCollections = map[string]string{
+	"companies":        "userId",
+	"serviceProviders": "userId",
+	"wholesalers":      "userId",
+	"sessions":         "userId",
+	"api_keys":         "companyId",
+	"data_exports":     "userId",
+}
+
+// StartAccountPurge runs PurgeDeletedAccounts now and then on every interval until ctx is done
+func StartAccountPurge(ctx context.Context, db *mongo.Client, interval time.Duration) {
+	runEvery(ctx, interval, "deleted accounts", func(ctx context.Context) (int, error) {
+		return PurgeDeletedAccounts(ctx, db)
+	})
+}
+
+// PurgeDeletedAccounts permanently removes accounts whose deletion grace period has ended,
+// together with their related documents and uploaded files. It returns how many were purged.
+func PurgeDeletedAccounts(ctx context.Context, db *mongo.Client) (int, error) {
+	users := config.GetCollection(db, "users")
+	purged := 0
+
+	for {
+		filter := bson.M{"deletionScheduledAt": bson.M{"$lte": time.Now()}}
+		cursor, err := users.Find(ctx, filter, options.Find().SetLimit(purgeBatchSize))
+		if err != nil {
+			return purged, err
+		}
+		var batch []models.User
+		if err := cursor.All(ctx, &batch); err != nil {
+			return purged, err
+		}
+		if len(batch) == 0 {
+			return purged, nil
+		}
+
+		for i := range batch {
+			if err := purgeAccount(ctx, db, &batch[i]); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+}
+
+// purgeAccount deletes one account. Related documents and files go first and the user document
+// last, so a failure leaves the account scheduled and the next run retries it. Accounts past
+// their grace period can no longer be restored, so nothing is lost by removing their data first.
+func purgeAccount(ctx context.Context, db *mongo.Client, user *models.User) error {
+	for collName, field := range userOwnedCollections {
+		if _, err := config.GetCollection(db, collName).DeleteMany(ctx, bson.M{field: user.ID}); err != nil {
+			return fmt.Errorf("delete %s of user %s: %w", collName, user.ID.Hex(), err)
+		}
+	}
+
+	for _, path := range AccountFiles(user) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to delete file %s of purged user %s: %v", path, user.ID.Hex(), err)
+		}
+	}
+	if err := os.RemoveAll(DataExportUserDir(user.ID)); err != nil {
+		log.Printf("Failed to delete data exports of purged user %s: %v", user.ID.Hex(), err)
+	}
+
+	result, err := config.GetCollection(db, "users").DeleteOne(ctx, bson.M{
+		"_id":                 user.ID,
+		"deletionScheduledAt": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("delete user %s: %w", user.ID.Hex(), err)
+	}
+	if result.DeletedCount == 0 {
+		return nil
+	}
+
+	event := models.AuditEvent{
+		ID:         primitive.NewObjectID(),
+		Action:     models.AuditAccountPurged,
+		ActorType:  "system",
+		TargetType: "user",
+		TargetID:   user.ID.Hex(),
+		CreatedAt:  time.Now(),
+	}
+	if _, err := config.GetCollection(db, "audit_events").InsertOne(ctx, event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", event.Action, err)
+	}
+	return nil
+}
+
+// AccountFiles lists the uploaded files referenced by an account: logos, profile photos and branch images
+func AccountFiles(user *models.User) []string {
+	refs := []string{user.LogoPath, user.ProfilePic}
+	if user.CompanyInfo != nil {
+		refs = append(refs, user.CompanyInfo.Logo)
+		for _, branch := range user.CompanyInfo.Branches {
+			refs = append(refs, branch.Images...)
+		}
+	}
+	if user.ServiceProviderInfo != nil {
+		refs = append(refs, user.ServiceProviderInfo.ProfilePhoto)
+	}
+
+	var paths []string
+	for _, ref := range refs {
+		if path, ok := uploadPath(ref); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// uploadPath maps a stored file reference such as "/uploads/logos/x.png" or "uploads/x.jpg" to a
+// path on disk. References outside the uploads directory, like remote URLs, are skipped.
+func uploadPath(ref string) (string, bool) {
+	if ref == "" || strings.Contains(ref, "://") {
+		return "", false
+	}
+	path := filepath.Clean(strings.TrimPrefix(filepath.ToSlash(ref), "/"))
+	if !strings.HasPrefix(path, uploadsDir+string(filepath.Separator)) {
+		return "", false
+	}
+	return path, true
+}
@@ -0,0 +1,63 @@
+// jobs/data_exports.go
+package jobs
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+// DataExportDir returns the directory personal data export archives are written to
+func DataExportDir() string {
+	return config.GetEnv("DATA_EXPORT_DIR", "exports")
+}
+
+// DataExportUserDir returns the directory holding one user's export archives
+func DataExportUserDir(userID primitive.ObjectID) string {
+	return filepath.Join(DataExportDir(), userID.Hex())
+}
+
+// StartDataExportCleanup removes expired data exports now and then on every interval until ctx is done
+func StartDataExportCleanup(ctx context.Context, db *mongo.Client, interval time.Duration) {
+	runEvery(ctx, interval, "expired data exports", func(ctx context.Context) (int, error) {
+		return PurgeExpiredDataExports(ctx, db)
+	})
+}
+
+// PurgeExpiredDataExports deletes export archives past their download window and their records
+func PurgeExpiredDataExports(ctx context.Context, db *mongo.Client) (int, error) {
+	exports := config.GetCollection(db, "data_exports")
+
+	cursor, err := exports.Find(ctx, bson.M{"expiresAt": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	var expired []models.DataExport
+	if err := cursor.All(ctx, &expired); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, export := range expired {
+		if export.FilePath != "" {
+			if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+				log.Printf("Failed to delete data export %s: %v", export.FilePath, err)
+				continue
+			}
+		}
+		if _, err := exports.DeleteOne(ctx, bson.M{"_id": export.ID}); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,34 @@
+// jobs/jobs.go
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runEvery runs a cleanup task now and then on every interval until ctx is done.
+// Each run gets at most one interval to finish.
+func runEvery(ctx context.Context, interval time.Duration, name string, task func(ctx context.Context) (int, error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			runCtx, cancel := context.WithTimeout(ctx, interval)
+			removed, err := task(runCtx)
+			cancel()
+			if err != nil {
+				log.Printf("Cleanup of %s failed: %v", name, err)
+			} else if removed > 0 {
+				log.Printf("Removed %d %s", removed, name)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
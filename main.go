@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
@@ -10,7 +12,11 @@ import (
 
 	"github.com/HSouheill/barrim_backend/config"
 	"github.com/HSouheill/barrim_backend/controllers"
+	"github.com/HSouheill/barrim_backend/jobs"
+	customMiddleware "github.com/HSouheill/barrim_backend/middleware"
+	"github.com/HSouheill/barrim_backend/models"
 	"github.com/HSouheill/barrim_backend/routes"
+	"github.com/HSouheill/barrim_backend/utils"
 )
 
 func main() {
@@ -20,11 +26,28 @@ func main() {
 		log.Println("Warning: .env file not found")
 	}
 
+	// Load JWT signing keys early so a bad key configuration fails at startup
+	customMiddleware.SigningKeys()
+
 	// Connect to database
 	client := config.ConnectDB()
 
+	// Permanently remove accounts whose deletion grace period has ended
+	jobs.StartAccountPurge(context.Background(), client, config.GetEnvDuration("ACCOUNT_PURGE_INTERVAL", time.Hour))
+	// Remove personal data exports whose download window has passed
+	jobs.StartDataExportCleanup(context.Background(), client, config.GetEnvDuration("DATA_EXPORT_CLEANUP_INTERVAL", time.Hour))
+
 	// Create a new Echo instance
 	e := echo.New()
+	// Request structs are checked against their validate tags with c.Validate; the tags are
+	// parsed now so a malformed one stops the server instead of failing requests
+	requestValidator := utils.NewRequestValidator()
+	if err := requestValidator.Register(models.ValidatedRequests...); err != nil {
+		log.Fatalf("Invalid request validation rules: %v", err)
+	}
+	e.Validator = requestValidator
+	// Errors returned by handlers are written with their stable error codes
+	e.HTTPErrorHandler = customMiddleware.HTTPErrorHandler
 
 	// Middleware
 	e.Use(middleware.Logger())
@@ -35,6 +58,9 @@ func main() {
 	companyController := controllers.NewCompanyController(client)
 	// Register company routes
 	routes.RegisterCompanyRoutes(e, companyController)
+	// Register admin routes
+	adminController := controllers.NewAdminController(client)
+	routes.RegisterAdminRoutes(e, adminController, companyController)
 	// Setup routes
 	routes.SetupRoutes(e, client)
 	// Ensure uploads directory exists
@@ -0,0 +1,140 @@
+// middleware/api_keys.go
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// APIKeyHeader is the request header carrying an API key
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyPrefix marks Barrim API keys so leaked keys are easy to recognise
+const apiKeyPrefix = "brm_"
+
+// apiKeyDisplayLength is how much of a key is kept in clear text to tell keys apart
+const apiKeyDisplayLength = 12
+
+// ErrInvalidAPIKey is returned for API keys that are unknown, revoked or expired
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKeyScopes maps each scope an API key can be granted to the permissions it allows
+var APIKeyScopes = map[string][]Permission{
+	"branches:read":  {PermBranchRead},
+	"branches:write": {PermBranchWrite, PermBranchDelete},
+	"company:read":   {PermCompanyRead},
+	"company:write":  {PermCompanyWrite},
+}
+
+// NewAPIKey returns a random API key and the clear-text prefix stored alongside its hash
+func NewAPIKey() (key, prefix string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:apiKeyDisplayLength], nil
+}
+
+// AuthenticateTokenOrAPIKey accepts either an API key in the X-API-Key header or a bearer
+// access token. API key callers act as the owning company, limited to the key's scopes.
+func AuthenticateTokenOrAPIKey(db *mongo.Client) echo.MiddlewareFunc {
+	authenticate := Authenticate(db)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withToken := authenticate(next)
+		return func(c echo.Context) error {
+			raw := c.Request().Header.Get(APIKeyHeader)
+			if raw == "" {
+				return withToken(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+			defer cancel()
+
+			principal, err := ValidateAPIKey(ctx, db, raw)
+			if err != nil {
+				switch {
+				case errors.Is(err, ErrInvalidAPIKey), errors.Is(err, ErrAccountNotFound):
+					return models.ErrInvalidAPIKey
+				case errors.Is(err, ErrAccountSuspended):
+					return models.ErrAccountSuspended
+				default:
+					return models.ErrInternal.WithMessage("Failed to validate API key").Wrap(err)
+				}
+			}
+
+			c.Set(principalContextKey, principal)
+			return next(c)
+		}
+	}
+}
+
+// ValidateAPIKey checks an API key and its owning account and returns the principal it acts as
+func ValidateAPIKey(ctx context.Context, db *mongo.Client, raw string) (*Principal, error) {
+	keys := config.GetCollection(db, "api_keys")
+
+	var key models.APIKey
+	err := keys.FindOne(ctx, bson.M{"keyHash": utils.HashToken(raw)}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var owner models.User
+	opts := options.FindOne().SetProjection(bson.M{"email": 1, "userType": 1, "suspended": 1, "deletionScheduledAt": 1})
+	err = config.GetCollection(db, "users").FindOne(ctx, bson.M{"_id": key.CompanyID}, opts).Decode(&owner)
+	if err == mongo.ErrNoDocuments || (err == nil && owner.PendingDeletion()) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if owner.Suspended {
+		return nil, ErrAccountSuspended
+	}
+	// Keys stop working when the owner can no longer hold them, e.g. after a type change
+	if !HasPermission(owner.UserType, PermAPIKeysManage) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Like sessions, the last use is only written once per interval
+	_, err = keys.UpdateOne(
+		ctx,
+		bson.M{"_id": key.ID, "$or": bson.A{
+			bson.M{"lastUsedAt": bson.M{"$lt": now.Add(-lastSeenInterval)}},
+			bson.M{"lastUsedAt": bson.M{"$exists": false}},
+		}},
+		bson.M{"$set": bson.M{"lastUsedAt": now}},
+	)
+	if err != nil {
+		log.Printf("Failed to update last use of API key %s: %v", key.ID.Hex(), err)
+	}
+
+	return &Principal{
+		UserID:   key.CompanyID.Hex(),
+		Email:    owner.Email,
+		UserType: owner.UserType,
+		APIKeyID: key.ID.Hex(),
+		Scopes:   key.Scopes,
+	}, nil
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+var allPermissions = []Permission{
+	PermProfileRead, PermProfileWrite, PermAccountDelete, PermCredentialsManage, PermDataExport,
+	PermLocationWrite, PermCompaniesList, PermCompanyRead, PermCompanyWrite, PermCompanyLogoWrite,
+	PermBranchRead, PermBranchWrite, PermBranchDelete, PermBranchReadAny,
+	PermProviderAvailabilityWrite, PermProviderPhotoWrite, PermUsersList, PermUsersManage,
+	PermUsersImpersonate, PermSettingsManage, PermAuditRead, PermAPIKeysManage,
+}
+
+func TestAPIKeyScopePermissions(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  []Permission
+	}{
+		{"branches:read", []Permission{PermBranchRead}},
+		{"branches:write", []Permission{PermBranchWrite, PermBranchDelete}},
+		{"company:read", []Permission{PermCompanyRead}},
+		{"company:write", []Permission{PermCompanyWrite}},
+		{"unknown:scope", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			principal := Principal{UserType: "company", APIKeyID: "key", Scopes: []string{tt.scope}}
+			for _, perm := range allPermissions {
+				want := false
+				for _, p := range tt.want {
+					want = want || p == perm
+				}
+				if got := principal.HasPermission(perm); got != want {
+					t.Errorf("scope %q: HasPermission(%q) = %v, want %v", tt.scope, perm, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyScopesStayWithinCompanyRole(t *testing.T) {
+	for scope, perms := range APIKeyScopes {
+		for _, perm := range perms {
+			if !HasPermission("company", perm) {
+				t.Errorf("scope %q grants %q, which companies do not hold", scope, perm)
+			}
+			if perm == PermAPIKeysManage || perm == PermCredentialsManage || perm == PermAccountDelete {
+				t.Errorf("scope %q grants account management permission %q", scope, perm)
+			}
+		}
+	}
+}
+
+func TestNewAPIKey(t *testing.T) {
+	key, prefix, err := NewAPIKey()
+	if err != nil {
+		t.Fatalf("NewAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, apiKeyPrefix) || !strings.HasPrefix(key, prefix) || len(prefix) != apiKeyDisplayLength {
+		t.Errorf("NewAPIKey() = %q, %q", key, prefix)
+	}
+	other, _, err := NewAPIKey()
+	if err != nil {
+		t.Fatalf("NewAPIKey() error = %v", err)
+	}
+	if other == key {
+		t.Error("NewAPIKey() returned the same key twice")
+	}
+}
+
+func TestValidateAPIKey(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	keyID, companyID := primitive.NewObjectID(), primitive.NewObjectID()
+	keyDoc := func(extra ...bson.E) bson.D {
+		doc := bson.D{
+			{Key: "_id", Value: keyID},
+			{Key: "companyId", Value: companyID},
+			{Key: "scopes", Value: bson.A{"branches:read"}},
+		}
+		return append(doc, extra...)
+	}
+	ownerDoc := func(userType string, extra ...bson.E) bson.D {
+		doc := bson.D{{Key: "_id", Value: companyID}, {Key: "email", Value: "company@example.com"}, {Key: "userType", Value: userType}}
+		return append(doc, extra...)
+	}
+
+	mt.Run("valid key acts as the company", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			findResponse("barrim.api_keys", keyDoc()),
+			findResponse("barrim.users", ownerDoc("company")),
+			updateResponse(1),
+		)
+		principal, err := ValidateAPIKey(context.Background(), mt.Client, "brm_key")
+		if err != nil {
+			mt.Fatalf("ValidateAPIKey() error = %v", err)
+		}
+		if principal.UserID != companyID.Hex() || principal.APIKeyID != keyID.Hex() || len(principal.Scopes) != 1 {
+			mt.Errorf("ValidateAPIKey() = %+v", principal)
+		}
+		if !principal.HasPermission(PermBranchRead) || principal.HasPermission(PermBranchWrite) {
+			mt.Error("principal permissions do not follow the key scopes")
+		}
+	})
+
+	tests := []struct {
+		name      string
+		responses []bson.D
+		want      error
+	}{
+		{"unknown key", []bson.D{findResponse("barrim.api_keys")}, ErrInvalidAPIKey},
+		{"revoked key", []bson.D{
+			findResponse("barrim.api_keys", keyDoc(bson.E{Key: "revokedAt", Value: time.Now()})),
+		}, ErrInvalidAPIKey},
+		{"expired key", []bson.D{
+			findResponse("barrim.api_keys", keyDoc(bson.E{Key: "expiresAt", Value: time.Now().Add(-time.Minute)})),
+		}, ErrInvalidAPIKey},
+		{"owner is no longer a company", []bson.D{
+			findResponse("barrim.api_keys", keyDoc()),
+			findResponse("barrim.users", ownerDoc("user")),
+		}, ErrInvalidAPIKey},
+		{"suspended owner", []bson.D{
+			findResponse("barrim.api_keys", keyDoc()),
+			findResponse("barrim.users", ownerDoc("company", bson.E{Key: "suspended", Value: true})),
+		}, ErrAccountSuspended},
+		{"owner scheduled for deletion", []bson.D{
+			findResponse("barrim.api_keys", keyDoc()),
+			findResponse("barrim.users", ownerDoc("company", bson.E{Key: "deletionScheduledAt", Value: time.Now()})),
+		}, ErrAccountNotFound},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(tt.responses...)
+			if _, err := ValidateAPIKey(context.Background(), mt.Client, "brm_key"); err != tt.want {
+				mt.Fatalf("ValidateAPIKey() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
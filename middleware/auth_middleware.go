@@ -2,28 +2,62 @@
 package middleware
 
 import (
-	"net/http"
+	"context"
+	"time"
 
+	"github.com/HSouheill/barrim_backend/config"
 	"github.com/HSouheill/barrim_backend/models"
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// RequireUserType checks if the authenticated user has one of the allowed user types
-func RequireUserType(allowedTypes ...string) echo.MiddlewareFunc {
+// EmailVerificationRequired reports whether accounts of the given type must verify their email
+// before using protected routes. The policy is read from EMAIL_VERIFICATION_REQUIRED_FOR.
+func EmailVerificationRequired(userType string) bool {
+	required := config.GetEnvList("EMAIL_VERIFICATION_REQUIRED_FOR", []string{"company", "wholesaler", "serviceProvider"})
+	for _, t := range required {
+		if t == userType {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireVerifiedEmail blocks accounts covered by the verification policy until their email is verified
+func RequireVerifiedEmail(db *mongo.Client) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			claims := GetUserFromToken(c)
+			principal := GetPrincipal(c)
+			if principal == nil || !EmailVerificationRequired(principal.UserType) {
+				return next(c)
+			}
 
-			for _, allowedType := range allowedTypes {
-				if claims.UserType == allowedType {
-					return next(c)
+			userID, err := primitive.ObjectIDFromHex(principal.UserID)
+			if err != nil {
+				return models.ErrUnauthorized.WithMessage("Invalid user ID")
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+			defer cancel()
+
+			var user models.User
+			opts := options.FindOne().SetProjection(bson.M{"emailVerified": 1})
+			err = config.GetCollection(db, "users").FindOne(ctx, bson.M{"_id": userID}, opts).Decode(&user)
+			if err != nil {
+				if err == mongo.ErrNoDocuments {
+					return models.ErrAccountNotFound.WithMessage("User not found")
 				}
+				return models.ErrInternal.WithMessage("Failed to check email verification").Wrap(err)
+			}
+
+			if !user.EmailVerified {
+				return models.ErrEmailNotVerified
 			}
 
-			return c.JSON(http.StatusForbidden, models.Response{
-				Status:  http.StatusForbidden,
-				Message: "Access denied for your user type",
-			})
+			return next(c)
 		}
 	}
 }
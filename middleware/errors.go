@@ -0,0 +1,76 @@
+// middleware/errors.go
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+// HTTPErrorHandler writes every error returned by a handler or middleware as a models.Response
+// carrying a stable code. Catalog errors are sent as they are; anything else becomes a generic
+// error so internal details never reach the client.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	apiErr := toAPIError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Request().Method, c.Request().URL.Path, err)
+	}
+
+	var writeErr error
+	if c.Request().Method == http.MethodHead {
+		writeErr = c.NoContent(apiErr.Status)
+	} else {
+		writeErr = c.JSON(apiErr.Status, models.Response{
+			Status:  apiErr.Status,
+			Code:    apiErr.Code,
+			Message: apiErr.Message,
+			Data:    apiErr.Data,
+			Errors:  apiErr.Errors,
+		})
+	}
+	if writeErr != nil {
+		log.Printf("Failed to write error response: %v", writeErr)
+	}
+}
+
+// toAPIError maps an error to its catalog entry
+func toAPIError(err error) *models.APIError {
+	var apiErr *models.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	// Errors raised by Echo itself, such as unknown routes or oversized bodies
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.Code {
+		case http.StatusNotFound:
+			return models.ErrNotFound
+		case http.StatusMethodNotAllowed:
+			return models.ErrMethodNotAllowed
+		case http.StatusRequestEntityTooLarge:
+			return models.ErrRequestTooLarge
+		case http.StatusUnauthorized:
+			return models.ErrUnauthorized
+		case http.StatusForbidden:
+			return models.ErrPermissionDenied
+		case http.StatusTooManyRequests:
+			return models.ErrTooManyRequests
+		case http.StatusBadRequest, http.StatusUnsupportedMediaType:
+			return models.ErrInvalidRequest.WithMessage(http.StatusText(httpErr.Code))
+		}
+		if httpErr.Code < http.StatusInternalServerError {
+			return models.NewAPIError(httpErr.Code, models.ErrInvalidRequest.Code, http.StatusText(httpErr.Code))
+		}
+	}
+
+	return models.ErrInternal.Wrap(err)
+}
@@ -0,0 +1,111 @@
+// middleware/impersonation.go
+package middleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// ImpersonatedByHeader is set on every response to a request made with an impersonation token
+const ImpersonatedByHeader = "X-Impersonated-By"
+
+// ErrImpersonatorNotAllowed is returned when the admin of an impersonation token lost the permission
+var ErrImpersonatorNotAllowed = errors.New("impersonator is no longer allowed to impersonate")
+
+// impersonationDeniedPermissions can never be used while acting as another user
+var impersonationDeniedPermissions = []Permission{
+	PermAccountDelete,
+	PermCredentialsManage,
+	PermDataExport,
+	PermBranchDelete,
+	PermAPIKeysManage,
+	PermUsersManage,
+	PermUsersImpersonate,
+	PermSettingsManage,
+}
+
+// ImpersonationTTL returns how long an impersonation token stays valid; it cannot be refreshed
+func ImpersonationTTL() time.Duration {
+	return config.GetEnvDuration("IMPERSONATION_TTL", 15*time.Minute)
+}
+
+// IssueImpersonationToken creates a short-lived session for the target user on behalf of an admin.
+// The session has no usable refresh token, and its access token names the admin in the act claim.
+func IssueImpersonationToken(ctx context.Context, db *mongo.Client, target *models.User, impersonatorID primitive.ObjectID, device models.DeviceInfo, ip string) (*TokenPair, error) {
+	// The refresh token is never handed out; it only fills the unique index
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ttl := ImpersonationTTL()
+	session := models.Session{
+		ID:               primitive.NewObjectID(),
+		UserID:           target.ID,
+		ImpersonatorID:   impersonatorID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		Device:           device,
+		IP:               ip,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(ttl),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	if _, err := config.GetCollection(db, "sessions").InsertOne(ctx, session); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := SigningKeys().Sign(&JwtCustomClaims{
+		UserID:    target.ID.Hex(),
+		Email:     target.Email,
+		UserType:  target.UserType,
+		SessionID: session.ID.Hex(),
+		Act:       &ActorClaim{Subject: impersonatorID.Hex()},
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: session.ExpiresAt.Unix(),
+			IssuedAt:  now.Unix(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken: accessToken,
+		ExpiresIn:   int64(ttl.Seconds()),
+		SessionID:   session.ID.Hex(),
+	}, nil
+}
+
+// ValidateImpersonator checks that the admin behind an impersonation token may still impersonate
+func ValidateImpersonator(ctx context.Context, db *mongo.Client, impersonatorID string) error {
+	impersonator, err := activeAccount(ctx, db, impersonatorID)
+	if err != nil {
+		return err
+	}
+	if !HasPermission(impersonator.UserType, PermUsersImpersonate) {
+		return ErrImpersonatorNotAllowed
+	}
+	return nil
+}
+
+// allowedWhileImpersonating reports whether perm may be used by an impersonation token
+func allowedWhileImpersonating(perm Permission) bool {
+	for _, denied := range impersonationDeniedPermissions {
+		if denied == perm {
+			return false
+		}
+	}
+	return true
+}
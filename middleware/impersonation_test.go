@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+func TestImpersonationDenyList(t *testing.T) {
+	denied := map[Permission]bool{
+		PermAccountDelete:     true,
+		PermCredentialsManage: true,
+		PermDataExport:        true,
+		PermBranchDelete:      true,
+		PermAPIKeysManage:     true,
+		PermUsersManage:       true,
+		PermUsersImpersonate:  true,
+		PermSettingsManage:    true,
+	}
+	for _, role := range []string{"user", "company", "wholesaler", "serviceProvider", "admin"} {
+		principal := Principal{UserType: role, ImpersonatorID: primitive.NewObjectID().Hex()}
+		for _, perm := range allPermissions {
+			want := HasPermission(role, perm) && !denied[perm]
+			if got := principal.HasPermission(perm); got != want {
+				t.Errorf("impersonating %s: HasPermission(%q) = %v, want %v", role, perm, got, want)
+			}
+		}
+	}
+}
+
+func TestImpersonationToken(t *testing.T) {
+	useEphemeralKeys(t)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("token names the admin and cannot be refreshed", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		target := &models.User{ID: primitive.NewObjectID(), Email: "company@example.com", UserType: "company"}
+		adminID := primitive.NewObjectID()
+
+		pair, err := IssueImpersonationToken(context.Background(), mt.Client, target, adminID, models.DeviceInfo{}, "")
+		if err != nil {
+			mt.Fatalf("IssueImpersonationToken() error = %v", err)
+		}
+		if pair.RefreshToken != "" {
+			mt.Error("impersonation returned a refresh token")
+		}
+		principal, err := ParseAccessToken(pair.AccessToken)
+		if err != nil {
+			mt.Fatalf("ParseAccessToken() error = %v", err)
+		}
+		if principal.UserID != target.ID.Hex() || principal.ImpersonatorID != adminID.Hex() {
+			mt.Errorf("principal = %+v, want user %s impersonated by %s", principal, target.ID.Hex(), adminID.Hex())
+		}
+		if principal.HasPermission(PermBranchDelete) {
+			mt.Error("impersonation token may delete branches")
+		}
+
+		insert := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		if got := insert.Lookup("impersonatorId").ObjectID(); got != adminID {
+			mt.Errorf("session impersonatorId = %s, want %s", got.Hex(), adminID.Hex())
+		}
+	})
+}
+
+func TestValidateImpersonator(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	adminID := primitive.NewObjectID()
+	account := func(userType string, extra ...bson.E) bson.D {
+		doc := bson.D{{Key: "_id", Value: adminID}, {Key: "userType", Value: userType}}
+		return append(doc, extra...)
+	}
+
+	tests := []struct {
+		name      string
+		id        string
+		responses []bson.D
+		want      error
+	}{
+		{"active admin", adminID.Hex(), []bson.D{findResponse("barrim.users", account("admin"))}, nil},
+		{"demoted admin", adminID.Hex(), []bson.D{findResponse("barrim.users", account("user"))}, ErrImpersonatorNotAllowed},
+		{"suspended admin", adminID.Hex(), []bson.D{
+			findResponse("barrim.users", account("admin", bson.E{Key: "suspended", Value: true})),
+		}, ErrAccountSuspended},
+		{"deleted admin", adminID.Hex(), []bson.D{
+			findResponse("barrim.users", account("admin", bson.E{Key: "deletionScheduledAt", Value: time.Now()})),
+		}, ErrAccountNotFound},
+		{"unknown admin", adminID.Hex(), []bson.D{findResponse("barrim.users")}, ErrAccountNotFound},
+		{"malformed id", "not-an-id", nil, ErrAccountNotFound},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(tt.responses...)
+			if err := ValidateImpersonator(context.Background(), mt.Client, tt.id); err != tt.want {
+				mt.Fatalf("ValidateImpersonator() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,99 +2,185 @@
 package middleware
 
 import (
+	"context"
 	"errors"
-	"os"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
-	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/HSouheill/barrim_backend/models"
 )
 
+// principalContextKey is the echo.Context key holding the authenticated Principal
+const principalContextKey = "principal"
+
+// ErrInvalidToken is returned for access tokens that cannot be trusted
+var ErrInvalidToken = errors.New("invalid token")
+
 // JwtCustomClaims for JWT token
 type JwtCustomClaims struct {
-	UserID   string `json:"userId"`
-	Email    string `json:"email"`
-	UserType string `json:"userType"`
+	UserID    string      `json:"userId"`
+	Email     string      `json:"email"`
+	UserType  string      `json:"userType"`
+	SessionID string      `json:"sid"`
+	Act       *ActorClaim `json:"act,omitempty"` // set on impersonation tokens
 	jwt.StandardClaims
 }
 
-// GetJWTConfig returns JWT middleware configuration
-func GetJWTConfig() middleware.JWTConfig {
-	return middleware.JWTConfig{
-		Claims:     &JwtCustomClaims{},
-		SigningKey: []byte(os.Getenv("JWT_SECRET")),
-	}
+// ActorClaim identifies who is acting on behalf of the token's subject (RFC 8693)
+type ActorClaim struct {
+	Subject string `json:"sub"`
 }
 
-func JWTMiddleware() echo.MiddlewareFunc {
-	secret := os.Getenv("JWT_SECRET")
-
-	return echomiddleware.JWTWithConfig(echomiddleware.JWTConfig{
-		SigningKey: []byte(secret),
-	})
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID    string
+	Email     string
+	UserType  string
+	SessionID string
+	APIKeyID  string   // set when the caller authenticated with an API key instead of a login
+	Scopes    []string // scopes of the API key; unused for logins
+	// ImpersonatorID is the admin acting as this user, set for impersonation tokens
+	ImpersonatorID string
 }
 
-// GenerateJWT generates new JWT token
-func GenerateJWT(userID, email, userType string) (string, error) {
-	// Set expiration time
-	expiration := time.Now().Add(time.Hour * 24) // 24 hours
-
-	// Set custom claims
-	claims := &JwtCustomClaims{
-		userID,
-		email,
-		userType,
-		jwt.StandardClaims{
-			ExpiresAt: expiration.Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
+// HasPermission reports whether the caller holds the permission. API key callers are further
+// limited to the permissions of their key's scopes, and impersonators cannot use destructive ones.
+func (p *Principal) HasPermission(perm Permission) bool {
+	if !HasPermission(p.UserType, perm) {
+		return false
 	}
+	if p.ImpersonatorID != "" && !allowedWhileImpersonating(perm) {
+		return false
+	}
+	if p.APIKeyID == "" {
+		return true
+	}
+	for _, scope := range p.Scopes {
+		for _, granted := range APIKeyScopes[scope] {
+			if granted == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Generate encoded token
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+// Authenticate validates the bearer access token and its session, then stores the caller's
+// Principal on the context. Every protected route goes through it.
+func Authenticate(db *mongo.Client) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			auth := c.Request().Header.Get(echo.HeaderAuthorization)
+			if len(auth) <= len("Bearer ") || !strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+				return models.ErrMissingToken
+			}
+
+			principal, err := ParseAccessToken(auth[len("Bearer "):])
+			if err != nil {
+				return models.ErrInvalidToken
+			}
+			if principal.ImpersonatorID != "" {
+				c.Response().Header().Set(ImpersonatedByHeader, principal.ImpersonatorID)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+			defer cancel()
+
+			if err := ValidateSession(ctx, db, principal.SessionID); err != nil {
+				if errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionRevoked) || errors.Is(err, ErrSessionExpired) {
+					return models.ErrSessionInvalid
+				}
+				return models.ErrInternal.WithMessage("Failed to validate session").Wrap(err)
+			}
+
+			if err := ValidateAccount(ctx, db, principal.UserID); err != nil {
+				switch {
+				case errors.Is(err, ErrAccountSuspended):
+					return models.ErrAccountSuspended
+				case errors.Is(err, ErrAccountNotFound):
+					return models.ErrAccountNotFound
+				default:
+					return models.ErrInternal.WithMessage("Failed to validate account").Wrap(err)
+				}
+			}
+
+			// Impersonation ends as soon as the admin loses access or the impersonate permission
+			if principal.ImpersonatorID != "" {
+				if err := ValidateImpersonator(ctx, db, principal.ImpersonatorID); err != nil {
+					if errors.Is(err, ErrAccountNotFound) || errors.Is(err, ErrAccountSuspended) || errors.Is(err, ErrImpersonatorNotAllowed) {
+						return models.ErrImpersonationInvalid
+					}
+					return models.ErrInternal.WithMessage("Failed to validate impersonator").Wrap(err)
+				}
+			}
+
+			if err := TouchSession(ctx, db, principal.SessionID, c.RealIP()); err != nil {
+				log.Printf("Failed to update last seen time of session %s: %v", principal.SessionID, err)
+			}
+
+			c.Set(principalContextKey, principal)
+			return next(c)
+		}
+	}
 }
 
-// GetUserFromToken extracts user information from JWT token
-func GetUserFromToken(c echo.Context) *JwtCustomClaims {
-	user := c.Get("user").(*jwt.Token)
+// ParseAccessToken verifies an access token and returns the principal it identifies
+func ParseAccessToken(raw string) (*Principal, error) {
+	claims := &JwtCustomClaims{}
+	token, err := jwt.ParseWithClaims(raw, claims, SigningKeys().Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
 
-	// Handle both MapClaims and JwtCustomClaims
-	if claims, ok := user.Claims.(*JwtCustomClaims); ok {
-		return claims
+	// Valid() skips exp when it is absent, and every access token must carry a user and session.
+	// Access tokens have no audience; audience-bound tokens such as 2FA challenges are rejected.
+	if claims.ExpiresAt == 0 || claims.UserID == "" || claims.SessionID == "" || claims.Audience != "" {
+		return nil, ErrInvalidToken
+	}
+	if claims.Act != nil && claims.Act.Subject == "" {
+		return nil, ErrInvalidToken
 	}
 
-	if mapClaims, ok := user.Claims.(jwt.MapClaims); ok {
-		// Convert map claims to your custom claims structure
-		return &JwtCustomClaims{
-			UserID:   mapClaims["userId"].(string),
-			UserType: mapClaims["userType"].(string),
-			// Add other fields as needed
-		}
+	principal := &Principal{
+		UserID:    claims.UserID,
+		Email:     claims.Email,
+		UserType:  claims.UserType,
+		SessionID: claims.SessionID,
 	}
+	if claims.Act != nil {
+		principal.ImpersonatorID = claims.Act.Subject
+	}
+	return principal, nil
+}
 
-	return nil
+// GetPrincipal returns the caller stored by Authenticate, or nil on unauthenticated routes
+func GetPrincipal(c echo.Context) *Principal {
+	principal, _ := c.Get(principalContextKey).(*Principal)
+	return principal
 }
 
-func ExtractUserID(c echo.Context) (string, error) {
-	user := c.Get("user").(*jwt.Token)
-	if user == nil {
-		return "", errors.New("invalid token")
-	}
+// GenerateJWT generates a new access token bound to a session
+func GenerateJWT(userID, email, userType, sessionID string) (string, error) {
+	// Set expiration time
+	expiration := time.Now().Add(AccessTokenTTL())
 
-	claims := user.Claims.(jwt.MapClaims)
-	// Try to get userId first (as per your JwtCustomClaims structure)
-	if userID, ok := claims["userId"].(string); ok {
-		return userID, nil
-	}
-	// Fallback to id if userId isn't found
-	if userID, ok := claims["id"].(string); ok {
-		return userID, nil
+	// Set custom claims
+	claims := &JwtCustomClaims{
+		UserID:    userID,
+		Email:     email,
+		UserType:  userType,
+		SessionID: sessionID,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: expiration.Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
 	}
 
-	return "", errors.New("invalid user ID in token")
+	// Sign with the active key; its kid lets verifiers pick the right public key
+	return SigningKeys().Sign(claims)
 }
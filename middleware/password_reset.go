@@ -0,0 +1,48 @@
+// middleware/password_reset.go
+package middleware
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/HSouheill/barrim_backend/config"
+)
+
+// passwordResetAudience marks reset tokens so they can never pass as access or challenge tokens
+const passwordResetAudience = "barrim:password-reset"
+
+// PasswordResetTokenTTL returns how long a reset token stays valid after the OTP is verified
+func PasswordResetTokenTTL() time.Duration {
+	return config.GetEnvDuration("PASSWORD_RESET_TOKEN_TTL", time.Hour)
+}
+
+// GeneratePasswordResetToken issues the token exchanged for a new password once the reset OTP is verified.
+// Each token carries a unique id, so its hash identifies exactly one verification.
+func GeneratePasswordResetToken(userID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(PasswordResetTokenTTL())
+	token, err := SigningKeys().Sign(&jwt.StandardClaims{
+		Id:        primitive.NewObjectID().Hex(),
+		Subject:   userID,
+		Audience:  passwordResetAudience,
+		ExpiresAt: expiresAt.Unix(),
+		IssuedAt:  now.Unix(),
+	})
+	return token, expiresAt, err
+}
+
+// ParsePasswordResetToken verifies a reset token's signature and expiry and returns the user it was issued to.
+// Whether the token is still the current, unused one is checked against the stored hash.
+func ParsePasswordResetToken(raw string) (string, error) {
+	claims := &jwt.StandardClaims{}
+	token, err := jwt.ParseWithClaims(raw, claims, SigningKeys().Keyfunc)
+	if err != nil || !token.Valid {
+		return "", ErrInvalidToken
+	}
+	if claims.ExpiresAt == 0 || claims.Subject == "" || claims.Id == "" || !claims.VerifyAudience(passwordResetAudience, true) {
+		return "", ErrInvalidToken
+	}
+	return claims.Subject, nil
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestPasswordResetToken(t *testing.T) {
+	useEphemeralKeys(t)
+
+	token, expiresAt, err := GeneratePasswordResetToken("user-id")
+	if err != nil {
+		t.Fatalf("GeneratePasswordResetToken() error = %v", err)
+	}
+	if time.Until(expiresAt) <= 0 {
+		t.Errorf("GeneratePasswordResetToken() expiry = %v, want a future time", expiresAt)
+	}
+	subject, err := ParsePasswordResetToken(token)
+	if err != nil || subject != "user-id" {
+		t.Fatalf("ParsePasswordResetToken() = %q, %v, want user-id", subject, err)
+	}
+
+	// Every verification gets its own token, so the stored hash identifies one of them
+	other, _, err := GeneratePasswordResetToken("user-id")
+	if err != nil {
+		t.Fatalf("GeneratePasswordResetToken() error = %v", err)
+	}
+	if other == token {
+		t.Error("GeneratePasswordResetToken() issued the same token twice")
+	}
+
+	// A reset token is not an access token
+	if _, err := ParseAccessToken(token); err != ErrInvalidToken {
+		t.Errorf("ParseAccessToken(reset token) error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestParsePasswordResetTokenRejects(t *testing.T) {
+	useEphemeralKeys(t)
+
+	sign := func(claims jwt.StandardClaims) string {
+		token, err := SigningKeys().Sign(&claims)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return token
+	}
+	valid := func() jwt.StandardClaims {
+		return jwt.StandardClaims{
+			Id:        "id",
+			Subject:   "user-id",
+			Audience:  passwordResetAudience,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	accessToken, err := GenerateJWT("user-id", "user@example.com", "user", "session-id")
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"access token", accessToken},
+		{"expired", func() string { c := valid(); c.ExpiresAt = time.Now().Add(-time.Minute).Unix(); return sign(c) }()},
+		{"no expiry", func() string { c := valid(); c.ExpiresAt = 0; return sign(c) }()},
+		{"other audience", func() string { c := valid(); c.Audience = "barrim:2fa"; return sign(c) }()},
+		{"no token id", func() string { c := valid(); c.Id = ""; return sign(c) }()},
+		{"no subject", func() string { c := valid(); c.Subject = ""; return sign(c) }()},
+		{"garbage", "not-a-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePasswordResetToken(tt.token); err != ErrInvalidToken {
+				t.Errorf("ParsePasswordResetToken() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
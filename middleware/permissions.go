@@ -0,0 +1,130 @@
+// middleware/permissions.go
+package middleware
+
+import (
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Permission names an action that a role may be allowed to perform
+type Permission string
+
+// Permissions checked by routes and handlers
+const (
+	PermProfileRead               Permission = "profile:read"
+	PermProfileWrite              Permission = "profile:write"
+	PermAccountDelete             Permission = "account:delete"
+	PermCredentialsManage         Permission = "credentials:manage"
+	PermDataExport                Permission = "data:export"
+	PermLocationWrite             Permission = "location:write"
+	PermCompaniesList             Permission = "companies:list"
+	PermCompanyRead               Permission = "company:read"
+	PermCompanyWrite              Permission = "company:write"
+	PermCompanyLogoWrite          Permission = "company:logo:write"
+	PermBranchRead                Permission = "branch:read"
+	PermBranchWrite               Permission = "branch:write"
+	PermBranchDelete              Permission = "branch:delete"
+	PermBranchReadAny             Permission = "branch:read:any"
+	PermProviderAvailabilityWrite Permission = "provider:availability:write"
+	PermProviderPhotoWrite        Permission = "provider:photo:write"
+	PermUsersList                 Permission = "users:list"
+	PermUsersManage               Permission = "users:manage"
+	PermUsersImpersonate          Permission = "users:impersonate"
+	PermSettingsManage            Permission = "settings:manage"
+	PermAuditRead                 Permission = "audit:read"
+	PermAPIKeysManage             Permission = "apikeys:manage"
+)
+
+// basePermissions are granted to every authenticated account
+var basePermissions = []Permission{
+	PermProfileRead,
+	PermProfileWrite,
+	PermAccountDelete,
+	PermCredentialsManage,
+	PermDataExport,
+	PermLocationWrite,
+	PermCompaniesList,
+	PermBranchRead,
+}
+
+// rolePermissions maps each user type to the permissions it grants on top of basePermissions
+var rolePermissions = map[string][]Permission{
+	"user": {},
+	"company": {
+		PermCompanyRead,
+		PermCompanyWrite,
+		PermCompanyLogoWrite,
+		PermBranchWrite,
+		PermBranchDelete,
+		PermAPIKeysManage,
+	},
+	"wholesaler": {
+		PermCompanyWrite,
+		PermBranchWrite,
+		PermBranchDelete,
+	},
+	"serviceProvider": {
+		PermProviderAvailabilityWrite,
+		PermProviderPhotoWrite,
+	},
+	"admin": {
+		PermBranchReadAny,
+		PermUsersList,
+		PermUsersManage,
+		PermUsersImpersonate,
+		PermSettingsManage,
+		PermAuditRead,
+	},
+}
+
+// HasPermission reports whether the role grants the permission. Unknown roles grant nothing.
+func HasPermission(role string, perm Permission) bool {
+	granted, ok := rolePermissions[role]
+	if !ok {
+		return false
+	}
+	for _, p := range basePermissions {
+		if p == perm {
+			return true
+		}
+	}
+	for _, p := range granted {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizeOwned reports whether the authenticated caller may use perm on a resource owned by ownerID.
+// Owners need perm itself; anyone else needs anyPerm (pass "" when no such permission exists).
+func AuthorizeOwned(c echo.Context, ownerID string, perm, anyPerm Permission) bool {
+	principal := GetPrincipal(c)
+	if principal == nil {
+		return false
+	}
+	if principal.UserID != "" && principal.UserID == ownerID {
+		return principal.HasPermission(perm)
+	}
+	return anyPerm != "" && principal.HasPermission(anyPerm)
+}
+
+// Authorize reports whether the authenticated caller holds the permission
+func Authorize(c echo.Context, perm Permission) bool {
+	principal := GetPrincipal(c)
+	return principal != nil && principal.HasPermission(perm)
+}
+
+// RequirePermission rejects requests from users that lack any of the given permissions
+func RequirePermission(perms ...Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for _, perm := range perms {
+				if !Authorize(c, perm) {
+					return models.ErrPermissionDenied
+				}
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/labstack/echo/v4"
+)
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{"user", PermProfileRead, true},
+		{"user", PermAccountDelete, true},
+		{"user", PermBranchRead, true},
+		{"user", PermCompanyWrite, false},
+		{"user", PermBranchWrite, false},
+		{"user", PermUsersList, false},
+		{"company", PermProfileWrite, true},
+		{"company", PermCompanyRead, true},
+		{"company", PermCompanyLogoWrite, true},
+		{"company", PermBranchWrite, true},
+		{"company", PermBranchDelete, true},
+		{"company", PermAPIKeysManage, true},
+		{"company", PermProviderPhotoWrite, false},
+		{"company", PermBranchReadAny, false},
+		{"wholesaler", PermCompanyWrite, true},
+		{"wholesaler", PermBranchDelete, true},
+		{"wholesaler", PermCompanyRead, false},
+		{"wholesaler", PermAPIKeysManage, false},
+		{"serviceProvider", PermProviderAvailabilityWrite, true},
+		{"serviceProvider", PermProviderPhotoWrite, true},
+		{"serviceProvider", PermBranchWrite, false},
+		{"admin", PermUsersManage, true},
+		{"admin", PermUsersImpersonate, true},
+		{"admin", PermAuditRead, true},
+		{"admin", PermBranchReadAny, true},
+		{"admin", PermProfileRead, true},
+		{"admin", PermCompanyWrite, false},
+		{"unknown", PermProfileRead, false},
+		{"", PermProfileRead, false},
+	}
+	for _, tt := range tests {
+		if got := HasPermission(tt.role, tt.perm); got != tt.want {
+			t.Errorf("HasPermission(%q, %q) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedWhileImpersonating(t *testing.T) {
+	tests := []struct {
+		perm Permission
+		want bool
+	}{
+		{PermProfileRead, true},
+		{PermProfileWrite, true},
+		{PermCompanyWrite, true},
+		{PermBranchWrite, true},
+		{PermBranchDelete, false},
+		{PermAccountDelete, false},
+		{PermCredentialsManage, false},
+		{PermDataExport, false},
+		{PermAPIKeysManage, false},
+		{PermUsersManage, false},
+		{PermUsersImpersonate, false},
+		{PermSettingsManage, false},
+	}
+	for _, tt := range tests {
+		if got := allowedWhileImpersonating(tt.perm); got != tt.want {
+			t.Errorf("allowedWhileImpersonating(%q) = %v, want %v", tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestPrincipalHasPermission(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		perm      Permission
+		want      bool
+	}{
+		{"login with role permission", Principal{UserType: "company"}, PermBranchDelete, true},
+		{"login without role permission", Principal{UserType: "user"}, PermBranchWrite, false},
+		{"impersonation allows ordinary permission", Principal{UserType: "company", ImpersonatorID: "admin"}, PermBranchWrite, true},
+		{"impersonation denies destructive permission", Principal{UserType: "company", ImpersonatorID: "admin"}, PermBranchDelete, false},
+		{"impersonation denies account deletion", Principal{UserType: "user", ImpersonatorID: "admin"}, PermAccountDelete, false},
+		{"api key scope grants permission", Principal{UserType: "company", APIKeyID: "key", Scopes: []string{"branches:write"}}, PermBranchWrite, true},
+		{"api key scope outside key", Principal{UserType: "company", APIKeyID: "key", Scopes: []string{"branches:read"}}, PermBranchWrite, false},
+		{"api key without scopes", Principal{UserType: "company", APIKeyID: "key"}, PermProfileRead, false},
+		{"api key scope beyond role", Principal{UserType: "user", APIKeyID: "key", Scopes: []string{"company:write"}}, PermCompanyWrite, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.HasPermission(tt.perm); got != tt.want {
+				t.Errorf("HasPermission(%q) = %v, want %v", tt.perm, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeOwned(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *Principal
+		ownerID   string
+		anyPerm   Permission
+		want      bool
+	}{
+		{"owner with permission", &Principal{UserID: "u1", UserType: "company"}, "u1", "", true},
+		{"owner without permission", &Principal{UserID: "u1", UserType: "user"}, "u1", "", false},
+		{"other user", &Principal{UserID: "u2", UserType: "company"}, "u1", "", false},
+		{"admin with any permission", &Principal{UserID: "a1", UserType: "admin"}, "u1", PermBranchReadAny, true},
+		{"other user without any permission", &Principal{UserID: "u2", UserType: "company"}, "u1", PermBranchReadAny, false},
+		{"unauthenticated", nil, "u1", PermBranchReadAny, false},
+	}
+	e := echo.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			if tt.principal != nil {
+				c.Set(principalContextKey, tt.principal)
+			}
+			if got := AuthorizeOwned(c, tt.ownerID, PermBranchWrite, tt.anyPerm); got != tt.want {
+				t.Errorf("AuthorizeOwned() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *Principal
+		perms     []Permission
+		wantErr   error
+	}{
+		{"all permissions held", &Principal{UserType: "company"}, []Permission{PermCompanyRead, PermCompanyWrite}, nil},
+		{"one permission missing", &Principal{UserType: "wholesaler"}, []Permission{PermCompanyRead, PermCompanyWrite}, models.ErrPermissionDenied},
+		{"unauthenticated", nil, []Permission{PermProfileRead}, models.ErrPermissionDenied},
+	}
+	e := echo.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			if tt.principal != nil {
+				c.Set(principalContextKey, tt.principal)
+			}
+			called := false
+			handler := RequirePermission(tt.perms...)(func(c echo.Context) error {
+				called = true
+				return nil
+			})
+			err := handler(c)
+			if err != tt.wantErr {
+				t.Fatalf("handler error = %v, want %v", err, tt.wantErr)
+			}
+			if called != (tt.wantErr == nil) {
+				t.Errorf("next handler called = %v, want %v", called, tt.wantErr == nil)
+			}
+		})
+	}
+}
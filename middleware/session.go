@@ -0,0 +1,404 @@
+// middleware/session.go
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// Session errors
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionRevoked     = errors.New("session has been revoked")
+	ErrSessionExpired     = errors.New("session has expired")
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+	ErrAccountNotFound    = errors.New("account not found")
+	ErrAccountSuspended   = errors.New("account is suspended")
+)
+
+// maxPreviousTokenHashes bounds how many rotated refresh tokens are remembered for reuse detection
+const maxPreviousTokenHashes = 10
+
+// maxDeviceFieldLength caps client supplied device details stored on a session
+const maxDeviceFieldLength = 128
+
+// lastSeenInterval limits how often a session's last seen time is written
+const lastSeenInterval = time.Minute
+
+// TokenPair holds the tokens issued for a session
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // access token lifetime in seconds
+	SessionID    string
+	NewDevice    bool // the user has logged in before, but never from this device
+}
+
+// AccessTokenTTL returns the lifetime of access tokens
+func AccessTokenTTL() time.Duration {
+	return config.GetEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// RefreshTokenTTL returns how long a session stays valid without being refreshed
+func RefreshTokenTTL() time.Duration {
+	return config.GetEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// DeviceFromRequest reads the device details sent by the client in the X-Device-* headers
+func DeviceFromRequest(c echo.Context) models.DeviceInfo {
+	header := c.Request().Header
+	return models.DeviceInfo{
+		Name:      truncate(header.Get("X-Device-Name"), maxDeviceFieldLength),
+		Platform:  truncate(header.Get("X-Device-Platform"), maxDeviceFieldLength),
+		DeviceID:  truncate(header.Get("X-Device-Id"), maxDeviceFieldLength),
+		UserAgent: truncate(c.Request().UserAgent(), maxDeviceFieldLength),
+	}
+}
+
+// IssueTokens creates a new session for the user and returns its first token pair
+func IssueTokens(ctx context.Context, db *mongo.Client, userID primitive.ObjectID, email, userType string, device models.DeviceInfo, ip string) (*TokenPair, error) {
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := config.GetCollection(db, "sessions")
+	newDevice, err := isNewDevice(ctx, sessions, userID, device)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:               primitive.NewObjectID(),
+		UserID:           userID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		Device:           device,
+		IP:               ip,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(RefreshTokenTTL()),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	if _, err := sessions.InsertOne(ctx, session); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := GenerateJWT(userID.Hex(), email, userType, session.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(AccessTokenTTL().Seconds()),
+		SessionID:    session.ID.Hex(),
+		NewDevice:    newDevice,
+	}, nil
+}
+
+// isNewDevice reports whether the user has sessions on record but none from the device.
+// Revoked sessions count as history until they expire; a first ever login is not a new device.
+func isNewDevice(ctx context.Context, sessions *mongo.Collection, userID primitive.ObjectID, device models.DeviceInfo) (bool, error) {
+	// Sessions opened by an impersonating admin are not the user's devices
+	previous, err := sessions.CountDocuments(ctx, bson.M{"userId": userID, "impersonatorId": bson.M{"$exists": false}}, options.Count().SetLimit(1))
+	if err != nil || previous == 0 {
+		return false, err
+	}
+
+	filter := bson.M{"userId": userID, "impersonatorId": bson.M{"$exists": false}, "device.deviceId": device.DeviceID}
+	if device.DeviceID == "" {
+		// Without a device ID, fall back to whichever of the name, platform and user agent were
+		// reported. Empty values are not stored, so matching on them would never find a session.
+		filter = bson.M{"userId": userID, "impersonatorId": bson.M{"$exists": false}}
+		for field, value := range map[string]string{
+			"device.name":      device.Name,
+			"device.platform":  device.Platform,
+			"device.userAgent": device.UserAgent,
+		} {
+			if value != "" {
+				filter[field] = value
+			}
+		}
+	}
+	known, err := sessions.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return known == 0, nil
+}
+
+// RefreshTokens rotates the refresh token of a session and issues a new access token.
+// Presenting a refresh token that was already rotated revokes the whole session.
+func RefreshTokens(ctx context.Context, db *mongo.Client, refreshToken, ip string) (*TokenPair, error) {
+	sessions := config.GetCollection(db, "sessions")
+	tokenHash := utils.HashToken(refreshToken)
+
+	var session models.Session
+	err := sessions.FindOne(ctx, bson.M{"refreshTokenHash": tokenHash}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		// An old token being replayed means it was probably stolen
+		err = sessions.FindOne(ctx, bson.M{"previousTokenHashes": tokenHash}).Decode(&session)
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSessionNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := revokeSessions(ctx, db, bson.M{"_id": session.ID}); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+	// Impersonation sessions end with their access token
+	if !session.ImpersonatorID.IsZero() {
+		return nil, ErrSessionNotFound
+	}
+
+	// Reload the user so role changes are reflected in the new access token
+	var user models.User
+	err = config.GetCollection(db, "users").FindOne(ctx, bson.M{"_id": session.UserID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Suspended {
+		return nil, ErrAccountSuspended
+	}
+	if user.PendingDeletion() {
+		return nil, ErrSessionNotFound
+	}
+
+	newRefreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := sessions.UpdateOne(
+		ctx,
+		// Matching on the old hash makes concurrent refreshes of the same token fail
+		bson.M{"_id": session.ID, "refreshTokenHash": tokenHash},
+		bson.M{
+			"$set": bson.M{
+				"refreshTokenHash": utils.HashToken(newRefreshToken),
+				"ip":               ip,
+				"lastSeenAt":       now,
+				"expiresAt":        now.Add(RefreshTokenTTL()),
+				"updatedAt":        now,
+			},
+			"$push": bson.M{
+				"previousTokenHashes": bson.M{
+					"$each":  []string{tokenHash},
+					"$slice": -maxPreviousTokenHashes,
+				},
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrRefreshTokenReused
+	}
+
+	accessToken, err := GenerateJWT(user.ID.Hex(), user.Email, user.UserType, session.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int64(AccessTokenTTL().Seconds()),
+		SessionID:    session.ID.Hex(),
+	}, nil
+}
+
+// ValidateSession checks that the session referenced by an access token is still active
+func ValidateSession(ctx context.Context, db *mongo.Client, sessionID string) error {
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+
+	var session models.Session
+	err = config.GetCollection(db, "sessions").FindOne(ctx, bson.M{"_id": id}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if session.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// TouchSession records that the session was just used from the IP.
+// Writes are skipped while the last seen time is recent to keep requests cheap.
+func TouchSession(ctx context.Context, db *mongo.Client, sessionID, ip string) error {
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+
+	now := time.Now()
+	_, err = config.GetCollection(db, "sessions").UpdateOne(
+		ctx,
+		bson.M{"_id": id, "$or": bson.A{
+			bson.M{"lastSeenAt": bson.M{"$lt": now.Add(-lastSeenInterval)}},
+			bson.M{"lastSeenAt": bson.M{"$exists": false}},
+		}},
+		bson.M{"$set": bson.M{"lastSeenAt": now, "ip": ip}},
+	)
+	return err
+}
+
+// ListUserSessions returns the active sessions of the user, most recently used first
+func ListUserSessions(ctx context.Context, db *mongo.Client, userID primitive.ObjectID) ([]models.Session, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}})
+	cursor, err := config.GetCollection(db, "sessions").Find(
+		ctx,
+		bson.M{
+			"userId":    userID,
+			"revokedAt": bson.M{"$exists": false},
+			"expiresAt": bson.M{"$gt": time.Now()},
+		},
+		opts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// ValidateAccount checks that the user behind a token still exists and is not suspended.
+// Accounts pending deletion count as gone.
+func ValidateAccount(ctx context.Context, db *mongo.Client, userID string) error {
+	_, err := activeAccount(ctx, db, userID)
+	return err
+}
+
+// activeAccount loads the status and role of an account that is neither suspended nor pending deletion
+func activeAccount(ctx context.Context, db *mongo.Client, userID string) (*models.User, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+
+	var user models.User
+	opts := options.FindOne().SetProjection(bson.M{"userType": 1, "suspended": 1, "deletionScheduledAt": 1})
+	err = config.GetCollection(db, "users").FindOne(ctx, bson.M{"_id": id}, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.PendingDeletion() {
+		return nil, ErrAccountNotFound
+	}
+
+	if user.Suspended {
+		return nil, ErrAccountSuspended
+	}
+	return &user, nil
+}
+
+// RevokeSession revokes a single session belonging to the user
+func RevokeSession(ctx context.Context, db *mongo.Client, userID, sessionID primitive.ObjectID) error {
+	result, err := config.GetCollection(db, "sessions").UpdateOne(
+		ctx,
+		bson.M{"_id": sessionID, "userId": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every active session of the user except the one given.
+// Pass primitive.NilObjectID to revoke all of them.
+func RevokeUserSessions(ctx context.Context, db *mongo.Client, userID, exceptSessionID primitive.ObjectID) error {
+	filter := bson.M{"userId": userID}
+	if !exceptSessionID.IsZero() {
+		filter["_id"] = bson.M{"$ne": exceptSessionID}
+	}
+	return revokeSessions(ctx, db, filter)
+}
+
+// RevokeImpersonationSessions revokes every session an admin opened as another user
+func RevokeImpersonationSessions(ctx context.Context, db *mongo.Client, impersonatorID primitive.ObjectID) error {
+	return revokeSessions(ctx, db, bson.M{"impersonatorId": impersonatorID})
+}
+
+func revokeSessions(ctx context.Context, db *mongo.Client, filter bson.M) error {
+	filter["revokedAt"] = bson.M{"$exists": false}
+	_, err := config.GetCollection(db, "sessions").UpdateMany(
+		ctx,
+		filter,
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "updatedAt": time.Now()}},
+	)
+	return err
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// generateRefreshToken returns a random URL-safe refresh token
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
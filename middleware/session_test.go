@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// useEphemeralKeys makes token signing work without a keys directory
+func useEphemeralKeys(t *testing.T) {
+	t.Helper()
+	keyRingOnce.Do(func() {
+		ring, err := NewEphemeralKeyRing()
+		if err != nil {
+			t.Fatalf("NewEphemeralKeyRing() error = %v", err)
+		}
+		keyRing = ring
+	})
+}
+
+// findResponse answers a find command with the documents
+func findResponse(ns string, docs ...bson.D) bson.D {
+	return mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, docs...)
+}
+
+// updateResponse answers an update command as if matched documents were modified
+func updateResponse(matched int) bson.D {
+	return bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: matched}, {Key: "nModified", Value: matched}}
+}
+
+func sessionDoc(id, userID primitive.ObjectID, tokenHash string, extra ...bson.E) bson.D {
+	doc := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "userId", Value: userID},
+		{Key: "refreshTokenHash", Value: tokenHash},
+		{Key: "expiresAt", Value: time.Now().Add(time.Hour)},
+	}
+	return append(doc, extra...)
+}
+
+func userDoc(id primitive.ObjectID, extra ...bson.E) bson.D {
+	doc := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "email", Value: "user@example.com"},
+		{Key: "userType", Value: "company"},
+	}
+	return append(doc, extra...)
+}
+
+// updateCommand returns the first update statement of a started update command
+func updateCommand(mt *mtest.T) (filter, update bson.Raw) {
+	mt.Helper()
+	for {
+		event := mt.GetStartedEvent()
+		if event == nil {
+			mt.Fatal("no update command was sent")
+		}
+		if event.CommandName != "update" {
+			continue
+		}
+		stmt := event.Command.Lookup("updates").Array().Index(0).Value().Document()
+		return stmt.Lookup("q").Document(), stmt.Lookup("u").Document()
+	}
+}
+
+func TestRefreshTokensRotates(t *testing.T) {
+	useEphemeralKeys(t)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("current token", func(mt *mtest.T) {
+		sessionID, userID := primitive.NewObjectID(), primitive.NewObjectID()
+		oldToken := "old-refresh-token"
+		mt.AddMockResponses(
+			findResponse("barrim.sessions", sessionDoc(sessionID, userID, utils.HashToken(oldToken))),
+			findResponse("barrim.users", userDoc(userID)),
+			updateResponse(1),
+		)
+
+		pair, err := RefreshTokens(context.Background(), mt.Client, oldToken, "203.0.113.7")
+		if err != nil {
+			mt.Fatalf("RefreshTokens() error = %v", err)
+		}
+		if pair.RefreshToken == "" || pair.RefreshToken == oldToken {
+			mt.Errorf("RefreshTokens() refresh token = %q, want a new token", pair.RefreshToken)
+		}
+		if pair.SessionID != sessionID.Hex() {
+			mt.Errorf("RefreshTokens() session = %q, want %q", pair.SessionID, sessionID.Hex())
+		}
+		principal, err := ParseAccessToken(pair.AccessToken)
+		if err != nil {
+			mt.Fatalf("ParseAccessToken() error = %v", err)
+		}
+		if principal.UserID != userID.Hex() || principal.SessionID != sessionID.Hex() {
+			mt.Errorf("access token principal = %+v, want user %s in session %s", principal, userID.Hex(), sessionID.Hex())
+		}
+
+		filter, update := updateCommand(mt)
+		if got := filter.Lookup("refreshTokenHash").StringValue(); got != utils.HashToken(oldToken) {
+			mt.Errorf("update filter hash = %q, want the presented token's hash", got)
+		}
+		if got := update.Lookup("$set", "refreshTokenHash").StringValue(); got != utils.HashToken(pair.RefreshToken) {
+			mt.Errorf("stored hash = %q, want the new token's hash", got)
+		}
+		pushed := update.Lookup("$push", "previousTokenHashes", "$each").Array().Index(0).Value().StringValue()
+		if pushed != utils.HashToken(oldToken) {
+			mt.Errorf("remembered hash = %q, want the presented token's hash", pushed)
+		}
+	})
+}
+
+func TestRefreshTokensRejects(t *testing.T) {
+	useEphemeralKeys(t)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	sessionID, userID := primitive.NewObjectID(), primitive.NewObjectID()
+	token := "refresh-token"
+	hash := utils.HashToken(token)
+
+	mt.Run("replayed rotated token revokes the session", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			findResponse("barrim.sessions"),
+			findResponse("barrim.sessions", sessionDoc(sessionID, userID, "newer-hash", bson.E{Key: "previousTokenHashes", Value: bson.A{hash}})),
+			updateResponse(1),
+		)
+
+		_, err := RefreshTokens(context.Background(), mt.Client, token, "")
+		if err != ErrRefreshTokenReused {
+			mt.Fatalf("RefreshTokens() error = %v, want %v", err, ErrRefreshTokenReused)
+		}
+		filter, update := updateCommand(mt)
+		if got := filter.Lookup("_id").ObjectID(); got != sessionID {
+			mt.Errorf("revoked session = %s, want %s", got.Hex(), sessionID.Hex())
+		}
+		if _, err := update.LookupErr("$set", "revokedAt"); err != nil {
+			mt.Error("replay did not set revokedAt on the session")
+		}
+	})
+
+	mt.Run("concurrent refresh loses the race", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			findResponse("barrim.sessions", sessionDoc(sessionID, userID, hash)),
+			findResponse("barrim.users", userDoc(userID)),
+			updateResponse(0),
+		)
+
+		if _, err := RefreshTokens(context.Background(), mt.Client, token, ""); err != ErrRefreshTokenReused {
+			mt.Fatalf("RefreshTokens() error = %v, want %v", err, ErrRefreshTokenReused)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		responses []bson.D
+		want      error
+	}{
+		{"unknown token", []bson.D{
+			findResponse("barrim.sessions"),
+			findResponse("barrim.sessions"),
+		}, ErrSessionNotFound},
+		{"revoked session", []bson.D{
+			findResponse("barrim.sessions", sessionDoc(sessionID, userID, hash, bson.E{Key: "revokedAt", Value: time.Now()})),
+		}, ErrSessionRevoked},
+		{"expired session", []bson.D{
+			findResponse("barrim.sessions", bson.D{
+				{Key: "_id", Value: sessionID},
+				{Key: "userId", Value: userID},
+				{Key: "refreshTokenHash", Value: hash},
+				{Key: "expiresAt", Value: time.Now().Add(-time.Minute)},
+			}),
+		}, ErrSessionExpired},
+		{"impersonation session", []bson.D{
+			findResponse("barrim.sessions", sessionDoc(sessionID, userID, hash, bson.E{Key: "impersonatorId", Value: primitive.NewObjectID()})),
+		}, ErrSessionNotFound},
+		{"suspended user", []bson.D{
+			findResponse("barrim.sessions", sessionDoc(sessionID, userID, hash)),
+			findResponse("barrim.users", userDoc(userID, bson.E{Key: "suspended", Value: true})),
+		}, ErrAccountSuspended},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(tt.responses...)
+			if _, err := RefreshTokens(context.Background(), mt.Client, token, ""); err != tt.want {
+				mt.Fatalf("RefreshTokens() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
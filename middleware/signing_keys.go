@@ -0,0 +1,221 @@
+// middleware/signing_keys.go
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+// SigningKey is one key of the key ring. Retired keys only carry the public half.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeyRing holds the key used to sign new tokens and every key still accepted for verification
+type KeyRing struct {
+	Active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+var (
+	keyRing     *KeyRing
+	keyRingOnce sync.Once
+)
+
+// SigningKeys returns the process-wide key ring, loading it on first use.
+// JWT_EPHEMERAL_KEYS=true allows running without JWT_KEYS_DIR during local development only.
+func SigningKeys() *KeyRing {
+	keyRingOnce.Do(func() {
+		var ring *KeyRing
+		var err error
+		dir := os.Getenv("JWT_KEYS_DIR")
+		if dir == "" && config.GetEnvBool("JWT_EPHEMERAL_KEYS", false) {
+			log.Println("Warning: JWT_EPHEMERAL_KEYS is set, signing tokens with a key that is lost on restart")
+			ring, err = NewEphemeralKeyRing()
+		} else {
+			ring, err = LoadKeyRing(dir, os.Getenv("JWT_ACTIVE_KID"))
+		}
+		if err != nil {
+			log.Fatalf("Failed to load JWT signing keys: %v", err)
+		}
+		keyRing = ring
+	})
+	return keyRing
+}
+
+// LoadKeyRing reads every *.pem file in dir; the file name without extension is the key id.
+// Private keys (RSA or Ed25519) can sign, public keys keep tokens of retired keys verifiable.
+// scripts/generate-jwt-key.sh creates a key in the expected format.
+func LoadKeyRing(dir, activeKID string) (*KeyRing, error) {
+	if dir == "" {
+		return nil, errors.New("JWT_KEYS_DIR is not set; run scripts/generate-jwt-key.sh to create a signing key")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	ring := &KeyRing{keys: make(map[string]*SigningKey)}
+	var signers []string
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseSigningKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		ring.keys[kid] = key
+		if key.Private != nil {
+			signers = append(signers, kid)
+		}
+	}
+
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no private signing key found in %s", dir)
+	}
+	if activeKID == "" {
+		if len(signers) != 1 {
+			return nil, fmt.Errorf("JWT_ACTIVE_KID must be set when %s holds %d private keys", dir, len(signers))
+		}
+		activeKID = signers[0]
+	}
+
+	active, ok := ring.keys[activeKID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found in %s", activeKID, dir)
+	}
+	if active.Private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeKID)
+	}
+	ring.Active = active
+	return ring, nil
+}
+
+// NewEphemeralKeyRing creates a key ring with a single freshly generated Ed25519 key
+func NewEphemeralKeyRing() (*KeyRing, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	key := &SigningKey{
+		ID:      "ephemeral",
+		Method:  jwt.SigningMethodEdDSA,
+		Private: private,
+		Public:  public,
+	}
+	return &KeyRing{Active: key, keys: map[string]*SigningKey{key.ID: key}}, nil
+}
+
+// Key returns the key with the given id
+func (r *KeyRing) Key(kid string) (*SigningKey, bool) {
+	key, ok := r.keys[kid]
+	return key, ok
+}
+
+// Keyfunc resolves the verification key of a token from its kid header
+func (r *KeyRing) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := r.Key(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown jwt key id %q", kid)
+	}
+	// The algorithm is bound to the key, never taken from the token
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected jwt signing method=%v", t.Header["alg"])
+	}
+	return key.Public, nil
+}
+
+// Sign signs the claims with the active key
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(r.Active.Method, claims)
+	token.Header["kid"] = r.Active.ID
+	return token.SignedString(r.Active.Private)
+}
+
+// JWKS returns the public keys of the ring as a JSON Web Key Set
+func (r *KeyRing) JWKS() utils.JWKSet {
+	kids := make([]string, 0, len(r.keys))
+	for kid := range r.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := utils.JWKSet{Keys: []utils.JWK{}}
+	for _, kid := range kids {
+		switch public := r.keys[kid].Public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, utils.NewRSAJWK(kid, public))
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, utils.NewEd25519JWK(kid, public))
+		}
+	}
+	return set
+}
+
+// parseSigningKey decodes a PEM encoded RSA or Ed25519 private or public key
+func parseSigningKey(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	if rsaKey, ok := key.Public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+	return key, nil
+}
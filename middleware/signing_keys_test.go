@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func writePEM(t *testing.T, dir, kid, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+func writeRSAKey(t *testing.T, dir, kid string, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+	writePEM(t, dir, kid, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	return key
+}
+
+func writeEd25519Key(t *testing.T, dir, kid string, publicOnly bool) ed25519.PrivateKey {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey() error = %v", err)
+	}
+	var der []byte
+	blockType := "PRIVATE KEY"
+	if publicOnly {
+		der, err = x509.MarshalPKIXPublicKey(public)
+		blockType = "PUBLIC KEY"
+	} else {
+		der, err = x509.MarshalPKCS8PrivateKey(private)
+	}
+	if err != nil {
+		t.Fatalf("marshal key error = %v", err)
+	}
+	writePEM(t, dir, kid, blockType, der)
+	return private
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(t *testing.T, dir string)
+		activeKID string
+		wantKID   string
+	}{
+		{"single private key is active", func(t *testing.T, dir string) {
+			writeEd25519Key(t, dir, "2024", false)
+			writeEd25519Key(t, dir, "2023", true)
+		}, "", "2024"},
+		{"explicit active key", func(t *testing.T, dir string) {
+			writeEd25519Key(t, dir, "2024", false)
+			writeRSAKey(t, dir, "2025", 2048)
+		}, "2025", "2025"},
+		{"no keys", func(t *testing.T, dir string) {}, "", ""},
+		{"only public keys", func(t *testing.T, dir string) {
+			writeEd25519Key(t, dir, "2023", true)
+		}, "", ""},
+		{"several private keys without active id", func(t *testing.T, dir string) {
+			writeEd25519Key(t, dir, "2024", false)
+			writeEd25519Key(t, dir, "2025", false)
+		}, "", ""},
+		{"unknown active id", func(t *testing.T, dir string) {
+			writeEd25519Key(t, dir, "2024", false)
+		}, "2025", ""},
+		{"public active key", func(t *testing.T, dir string) {
+			writeEd25519Key(t, dir, "2024", false)
+			writeEd25519Key(t, dir, "2023", true)
+		}, "2023", ""},
+		{"short RSA key", func(t *testing.T, dir string) {
+			writeRSAKey(t, dir, "2024", 1024)
+		}, "", ""},
+		{"not a PEM file", func(t *testing.T, dir string) {
+			os.WriteFile(filepath.Join(dir, "2024.pem"), []byte("secret"), 0600)
+		}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(t, dir)
+			ring, err := LoadKeyRing(dir, tt.activeKID)
+			if tt.wantKID == "" {
+				if err == nil {
+					t.Fatal("LoadKeyRing() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadKeyRing() error = %v", err)
+			}
+			if ring.Active.ID != tt.wantKID {
+				t.Errorf("active key = %q, want %q", ring.Active.ID, tt.wantKID)
+			}
+		})
+	}
+
+	if _, err := LoadKeyRing("", ""); err == nil {
+		t.Error("LoadKeyRing() accepted an empty directory setting")
+	}
+}
+
+func TestKeyfunc(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := writeRSAKey(t, dir, "rsa", 2048)
+	edKey := writeEd25519Key(t, dir, "ed", false)
+	// Retired keys only keep their public half on disk
+	retiredPublic, retired, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey() error = %v", err)
+	}
+	writePEM(t, dir, "retired", "PUBLIC KEY", mustMarshalPKIX(t, retiredPublic))
+
+	ring, err := LoadKeyRing(dir, "rsa")
+	if err != nil {
+		t.Fatalf("LoadKeyRing() error = %v", err)
+	}
+
+	claims := jwt.StandardClaims{Subject: "user", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("SignedString() error = %v", err)
+		}
+		return signed
+	}
+	active, err := ring.Sign(claims)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"active key", active, true},
+		{"other private key", sign(jwt.SigningMethodEdDSA, "ed", edKey), true},
+		{"retired public key", sign(jwt.SigningMethodEdDSA, "retired", retired), true},
+		{"missing kid", sign(jwt.SigningMethodRS256, "", rsaKey), false},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "other", rsaKey), false},
+		{"algorithm not bound to kid", sign(jwt.SigningMethodEdDSA, "rsa", edKey), false},
+		{"rsa token under an ed25519 kid", sign(jwt.SigningMethodRS256, "ed", rsaKey), false},
+		{"hmac keyed with the public key", sign(jwt.SigningMethodHS256, "rsa", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)), false},
+		{"unsigned", sign(jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.ParseWithClaims(tt.token, &jwt.StandardClaims{}, ring.Keyfunc)
+			valid := err == nil && token.Valid
+			if valid != tt.valid {
+				t.Errorf("token valid = %v (error %v), want %v", valid, err, tt.valid)
+			}
+		})
+	}
+}
+
+func mustMarshalPKIX(t *testing.T, key interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+	return der
+}
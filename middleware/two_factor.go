@@ -0,0 +1,104 @@
+// middleware/two_factor.go
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/HSouheill/barrim_backend/config"
+	"github.com/HSouheill/barrim_backend/models"
+)
+
+// twoFactorChallengeAudience marks challenge tokens so they can never pass as access tokens
+const twoFactorChallengeAudience = "barrim:2fa-challenge"
+
+// TwoFactorChallengeTTL returns how long a user has to enter their code after the password step
+func TwoFactorChallengeTTL() time.Duration {
+	return config.GetEnvDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute)
+}
+
+// GenerateTwoFactorChallenge issues the short-lived token exchanged for a session once the code is verified
+func GenerateTwoFactorChallenge(userID string) (string, error) {
+	now := time.Now()
+	return SigningKeys().Sign(&jwt.StandardClaims{
+		Subject:   userID,
+		Audience:  twoFactorChallengeAudience,
+		ExpiresAt: now.Add(TwoFactorChallengeTTL()).Unix(),
+		IssuedAt:  now.Unix(),
+	})
+}
+
+// ParseTwoFactorChallenge verifies a challenge token and returns the user it was issued to
+func ParseTwoFactorChallenge(raw string) (string, error) {
+	claims := &jwt.StandardClaims{}
+	token, err := jwt.ParseWithClaims(raw, claims, SigningKeys().Keyfunc)
+	if err != nil || !token.Valid {
+		return "", ErrInvalidToken
+	}
+	if claims.ExpiresAt == 0 || claims.Subject == "" || !claims.VerifyAudience(twoFactorChallengeAudience, true) {
+		return "", ErrInvalidToken
+	}
+	return claims.Subject, nil
+}
+
+// LoadTwoFactorPolicy returns the admin-managed two-factor policy; no document means nothing is enforced
+func LoadTwoFactorPolicy(ctx context.Context, db *mongo.Client) (*models.TwoFactorPolicy, error) {
+	policy := &models.TwoFactorPolicy{ID: models.TwoFactorPolicySettingID}
+	err := config.GetCollection(db, "settings").FindOne(ctx, bson.M{"_id": models.TwoFactorPolicySettingID}).Decode(policy)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// RequireTwoFactorEnrollment blocks accounts whose user type must use two-factor authentication
+// until they have enabled it. The enrollment routes themselves live outside the guarded groups.
+func RequireTwoFactorEnrollment(db *mongo.Client) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := GetPrincipal(c)
+			if principal == nil {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+			defer cancel()
+
+			policy, err := LoadTwoFactorPolicy(ctx, db)
+			if err != nil {
+				return models.ErrInternal.WithMessage("Failed to load two-factor policy").Wrap(err)
+			}
+			if !policy.Requires(principal.UserType) {
+				return next(c)
+			}
+
+			userID, err := primitive.ObjectIDFromHex(principal.UserID)
+			if err != nil {
+				return models.ErrUnauthorized.WithMessage("Invalid user ID")
+			}
+
+			var user models.User
+			opts := options.FindOne().SetProjection(bson.M{"twoFactor.enabled": 1})
+			err = config.GetCollection(db, "users").FindOne(ctx, bson.M{"_id": userID}, opts).Decode(&user)
+			if err != nil {
+				if err == mongo.ErrNoDocuments {
+					return models.ErrAccountNotFound.WithMessage("User not found")
+				}
+				return models.ErrInternal.WithMessage("Failed to check two-factor status").Wrap(err)
+			}
+
+			if user.TwoFactor == nil || !user.TwoFactor.Enabled {
+				return models.ErrTwoFactorRequired.WithMessage("Two-factor authentication must be enabled for this account")
+			}
+
+			return next(c)
+		}
+	}
+}
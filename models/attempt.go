@@ -0,0 +1,19 @@
+// models/attempt.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuthAttempt counts failed authentication attempts for a key such as an account or an IP address
+type AuthAttempt struct {
+	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Key         string             `json:"key" bson:"key"`
+	Failures    int                `json:"failures" bson:"failures"`
+	LockedUntil *time.Time         `json:"lockedUntil,omitempty" bson:"lockedUntil,omitempty"`
+	ExpiresAt   time.Time          `json:"expiresAt" bson:"expiresAt"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,75 @@
+// models/audit.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions
+const (
+	AuditSignup               = "auth.signup"
+	AuditLogin                = "auth.login"
+	AuditLoginFailed          = "auth.login_failed"
+	AuditLogout               = "auth.logout"
+	AuditLogoutAll            = "auth.logout_all"
+	AuditSessionRevoked       = "auth.session_revoked"
+	AuditTwoFactorEnabled     = "auth.2fa_enabled"
+	AuditTwoFactorDisabled    = "auth.2fa_disabled"
+	AuditRecoveryCodesReset   = "auth.2fa_recovery_codes_regenerated"
+	AuditIdentityLinked       = "auth.identity_linked"
+	AuditIdentityUnlinked     = "auth.identity_unlinked"
+	AuditPasswordSet          = "password.set"
+	AuditPasswordChanged      = "password.changed"
+	AuditPasswordResetSent    = "password.reset_requested"
+	AuditPasswordReset        = "password.reset"
+	AuditProfileUpdated       = "user.profile_updated"
+	AuditLocationUpdated      = "user.location_updated"
+	AuditEmailChangeStarted   = "user.email_change_requested"
+	AuditEmailChanged         = "user.email_changed"
+	AuditPhoneVerified        = "user.phone_verified"
+	AuditAccountDeleted       = "user.deleted"
+	AuditAccountRestored      = "user.restored"
+	AuditAccountPurged        = "user.purged"
+	AuditDataExportRequested  = "user.data_export_requested"
+	AuditDataExportDownloaded = "user.data_export_downloaded"
+	AuditCompanyDataUpdated   = "company.data_updated"
+	AuditBranchCreated        = "company.branch_created"
+	AuditBranchUpdated        = "company.branch_updated"
+	AuditBranchDeleted        = "company.branch_deleted"
+	AuditAPIKeyCreated        = "company.api_key_created"
+	AuditAPIKeyRevoked        = "company.api_key_revoked"
+	AuditUserSuspended        = "admin.user_suspended"
+	AuditUserUnsuspended      = "admin.user_unsuspended"
+	AuditUserTypeChanged      = "admin.user_type_changed"
+	AuditTwoFactorPolicySet   = "admin.2fa_policy_updated"
+	AuditImpersonationStarted = "admin.impersonation_started"
+)
+
+// AuditRedacted replaces the value of sensitive fields in audit events
+const AuditRedacted = "[REDACTED]"
+
+// AuditEvent is an append-only record of a security relevant action
+type AuditEvent struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Action    string             `json:"action" bson:"action"`
+	ActorID   primitive.ObjectID `json:"actorId,omitempty" bson:"actorId,omitempty"`
+	ActorType string             `json:"actorType,omitempty" bson:"actorType,omitempty"`
+	APIKeyID  string             `json:"apiKeyId,omitempty" bson:"apiKeyId,omitempty"`
+	// ImpersonatorID is the admin who performed the action while acting as the actor
+	ImpersonatorID primitive.ObjectID     `json:"impersonatorId,omitempty" bson:"impersonatorId,omitempty"`
+	TargetType     string                 `json:"targetType,omitempty" bson:"targetType,omitempty"`
+	TargetID       string                 `json:"targetId,omitempty" bson:"targetId,omitempty"`
+	IP             string                 `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent      string                 `json:"userAgent,omitempty" bson:"userAgent,omitempty"`
+	Changes        map[string]AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	CreatedAt      time.Time              `json:"createdAt" bson:"createdAt"`
+}
+
+// AuditChange is the before and after value of a changed field
+type AuditChange struct {
+	From interface{} `json:"from" bson:"from"`
+	To   interface{} `json:"to" bson:"to"`
+}
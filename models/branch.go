@@ -0,0 +1,58 @@
+// models/branch.go
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Coordinate is a latitude or longitude sent either as a JSON number or as a numeric string
+type Coordinate float64
+
+// UnmarshalJSON accepts 33.89 as well as "33.89"
+func (c *Coordinate) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*c = Coordinate(v)
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("coordinate %q is not a number", v)
+		}
+		*c = Coordinate(f)
+	case nil:
+	default:
+		return fmt.Errorf("coordinate must be a number")
+	}
+	return nil
+}
+
+// BranchRequest is the branch data sent when creating a branch
+type BranchRequest struct {
+	Name        string     `json:"name" validate:"required,max=100"`
+	Location    string     `json:"location" validate:"max=200"`
+	Latitude    Coordinate `json:"latitude" validate:"min=-90,max=90"`
+	Longitude   Coordinate `json:"longitude" validate:"min=-180,max=180"`
+	Category    string     `json:"category" validate:"max=64"`
+	SubCategory string     `json:"subCategory" validate:"max=64"`
+	Phone       string     `json:"phone" validate:"max=32"`
+	Description string     `json:"description" validate:"max=1000"`
+}
+
+// BranchUpdateRequest is the branch data sent when updating a branch; absent fields keep their value
+type BranchUpdateRequest struct {
+	Name        *string     `json:"name" validate:"min=1,max=100"`
+	Location    *string     `json:"location" validate:"max=200"`
+	Latitude    *Coordinate `json:"latitude" validate:"min=-90,max=90"`
+	Longitude   *Coordinate `json:"longitude" validate:"min=-180,max=180"`
+	Category    *string     `json:"category" validate:"max=64"`
+	SubCategory *string     `json:"subCategory" validate:"max=64"`
+	Phone       *string     `json:"phone" validate:"max=32"`
+	Description *string     `json:"description" validate:"max=1000"`
+}
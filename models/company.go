@@ -1,5 +1,5 @@
-// // models/company.go
-// package models
+// models/company.go
+package models
 
 // import (
 // 	"time"
@@ -0,0 +1,28 @@
+// models/data_export.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Data export statuses
+const (
+	DataExportPending = "pending"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport is a user's request for an archive of their personal data
+type DataExport struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID      primitive.ObjectID `json:"-" bson:"userId"`
+	Status      string             `json:"status" bson:"status"`
+	FilePath    string             `json:"-" bson:"filePath,omitempty"`
+	Size        int64              `json:"size,omitempty" bson:"size,omitempty"`
+	TokenHash   string             `json:"-" bson:"tokenHash"` // hash of the token in the download link
+	ExpiresAt   time.Time          `json:"expiresAt" bson:"expiresAt"`
+	CompletedAt *time.Time         `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,165 @@
+// models/errors.go
+package models
+
+import "net/http"
+
+// APIError is an error with a stable, machine-readable code. Handlers return it and the central
+// HTTP error handler writes it as a Response. Err is the internal cause; it is logged and never
+// sent to clients.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Data    interface{}
+	Errors  []FieldError
+	Err     error
+}
+
+// NewAPIError defines an entry of the error catalog
+func NewAPIError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap returns the internal cause
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors with the same code, so errors.Is works on copies made by the With methods
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage returns a copy of the error with a more specific message and the same code
+func (e *APIError) WithMessage(message string) *APIError {
+	copied := *e
+	copied.Message = message
+	return &copied
+}
+
+// WithData returns a copy of the error carrying details the client needs to recover
+func (e *APIError) WithData(data interface{}) *APIError {
+	copied := *e
+	copied.Data = data
+	return &copied
+}
+
+// WithErrors returns a copy of the error listing the rejected fields
+func (e *APIError) WithErrors(fieldErrors []FieldError) *APIError {
+	copied := *e
+	copied.Errors = fieldErrors
+	return &copied
+}
+
+// Wrap returns a copy of the error that records its internal cause for the logs
+func (e *APIError) Wrap(err error) *APIError {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+// Error catalog. Codes are part of the API contract: clients match on them, so they must never
+// change once released. Messages are for people and may be reworded.
+var (
+	// Generic
+	ErrInternal          = NewAPIError(http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+	ErrInvalidRequest    = NewAPIError(http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+	ErrMissingFields     = NewAPIError(http.StatusBadRequest, "MISSING_REQUIRED_FIELDS", "Missing required fields")
+	ErrValidationFailed  = NewAPIError(http.StatusBadRequest, "VALIDATION_FAILED", "Invalid request")
+	ErrInvalidFormData   = NewAPIError(http.StatusBadRequest, "INVALID_FORM_DATA", "Invalid form data")
+	ErrInvalidFilter     = NewAPIError(http.StatusBadRequest, "INVALID_FILTER", "Invalid filter")
+	ErrFileRequired      = NewAPIError(http.StatusBadRequest, "FILE_REQUIRED", "No file uploaded or invalid file")
+	ErrUnsupportedFile   = NewAPIError(http.StatusBadRequest, "UNSUPPORTED_FILE_TYPE", "Only image files are allowed")
+	ErrNotFound          = NewAPIError(http.StatusNotFound, "NOT_FOUND", "Not found")
+	ErrMethodNotAllowed  = NewAPIError(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+	ErrRequestTooLarge   = NewAPIError(http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE", "Request body is too large")
+	ErrTooManyRequests   = NewAPIError(http.StatusTooManyRequests, "RATE_LIMITED", "Too many requests. Please try again later")
+	ErrPermissionDenied  = NewAPIError(http.StatusForbidden, "PERMISSION_DENIED", "You do not have permission to perform this action")
+	ErrSelfActionDenied  = NewAPIError(http.StatusBadRequest, "SELF_ACTION_NOT_ALLOWED", "You cannot perform this action on your own account")
+	ErrInvalidUserType   = NewAPIError(http.StatusBadRequest, "INVALID_USER_TYPE", "Invalid user type")
+	ErrInvalidEmail      = NewAPIError(http.StatusBadRequest, "INVALID_EMAIL", "Invalid email address")
+	ErrInvalidPhone      = NewAPIError(http.StatusBadRequest, "INVALID_PHONE", "Invalid phone number")
+	ErrEmailInUse        = NewAPIError(http.StatusConflict, "EMAIL_IN_USE", "Email is already in use")
+	ErrPhoneInUse        = NewAPIError(http.StatusConflict, "PHONE_IN_USE", "Phone number is already in use")
+	ErrEmailUnchanged    = NewAPIError(http.StatusBadRequest, "EMAIL_UNCHANGED", "New email must be different from the current email")
+	ErrPhoneVerified     = NewAPIError(http.StatusBadRequest, "PHONE_ALREADY_VERIFIED", "Phone number is already verified")
+	ErrEmailNotVerified  = NewAPIError(http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Please verify your email address to continue")
+	ErrSignupAdminDenied = NewAPIError(http.StatusForbidden, "SIGNUP_ADMIN_NOT_ALLOWED", "Admin accounts cannot be created through signup")
+
+	// Authentication
+	ErrUnauthorized              = NewAPIError(http.StatusUnauthorized, "AUTH_UNAUTHORIZED", "Unauthorized")
+	ErrInvalidCredentials        = NewAPIError(http.StatusUnauthorized, "AUTH_INVALID_CREDENTIALS", "Invalid email or password")
+	ErrInvalidPassword           = NewAPIError(http.StatusUnauthorized, "AUTH_INVALID_PASSWORD", "Invalid password")
+	ErrCurrentPasswordIncorrect  = NewAPIError(http.StatusUnauthorized, "AUTH_CURRENT_PASSWORD_INCORRECT", "Current password is incorrect")
+	ErrPasswordNotSet            = NewAPIError(http.StatusBadRequest, "AUTH_PASSWORD_NOT_SET", "This account does not have a password")
+	ErrPasswordAlreadySet        = NewAPIError(http.StatusConflict, "AUTH_PASSWORD_ALREADY_SET", "Your account already has a password. Use change password instead")
+	ErrPasswordUnchanged         = NewAPIError(http.StatusBadRequest, "AUTH_PASSWORD_UNCHANGED", "New password must be different from the current password")
+	ErrWeakPassword              = NewAPIError(http.StatusBadRequest, "AUTH_PASSWORD_POLICY", "Password does not meet the requirements")
+	ErrInvalidToken              = NewAPIError(http.StatusUnauthorized, "AUTH_INVALID_TOKEN", "Invalid or expired token")
+	ErrMissingToken              = NewAPIError(http.StatusUnauthorized, "AUTH_MISSING_TOKEN", "Missing or malformed token")
+	ErrInvalidAPIKey             = NewAPIError(http.StatusUnauthorized, "AUTH_INVALID_API_KEY", "Invalid or revoked API key")
+	ErrSessionInvalid            = NewAPIError(http.StatusUnauthorized, "AUTH_SESSION_INVALID", "Session is no longer valid")
+	ErrAccountNotFound           = NewAPIError(http.StatusUnauthorized, "AUTH_ACCOUNT_NOT_FOUND", "Account no longer exists")
+	ErrInvalidRefreshToken       = NewAPIError(http.StatusUnauthorized, "AUTH_REFRESH_TOKEN_INVALID", "Invalid or expired refresh token")
+	ErrImpersonationInvalid      = NewAPIError(http.StatusUnauthorized, "AUTH_IMPERSONATION_INVALID", "Impersonation is no longer valid")
+	ErrInvalidGoogleToken        = NewAPIError(http.StatusUnauthorized, "AUTH_GOOGLE_TOKEN_INVALID", "Invalid Google token")
+	ErrGoogleEmailUnverified     = NewAPIError(http.StatusUnauthorized, "AUTH_GOOGLE_EMAIL_UNVERIFIED", "Google account email is not verified")
+	ErrGoogleAccountNotLinked    = NewAPIError(http.StatusUnauthorized, "AUTH_GOOGLE_ACCOUNT_NOT_LINKED", "No account is linked to this Google account")
+	ErrGoogleAccountMismatch     = NewAPIError(http.StatusConflict, "AUTH_GOOGLE_ACCOUNT_MISMATCH", "This account is linked to a different Google account")
+	ErrGoogleLinkNeedsPassword   = NewAPIError(http.StatusConflict, "AUTH_GOOGLE_LINK_PASSWORD_REQUIRED", "An account with this email already exists. Enter your password to link your Google account")
+	ErrAccountSuspended          = NewAPIError(http.StatusForbidden, "ACCOUNT_SUSPENDED", "Your account has been suspended")
+	ErrAccountPendingDeletion    = NewAPIError(http.StatusForbidden, "ACCOUNT_PENDING_DELETION", "This account is scheduled for deletion. Restore it to sign in again")
+	ErrAccountNotPendingDeletion = NewAPIError(http.StatusBadRequest, "ACCOUNT_NOT_PENDING_DELETION", "This account is not scheduled for deletion")
+	ErrAccountNotRestorable      = NewAPIError(http.StatusNotFound, "ACCOUNT_NOT_RESTORABLE", "No restorable account found")
+	ErrAccountRestoreExpired     = NewAPIError(http.StatusGone, "ACCOUNT_RESTORE_EXPIRED", "The grace period has ended and the account can no longer be restored")
+
+	// One-time codes and tokens
+	ErrOTPNotFound             = NewAPIError(http.StatusBadRequest, "OTP_NOT_FOUND", "No OTP request found. Please request a new OTP")
+	ErrOTPExpired              = NewAPIError(http.StatusBadRequest, "OTP_EXPIRED", "OTP has expired. Please request a new OTP")
+	ErrOTPInvalid              = NewAPIError(http.StatusBadRequest, "OTP_INVALID", "Invalid code")
+	ErrLoginCodeInvalid        = NewAPIError(http.StatusUnauthorized, "AUTH_LOGIN_CODE_INVALID", "Invalid or expired login code")
+	ErrOTPAttemptsExceeded     = NewAPIError(http.StatusBadRequest, "OTP_ATTEMPTS_EXCEEDED", "Too many invalid attempts. Please request a new code")
+	ErrResetTokenInvalid       = NewAPIError(http.StatusBadRequest, "RESET_TOKEN_INVALID", "Invalid or expired reset token")
+	ErrEmailChangeNotPending   = NewAPIError(http.StatusBadRequest, "EMAIL_CHANGE_NOT_PENDING", "No pending email change or the code has expired")
+	ErrPhoneVerifyNotPending   = NewAPIError(http.StatusBadRequest, "PHONE_VERIFICATION_NOT_PENDING", "No pending phone verification or the code has expired")
+	ErrTwoFactorCodeInvalid    = NewAPIError(http.StatusUnauthorized, "TWO_FACTOR_CODE_INVALID", "Invalid authentication code")
+	ErrTwoFactorChallenge      = NewAPIError(http.StatusUnauthorized, "TWO_FACTOR_CHALLENGE_INVALID", "Invalid or expired challenge. Please log in again")
+	ErrTwoFactorNotStarted     = NewAPIError(http.StatusBadRequest, "TWO_FACTOR_SETUP_NOT_STARTED", "Start two-factor setup first")
+	ErrTwoFactorNotEnabled     = NewAPIError(http.StatusBadRequest, "TWO_FACTOR_NOT_ENABLED", "Two-factor authentication is not enabled")
+	ErrTwoFactorEnabled        = NewAPIError(http.StatusConflict, "TWO_FACTOR_ALREADY_ENABLED", "Two-factor authentication is already enabled")
+	ErrTwoFactorRequired       = NewAPIError(http.StatusForbidden, "TWO_FACTOR_REQUIRED", "Two-factor authentication is required for your account type")
+	ErrIdentityNotFound        = NewAPIError(http.StatusNotFound, "IDENTITY_NOT_FOUND", "This login method is not linked to your account")
+	ErrIdentityAlreadyLinked   = NewAPIError(http.StatusConflict, "IDENTITY_ALREADY_LINKED", "A Google account is already linked. Unlink it first")
+	ErrIdentityLinkedElsewhere = NewAPIError(http.StatusConflict, "IDENTITY_LINKED_TO_OTHER_USER", "This Google account is linked to another user")
+	ErrIdentityLastMethod      = NewAPIError(http.StatusBadRequest, "IDENTITY_LAST_LOGIN_METHOD", "You cannot remove your last login method")
+
+	// Resources
+	ErrInvalidUserID       = NewAPIError(http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+	ErrUserNotFound        = NewAPIError(http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+	ErrInvalidCompanyID    = NewAPIError(http.StatusBadRequest, "INVALID_COMPANY_ID", "Invalid company ID format")
+	ErrCompanyNotFound     = NewAPIError(http.StatusNotFound, "COMPANY_NOT_FOUND", "Company not found")
+	ErrInvalidBranchID     = NewAPIError(http.StatusBadRequest, "INVALID_BRANCH_ID", "Invalid branch ID format")
+	ErrBranchNotFound      = NewAPIError(http.StatusNotFound, "BRANCH_NOT_FOUND", "Branch not found")
+	ErrInvalidSessionID    = NewAPIError(http.StatusBadRequest, "INVALID_SESSION_ID", "Invalid session ID")
+	ErrSessionNotFound     = NewAPIError(http.StatusNotFound, "SESSION_NOT_FOUND", "Session not found")
+	ErrInvalidAPIKeyID     = NewAPIError(http.StatusBadRequest, "INVALID_API_KEY_ID", "Invalid API key ID")
+	ErrAPIKeyNotFound      = NewAPIError(http.StatusNotFound, "API_KEY_NOT_FOUND", "API key not found or already revoked")
+	ErrAPIKeyLimit         = NewAPIError(http.StatusConflict, "API_KEY_LIMIT_REACHED", "API key limit reached. Revoke an unused key first")
+	ErrAPIKeyInvalidScope  = NewAPIError(http.StatusBadRequest, "API_KEY_INVALID_SCOPE", "Invalid scope")
+	ErrAPIKeyInvalidExpiry = NewAPIError(http.StatusBadRequest, "API_KEY_INVALID_EXPIRY", "Expiry must be a positive number of days")
+	ErrDataExportNotFound  = NewAPIError(http.StatusNotFound, "DATA_EXPORT_NOT_FOUND", "Data export not found or expired")
+	ErrDataExportPending   = NewAPIError(http.StatusConflict, "DATA_EXPORT_IN_PROGRESS", "Your data export is still being prepared")
+	ErrDataExportNotReady  = NewAPIError(http.StatusConflict, "DATA_EXPORT_NOT_READY", "Your data export is not ready yet")
+
+	// Administration
+	ErrImpersonationDenied = NewAPIError(http.StatusForbidden, "IMPERSONATION_NOT_ALLOWED", "Admin accounts cannot be impersonated")
+	ErrImpersonationTarget = NewAPIError(http.StatusBadRequest, "IMPERSONATION_INVALID_TARGET", "This account cannot be impersonated")
+)
@@ -0,0 +1,14 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/HSouheill/barrim_backend/models"
+	"github.com/HSouheill/barrim_backend/utils"
+)
+
+func TestValidatedRequestTags(t *testing.T) {
+	if err := utils.NewRequestValidator().Register(models.ValidatedRequests...); err != nil {
+		t.Fatalf("request validation tags are malformed: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+// models/session.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session represents a login session backed by a rotating refresh token
+type Session struct {
+	ID                  primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	UserID              primitive.ObjectID `json:"userId" bson:"userId"`
+	ImpersonatorID      primitive.ObjectID `json:"impersonatorId,omitempty" bson:"impersonatorId,omitempty"` // admin acting as the user
+	RefreshTokenHash    string             `json:"-" bson:"refreshTokenHash"`
+	PreviousTokenHashes []string           `json:"-" bson:"previousTokenHashes,omitempty"`
+	Device              DeviceInfo         `json:"device" bson:"device"`
+	IP                  string             `json:"ip,omitempty" bson:"ip,omitempty"`
+	LastSeenAt          time.Time          `json:"lastSeenAt" bson:"lastSeenAt"`
+	ExpiresAt           time.Time          `json:"expiresAt" bson:"expiresAt"`
+	RevokedAt           *time.Time         `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+	CreatedAt           time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt           time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// DeviceInfo describes the client a session was started from, as reported by the client
+type DeviceInfo struct {
+	Name      string `json:"name,omitempty" bson:"name,omitempty"`
+	Platform  string `json:"platform,omitempty" bson:"platform,omitempty"`
+	DeviceID  string `json:"-" bson:"deviceId,omitempty"`
+	UserAgent string `json:"userAgent,omitempty" bson:"userAgent,omitempty"`
+}
+
+// SessionSummary is how a session is shown to its owner
+type SessionSummary struct {
+	ID           primitive.ObjectID `json:"id"`
+	Device       DeviceInfo         `json:"device"`
+	IP           string             `json:"ip,omitempty"`
+	LastSeenAt   time.Time          `json:"lastSeenAt"`
+	CreatedAt    time.Time          `json:"createdAt"`
+	Current      bool               `json:"current"`
+	Impersonated bool               `json:"impersonated,omitempty"` // opened by support staff acting as the user
+}
+
+// RefreshTokenRequest is the body accepted by the token refresh endpoint
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
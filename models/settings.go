@@ -0,0 +1,34 @@
+// models/settings.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TwoFactorPolicySettingID is the _id of the settings document holding the two-factor policy
+const TwoFactorPolicySettingID = "twoFactorPolicy"
+
+// TwoFactorPolicy lists the user types that must enroll in two-factor authentication
+type TwoFactorPolicy struct {
+	ID                string             `json:"-" bson:"_id"`
+	RequiredUserTypes []string           `json:"requiredUserTypes" bson:"requiredUserTypes"`
+	UpdatedBy         primitive.ObjectID `json:"updatedBy,omitempty" bson:"updatedBy,omitempty"`
+	UpdatedAt         time.Time          `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+}
+
+// Requires reports whether accounts of the user type must use two-factor authentication
+func (p *TwoFactorPolicy) Requires(userType string) bool {
+	for _, t := range p.RequiredUserTypes {
+		if t == userType {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateTwoFactorPolicyRequest replaces the list of user types that must use two-factor authentication
+type UpdateTwoFactorPolicyRequest struct {
+	RequiredUserTypes []string `json:"requiredUserTypes"`
+}
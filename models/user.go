@@ -11,12 +11,17 @@ import (
 type User struct {
 	ID                  primitive.ObjectID   `json:"id,omitempty" bson:"_id,omitempty"`
 	Email               string               `json:"email" bson:"email"`
+	EmailVerified       bool                 `json:"emailVerified" bson:"emailVerified"`
+	EmailVerification   *OTPInfo             `json:"-" bson:"emailVerification,omitempty"`
+	EmailChange         *EmailChange         `json:"-" bson:"emailChange,omitempty"`
 	Password            string               `json:"password,omitempty" bson:"password"`
 	FullName            string               `json:"fullName" bson:"fullName"`
 	UserType            string               `json:"userType" bson:"userType"`
 	DateOfBirth         string               `json:"dateOfBirth,omitempty" bson:"dateOfBirth,omitempty"`
 	Gender              string               `json:"gender,omitempty" bson:"gender,omitempty"`
 	Phone               string               `json:"phone,omitempty" bson:"phone,omitempty"`
+	PhoneVerified       bool                 `json:"phoneVerified" bson:"phoneVerified"`
+	PhoneVerification   *PhoneVerification   `json:"-" bson:"phoneVerification,omitempty"`
 	ReferralCode        string               `json:"referralCode,omitempty" bson:"referralCode,omitempty"`
 	InterestedDeals     []string             `json:"interestedDeals,omitempty" bson:"interestedDeals,omitempty"`
 	Location            *Location            `json:"location,omitempty" bson:"location,omitempty"`
@@ -24,57 +29,125 @@ type User struct {
 	ServiceProviderInfo *ServiceProviderInfo `json:"serviceProviderInfo,omitempty" bson:"serviceProviderInfo,omitempty"`
 	WholesalerInfo      *WholesalerInfo      `json:"wholesalerInfo,omitempty" bson:"wholesalerInfo,omitempty"`
 	LogoPath            string               `json:"logoPath,omitempty" bson:"logoPath,omitempty"`
-	OTPInfo             *OTPInfo             `json:"otpInfo,omitempty" bson:"otpInfo,omitempty"`
-	ResetPasswordToken  string               `json:"resetPasswordToken,omitempty" bson:"resetPasswordToken,omitempty"`
-	ResetTokenExpiresAt time.Time            `json:"resetTokenExpiresAt,omitempty" bson:"resetTokenExpiresAt,omitempty"`
+	OTPInfo             *OTPInfo             `json:"-" bson:"otpInfo,omitempty"`
+	ResetTokenHash      string               `json:"-" bson:"resetTokenHash,omitempty"` // hash of the single-use reset token
+	ResetTokenExpiresAt time.Time            `json:"-" bson:"resetTokenExpiresAt,omitempty"`
 	GoogleUID           string               `bson:"googleUID,omitempty" json:"googleUID,omitempty"`
+	Identities          []Identity           `json:"identities,omitempty" bson:"identities,omitempty"`
 	ProfilePic          string               `bson:"profilePic,omitempty" json:"profilePic,omitempty"`
+	Suspended           bool                 `json:"suspended,omitempty" bson:"suspended,omitempty"`
+	SuspendedAt         *time.Time           `json:"suspendedAt,omitempty" bson:"suspendedAt,omitempty"`
+	SuspensionReason    string               `json:"suspensionReason,omitempty" bson:"suspensionReason,omitempty"`
+	DeletionRequestedAt *time.Time           `json:"deletionRequestedAt,omitempty" bson:"deletionRequestedAt,omitempty"`
+	DeletionScheduledAt *time.Time           `json:"deletionScheduledAt,omitempty" bson:"deletionScheduledAt,omitempty"` // purged after this time unless restored
+	TwoFactor           *TwoFactorInfo       `json:"-" bson:"twoFactor,omitempty"`
 	CreatedAt           time.Time            `json:"createdAt" bson:"createdAt"`
 	UpdatedAt           time.Time            `json:"updatedAt" bson:"updatedAt"`
 }
 
 type OTPInfo struct {
 	OTP       string    `json:"otp" bson:"otp"`
+	Purpose   string    `json:"purpose,omitempty" bson:"purpose,omitempty"`
+	TokenHash string    `json:"-" bson:"tokenHash,omitempty"` // hash of the one-time link token sent with the code
 	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+	SentAt    time.Time `json:"sentAt,omitempty" bson:"sentAt,omitempty"`
+	Attempts  int       `json:"attempts" bson:"attempts"`
+}
+
+// OTP purposes; codes stored before purposes existed are password reset codes
+const (
+	OTPPurposePasswordReset = "passwordReset"
+	OTPPurposeLogin         = "login"
+)
+
+// EmailChange is a pending change of address, confirmed by a code sent to the new address
+type EmailChange struct {
+	NewEmail string `json:"newEmail" bson:"newEmail"`
+	OTPInfo  `bson:",inline"`
+}
+
+// Identity providers a user can sign in with
+const (
+	IdentityProviderPassword = "password"
+	IdentityProviderGoogle   = "google"
+)
+
+// Identity is a login method linked to an account
+type Identity struct {
+	Provider    string    `json:"provider" bson:"provider"`
+	ProviderUID string    `json:"providerUid,omitempty" bson:"providerUid,omitempty"`
+	Email       string    `json:"email,omitempty" bson:"email,omitempty"`
+	LinkedAt    time.Time `json:"linkedAt" bson:"linkedAt"`
+}
+
+// HasIdentity reports whether the user can sign in with the provider
+func (u *User) HasIdentity(provider string) bool {
+	for _, identity := range u.Identities {
+		if identity.Provider == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// PendingDeletion reports whether the user asked to delete the account and it has not been restored
+func (u *User) PendingDeletion() bool {
+	return u.DeletionScheduledAt != nil
+}
+
+// PhoneVerification is a pending phone number, confirmed by a code sent to it by SMS
+type PhoneVerification struct {
+	Phone   string `json:"phone" bson:"phone"`
+	OTPInfo `bson:",inline"`
+}
+
+// TwoFactorInfo holds a user's TOTP enrollment
+type TwoFactorInfo struct {
+	Enabled       bool       `json:"enabled" bson:"enabled"`
+	Secret        string     `json:"-" bson:"secret,omitempty"`
+	PendingSecret string     `json:"-" bson:"pendingSecret,omitempty"` // awaiting confirmation by a first code
+	RecoveryCodes []string   `json:"-" bson:"recoveryCodes,omitempty"` // bcrypt hashes, removed once used
+	LastUsedStep  int64      `json:"-" bson:"lastUsedStep"`            // last accepted TOTP step, blocks code replay
+	EnabledAt     *time.Time `json:"enabledAt,omitempty" bson:"enabledAt,omitempty"`
 }
 
 // Location model
 type Location struct {
-	City       string  `json:"city" bson:"city"`
-	Country    string  `json:"country" bson:"country"`
-	District   string  `json:"district" bson:"district"`
-	Street     string  `json:"street" bson:"street"`
-	PostalCode string  `json:"postalCode" bson:"postalCode"`
-	Lat        float64 `json:"lat" bson:"lat"`
-	Lng        float64 `json:"lng" bson:"lng"`
+	City       string  `json:"city" bson:"city" validate:"max=100"`
+	Country    string  `json:"country" bson:"country" validate:"max=100"`
+	District   string  `json:"district" bson:"district" validate:"max=100"`
+	Street     string  `json:"street" bson:"street" validate:"max=200"`
+	PostalCode string  `json:"postalCode" bson:"postalCode" validate:"max=20"`
+	Lat        float64 `json:"lat" bson:"lat" validate:"min=-90,max=90"`
+	Lng        float64 `json:"lng" bson:"lng" validate:"min=-180,max=180"`
 	Allowed    bool    `json:"allowed" bson:"allowed"`
 }
 
 // CompanyInfo model
 type CompanyInfo struct {
-	Name           string   `json:"name" bson:"name"`
-	Category       string   `json:"Category" bson:"Category"`
-	CustomCategory string   `json:"customCategory,omitempty" bson:"customCategory,omitempty"`
+	Name           string   `json:"name" bson:"name" validate:"required,max=100"`
+	Category       string   `json:"Category" bson:"Category" validate:"required,max=64"`
+	CustomCategory string   `json:"customCategory,omitempty" bson:"customCategory,omitempty" validate:"required_if=Category Other,max=64"`
 	Logo           string   `json:"logo,omitempty" bson:"logo,omitempty"`
-	SubCategory    string   `json:"subCategory,omitempty" bson:"subCategory,omitempty"`
+	SubCategory    string   `json:"subCategory,omitempty" bson:"subCategory,omitempty" validate:"max=64"`
 	Branches       []Branch `bson:"branches,omitempty" json:"branches,omitempty"`
 	Details        []Detail `bson:"details,omitempty" json:"details,omitempty"`
 }
 
 type WholesalerInfo struct {
-	BusinessName string `json:"businessName" bson:"businessName"`
-	Category     string `json:"Category" bson:"Category"`
-	ReferralCode string `json:"referralCode,omitempty" bson:"referralCode,omitempty"`
+	BusinessName string `json:"businessName" bson:"businessName" validate:"required,max=100"`
+	Category     string `json:"Category" bson:"Category" validate:"required,max=64"`
+	ReferralCode string `json:"referralCode,omitempty" bson:"referralCode,omitempty" validate:"max=32"`
 }
 
 // ServiceProviderInfo model
 type ServiceProviderInfo struct {
-	ServiceType       string   `json:"serviceType" bson:"serviceType"`
-	CustomServiceType string   `json:"customServiceType,omitempty" bson:"customServiceType,omitempty"` // For "Other" service type
-	YearsExperience   int      `json:"yearsExperience" bson:"yearsExperience"`
+	ServiceType       string   `json:"serviceType" bson:"serviceType" validate:"required,max=64"`
+	CustomServiceType string   `json:"customServiceType,omitempty" bson:"customServiceType,omitempty" validate:"required_if=ServiceType Other,max=64"` // For "Other" service type
+	YearsExperience   int      `json:"yearsExperience" bson:"yearsExperience" validate:"min=0,max=80"`
 	ProfilePhoto      string   `json:"profilePhoto,omitempty" bson:"profilePhoto,omitempty"`
-	AvailableHours    []string `json:"availableHours,omitempty" bson:"availableHours,omitempty"`
-	AvailableDays     []string `json:"availableDays,omitempty" bson:"availableDays,omitempty"`
+	AvailableHours    []string `json:"availableHours,omitempty" bson:"availableHours,omitempty" validate:"max=24,dive,required,max=32"`
+	AvailableDays     []string `json:"availableDays,omitempty" bson:"availableDays,omitempty" validate:"max=7,dive,required,max=32"`
 }
 
 // AuthRequest models
@@ -83,31 +156,160 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// SignupRequest creates an account. The profile section matching the user type is required.
 type SignupRequest struct {
-	Email               string               `json:"email"`
-	Password            string               `json:"password"`
-	FullName            string               `json:"fullName"`
-	UserType            string               `json:"userType"`
-	DateOfBirth         string               `json:"dateOfBirth,omitempty"`
-	Gender              string               `json:"gender,omitempty"`
-	Phone               string               `json:"phone,omitempty"`
-	ReferralCode        string               `json:"referralCode,omitempty"`
-	InterestedDeals     []string             `json:"interestedDeals,omitempty"`
+	Email               string               `json:"email" validate:"required,email,max=254"`
+	Password            string               `json:"password" validate:"required"`
+	FullName            string               `json:"fullName" validate:"required,max=100"`
+	UserType            string               `json:"userType" validate:"required,oneof=user company wholesaler serviceProvider"`
+	DateOfBirth         string               `json:"dateOfBirth,omitempty" validate:"omitempty,date"`
+	Gender              string               `json:"gender,omitempty" validate:"max=32"`
+	Phone               string               `json:"phone,omitempty" validate:"max=32"`
+	ReferralCode        string               `json:"referralCode,omitempty" validate:"max=32"`
+	InterestedDeals     []string             `json:"interestedDeals,omitempty" validate:"max=50,dive,required,max=64"`
 	Location            *Location            `json:"location,omitempty"`
-	CompanyInfo         *CompanyInfo         `json:"companyInfo,omitempty"`
-	ServiceProviderInfo *ServiceProviderInfo `json:"serviceProviderInfo,omitempty"`
-	WholesalerInfo      *WholesalerInfo      `json:"wholesalerInfo,omitempty"`
+	CompanyInfo         *CompanyInfo         `json:"companyInfo,omitempty" validate:"required_if=UserType company"`
+	ServiceProviderInfo *ServiceProviderInfo `json:"serviceProviderInfo,omitempty" validate:"required_if=UserType serviceProvider"`
+	WholesalerInfo      *WholesalerInfo      `json:"wholesalerInfo,omitempty" validate:"required_if=UserType wholesaler"`
+}
+
+// GoogleLoginRequest carries a Google ID token. Password is only needed to link
+// Google to an existing password account with the same email.
+type GoogleLoginRequest struct {
+	IDToken  string `json:"idToken"`
+	Password string `json:"password,omitempty"`
+}
+
+// EmailVerificationRequest confirms ownership of an email address
+type EmailVerificationRequest struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+// PasswordlessLoginRequest asks for a one-time login code and link
+type PasswordlessLoginRequest struct {
+	Email string `json:"email"`
+}
+
+// PasswordlessVerifyRequest exchanges an emailed code, or the token from the emailed link, for a session
+type PasswordlessVerifyRequest struct {
+	Email string `json:"email,omitempty"`
+	Code  string `json:"code,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// LinkGoogleRequest links a Google account to the authenticated user
+type LinkGoogleRequest struct {
+	IDToken string `json:"idToken"`
+}
+
+// SetPasswordRequest adds a password to an account that signs in with another provider only
+type SetPasswordRequest struct {
+	NewPassword string `json:"newPassword"`
+}
+
+// ChangePasswordRequest changes the password of the authenticated user
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// EmailChangeRequest starts changing the authenticated user's email address
+type EmailChangeRequest struct {
+	NewEmail string `json:"newEmail"`
+	Password string `json:"password"`
+}
+
+// EmailChangeConfirmRequest confirms an email change with the code sent to the new address
+type EmailChangeConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+// PhoneVerificationRequest asks for a verification code to be sent to a phone number
+type PhoneVerificationRequest struct {
+	Phone string `json:"phone"`
+}
+
+// PhoneVerificationConfirmRequest confirms a phone number with the code sent to it
+type PhoneVerificationConfirmRequest struct {
+	Code string `json:"code"`
 }
 
+// TwoFactorCodeRequest carries a TOTP or recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorLoginRequest completes a login that was answered with a two-factor challenge
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
+// TwoFactorDisableRequest turns two-factor authentication off
+type TwoFactorDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// SuspendUserRequest is the body accepted when an admin suspends an account
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// RestoreAccountRequest is the body accepted to cancel a pending account deletion.
+// Password accounts send their email and password; Google accounts send an ID token.
+type RestoreAccountRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	IDToken  string `json:"idToken"`
+}
+
+// ImpersonateUserRequest is the body accepted when an admin starts impersonating an account
+type ImpersonateUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ChangeUserTypeRequest is the body accepted when an admin changes an account's type
+type ChangeUserTypeRequest struct {
+	UserType string `json:"userType"`
+}
+
+// UpdateLocationRequest replaces the authenticated user's location
 type UpdateLocationRequest struct {
-	Location *Location `json:"location"`
+	Location *Location `json:"location" validate:"required"`
+}
+
+// AvailabilityRequest replaces a service provider's available days and hours
+type AvailabilityRequest struct {
+	AvailableDays  []string `json:"availableDays" validate:"required,max=7,dive,required,max=32"`
+	AvailableHours []string `json:"availableHours" validate:"required,max=24,dive,required,max=32"`
+}
+
+// ValidatedRequests are the request structs handlers check with c.Validate. Their validate tags
+// are parsed at startup; add new request types here.
+var ValidatedRequests = []interface{}{
+	SignupRequest{},
+	UpdateLocationRequest{},
+	AvailabilityRequest{},
+	BranchRequest{},
+	BranchUpdateRequest{},
 }
 
 // Response model
 type Response struct {
-	Status  int         `json:"status"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
+	Status  int          `json:"status"`
+	Code    string       `json:"code,omitempty"` // stable error code from the error catalog, only set on errors
+	Message string       `json:"message"`
+	Data    interface{}  `json:"data,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 type Branch struct {
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/HSouheill/barrim_backend/controllers"
+	"github.com/HSouheill/barrim_backend/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterAdminRoutes(e *echo.Echo, adminController *controllers.AdminController, companyController *controllers.CompanyController) {
+	// Admin routes - require an authenticated user with admin permissions
+	adminGroup := e.Group("/api/admin")
+	adminGroup.Use(middleware.Authenticate(adminController.DB))
+	adminGroup.Use(middleware.RequireTwoFactorEnrollment(adminController.DB))
+	adminGroup.GET("/users", adminController.ListUsers, middleware.RequirePermission(middleware.PermUsersList))
+	adminGroup.POST("/users/:id/suspend", adminController.SuspendUser, middleware.RequirePermission(middleware.PermUsersManage))
+	adminGroup.POST("/users/:id/unsuspend", adminController.UnsuspendUser, middleware.RequirePermission(middleware.PermUsersManage))
+	adminGroup.PUT("/users/:id/user-type", adminController.ChangeUserType, middleware.RequirePermission(middleware.PermUsersManage))
+	adminGroup.POST("/users/:id/restore", adminController.RestoreUser, middleware.RequirePermission(middleware.PermUsersManage))
+	adminGroup.POST("/users/:id/impersonate", adminController.ImpersonateUser, middleware.RequirePermission(middleware.PermUsersImpersonate))
+	adminGroup.GET("/settings/two-factor", adminController.GetTwoFactorPolicy, middleware.RequirePermission(middleware.PermSettingsManage))
+	adminGroup.PUT("/settings/two-factor", adminController.UpdateTwoFactorPolicy, middleware.RequirePermission(middleware.PermSettingsManage))
+	adminGroup.GET("/audit-events", adminController.ListAuditEvents, middleware.RequirePermission(middleware.PermAuditRead))
+	adminGroup.GET("/companies/:id/branches", companyController.GetCompanyBranches, middleware.RequirePermission(middleware.PermBranchReadAny))
+}
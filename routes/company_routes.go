@@ -7,15 +7,22 @@ import (
 )
 
 func RegisterCompanyRoutes(e *echo.Echo, companyController *controllers.CompanyController) {
-	// Protected routes - require authentication
+	// Protected routes - require a user login or a company API key sent in X-API-Key
 	companyGroup := e.Group("/api/company")
-	companyGroup.Use(middleware.JWTMiddleware())
+	companyGroup.Use(middleware.AuthenticateTokenOrAPIKey(companyController.DB))
+	companyGroup.Use(middleware.RequireTwoFactorEnrollment(companyController.DB))
+	companyGroup.Use(middleware.RequireVerifiedEmail(companyController.DB))
 
-	companyGroup.GET("/data", companyController.GetCompanyData)
-	companyGroup.PUT("/data", companyController.UpdateCompanyData)
-	companyGroup.POST("/branches", companyController.CreateBranch)
-	companyGroup.GET("/branches", companyController.GetBranches)
-	companyGroup.DELETE("/branches/:id", companyController.DeleteBranch)
-	companyGroup.PUT("/branches/:id", companyController.UpdateBranch)
+	companyGroup.GET("/data", companyController.GetCompanyData, middleware.RequirePermission(middleware.PermCompanyRead))
+	companyGroup.PUT("/data", companyController.UpdateCompanyData, middleware.RequirePermission(middleware.PermCompanyWrite))
+	companyGroup.POST("/branches", companyController.CreateBranch, middleware.RequirePermission(middleware.PermBranchWrite))
+	companyGroup.GET("/branches", companyController.GetBranches, middleware.RequirePermission(middleware.PermBranchRead))
+	companyGroup.DELETE("/branches/:id", companyController.DeleteBranch, middleware.RequirePermission(middleware.PermBranchDelete))
+	companyGroup.PUT("/branches/:id", companyController.UpdateBranch, middleware.RequirePermission(middleware.PermBranchWrite))
+
+	// API keys can only be managed after logging in; no key scope grants PermAPIKeysManage
+	companyGroup.POST("/api-keys", companyController.CreateAPIKey, middleware.RequirePermission(middleware.PermAPIKeysManage))
+	companyGroup.GET("/api-keys", companyController.ListAPIKeys, middleware.RequirePermission(middleware.PermAPIKeysManage))
+	companyGroup.DELETE("/api-keys/:id", companyController.RevokeAPIKey, middleware.RequirePermission(middleware.PermAPIKeysManage))
 
 }
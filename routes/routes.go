@@ -4,7 +4,6 @@ import (
 	"path/filepath"
 
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/HSouheill/barrim_backend/controllers"
@@ -22,6 +21,13 @@ func SetupRoutes(e *echo.Echo, db *mongo.Client) {
 	e.POST("/api/auth/signup", authController.Signup)
 	e.POST("/api/auth/login", authController.Login)
 	e.POST("api/auth/google", authController.GoogleLogin)
+	e.POST("/api/auth/refresh", authController.RefreshToken)
+	e.POST("/api/auth/verify-email", authController.VerifyEmail)
+	e.POST("/api/auth/resend-verification", authController.ResendVerification)
+	e.POST("/api/auth/2fa/verify", authController.VerifyTwoFactor)
+	e.POST("/api/auth/passwordless/request", authController.RequestLoginCode)
+	e.POST("/api/auth/passwordless/verify", authController.VerifyLoginCode)
+	e.POST("/api/auth/restore-account", authController.RestoreAccount)
 
 	// Public routes
 	e.GET("/api/service-providers", userController.SearchServiceProviders)
@@ -30,35 +36,63 @@ func SetupRoutes(e *echo.Echo, db *mongo.Client) {
 	e.POST("/api/auth/verify-otp", passwordController.VerifyOTP)
 	e.POST("/api/auth/reset-password", passwordController.ResetPassword)
 	e.GET("/uploads/:filename", controllers.ServeImage)
+	e.GET("/.well-known/jwks.json", controllers.ServeJWKS)
+	// Data export downloads are authorized by the token in the emailed link
+	e.GET("/api/data-exports/:id/download", userController.DownloadDataExport)
+
+	// Session routes stay available to accounts that have not verified their email yet
+	session := e.Group("/api/auth")
+	session.Use(customMiddleware.Authenticate(db))
+	session.POST("/logout", authController.Logout)
+	session.POST("/logout-all", authController.LogoutAll, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	// Two-factor enrollment must stay reachable for accounts the policy is still blocking
+	session.POST("/2fa/setup", authController.SetupTwoFactor, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	session.POST("/2fa/enable", authController.EnableTwoFactor, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	session.POST("/2fa/disable", authController.DisableTwoFactor, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	session.POST("/2fa/recovery-codes", authController.RegenerateRecoveryCodes, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
 
 	// Protected routes
 	r := e.Group("/api")
-	r.Use(middleware.JWTWithConfig(customMiddleware.GetJWTConfig()))
+	r.Use(customMiddleware.Authenticate(db))
+	r.Use(customMiddleware.RequireVerifiedEmail(db))
+	r.Use(customMiddleware.RequireTwoFactorEnrollment(db))
 
 	// User routes
-	r.GET("/users", userController.GetAllUsers)
-	r.GET("/users/profile", userController.GetProfile)
-	r.PUT("/users/profile", userController.UpdateProfile)
-	r.PUT("/users/location", userController.UpdateLocation) // Existing route for updating location
-	r.DELETE("/users", userController.DeleteUser)
-	r.POST("/upload-logo", userController.UploadCompanyLogo)
-	r.POST("/upload-profile-photo", userController.UploadProfilePhoto)
-	r.POST("/update-availability", userController.UpdateAvailability)
+	r.GET("/users", userController.GetAllUsers, customMiddleware.RequirePermission(customMiddleware.PermUsersList))
+	r.GET("/users/profile", userController.GetProfile, customMiddleware.RequirePermission(customMiddleware.PermProfileRead))
+	r.PUT("/users/profile", userController.UpdateProfile, customMiddleware.RequirePermission(customMiddleware.PermProfileWrite))
+	r.PATCH("/users/profile", userController.UpdateProfile, customMiddleware.RequirePermission(customMiddleware.PermProfileWrite))
+	r.PUT("/users/location", userController.UpdateLocation, customMiddleware.RequirePermission(customMiddleware.PermLocationWrite)) // Existing route for updating location
+	r.DELETE("/users", userController.DeleteUser, customMiddleware.RequirePermission(customMiddleware.PermAccountDelete))
+	r.PUT("/users/password", passwordController.ChangePassword, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	r.POST("/users/email", userController.RequestEmailChange, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	r.POST("/users/email/confirm", userController.ConfirmEmailChange, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	r.POST("/users/phone/send-code", userController.SendPhoneVerification, customMiddleware.RequirePermission(customMiddleware.PermProfileWrite))
+	r.POST("/users/phone/verify", userController.VerifyPhone, customMiddleware.RequirePermission(customMiddleware.PermProfileWrite))
+	r.POST("/users/password", authController.SetPassword, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	r.GET("/users/identities", authController.GetIdentities, customMiddleware.RequirePermission(customMiddleware.PermProfileRead))
+	r.POST("/users/identities/google", authController.LinkGoogle, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	r.DELETE("/users/identities/:provider", authController.UnlinkIdentity, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	r.GET("/users/sessions", authController.GetSessions, customMiddleware.RequirePermission(customMiddleware.PermProfileRead))
+	r.DELETE("/users/sessions/:id", authController.RevokeUserSession, customMiddleware.RequirePermission(customMiddleware.PermCredentialsManage))
+	r.POST("/users/data-exports", userController.RequestDataExport, customMiddleware.RequirePermission(customMiddleware.PermDataExport))
+	r.GET("/users/data-exports", userController.ListDataExports, customMiddleware.RequirePermission(customMiddleware.PermDataExport))
+	r.POST("/upload-logo", userController.UploadCompanyLogo, customMiddleware.RequirePermission(customMiddleware.PermCompanyLogoWrite))
+	r.POST("/upload-profile-photo", userController.UploadProfilePhoto, customMiddleware.RequirePermission(customMiddleware.PermProviderPhotoWrite))
+	r.POST("/update-availability", userController.UpdateAvailability, customMiddleware.RequirePermission(customMiddleware.PermProviderAvailabilityWrite))
 	// In your routes.go file, add this line to the protected routes section:
-	r.GET("/user/companies", userController.GetCompaniesWithLocations)
+	r.GET("/user/companies", userController.GetCompaniesWithLocations, customMiddleware.RequirePermission(customMiddleware.PermCompaniesList))
 	// Add the new save-locations route
-	r.POST("/save-locations", userController.UpdateLocation) // Reuse the UpdateLocation method
+	r.POST("/save-locations", userController.UpdateLocation, customMiddleware.RequirePermission(customMiddleware.PermLocationWrite)) // Reuse the UpdateLocation method
 
 	// Company-specific routes
 	company := r.Group("/api/company")
-	company.Use(customMiddleware.RequireUserType("company", "wholesaler"))
-	company.POST("/logo", userController.UploadCompanyLogo)
+	company.POST("/logo", userController.UploadCompanyLogo, customMiddleware.RequirePermission(customMiddleware.PermCompanyLogoWrite))
 
 	// Service provider specific routes
 	serviceProvider := r.Group("/service-provider")
-	serviceProvider.Use(customMiddleware.RequireUserType("serviceProvider"))
-	serviceProvider.POST("/availability", userController.UpdateAvailability)
-	serviceProvider.POST("/photo", userController.UploadProfilePhoto)
+	serviceProvider.POST("/availability", userController.UpdateAvailability, customMiddleware.RequirePermission(customMiddleware.PermProviderAvailabilityWrite))
+	serviceProvider.POST("/photo", userController.UploadProfilePhoto, customMiddleware.RequirePermission(customMiddleware.PermProviderPhotoWrite))
 }
 
 // Helper function to check place types
@@ -0,0 +1,36 @@
+#!/bin/sh
+# Generates a JWT signing key for the key ring read from JWT_KEYS_DIR.
+#
+# Usage: scripts/generate-jwt-key.sh [keys-dir] [ed25519|rsa]
+#
+# The key is written to <keys-dir>/<kid>.pem, where kid is today's date, and the
+# JWT_ACTIVE_KID line to use is printed. To rotate, generate a new key, point
+# JWT_ACTIVE_KID at it and keep the old file until the tokens it signed have expired;
+# replacing the old file with its public half (openssl pkey -pubout) keeps it
+# verifiable without letting it sign.
+set -eu
+
+dir="${1:-keys}"
+algorithm="${2:-ed25519}"
+kid="$(date +%Y%m%d)"
+path="$dir/$kid.pem"
+
+mkdir -p "$dir"
+if [ -e "$path" ]; then
+	echo "$path already exists" >&2
+	exit 1
+fi
+
+case "$algorithm" in
+ed25519) openssl genpkey -algorithm ed25519 -out "$path" ;;
+rsa) openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:3072 -out "$path" ;;
+*)
+	echo "unknown algorithm $algorithm, use ed25519 or rsa" >&2
+	exit 1
+	;;
+esac
+chmod 600 "$path"
+
+echo "Wrote $path"
+echo "JWT_KEYS_DIR=$dir"
+echo "JWT_ACTIVE_KID=$kid"
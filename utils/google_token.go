@@ -0,0 +1,204 @@
+// utils/google_token.go
+package utils
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// GoogleJWKSURL is where Google publishes the keys that sign its ID tokens
+const GoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// googleIssuers are the accepted values of the iss claim
+var googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+// ErrInvalidGoogleToken is returned when an ID token fails verification
+var ErrInvalidGoogleToken = errors.New("invalid google id token")
+
+// GoogleKeySource supplies the public keys used to verify Google ID tokens
+type GoogleKeySource interface {
+	PublicKey(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// StaticKeySource serves a fixed set of keys, e.g. a locally generated key in tests
+type StaticKeySource map[string]*rsa.PublicKey
+
+// PublicKey returns the key with the given id
+func (s StaticKeySource) PublicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// JWKSKeySource fetches a remote JWKS document and caches it for as long as the server allows
+type JWKSKeySource struct {
+	URL    string
+	Client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	expiresAt   time.Time
+	lastFetched time.Time
+}
+
+// NewJWKSKeySource creates a cached key source for the given JWKS URL
+func NewJWKSKeySource(url string) *JWKSKeySource {
+	return &JWKSKeySource{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// PublicKey returns the key with the given id, refreshing the cache when it is stale or the id is unknown
+func (s *JWKSKeySource) PublicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if key, ok := s.keys[kid]; ok && now.Before(s.expiresAt) {
+		return key, nil
+	}
+
+	// Unknown ids trigger a refresh, but not more than once a minute
+	if now.After(s.expiresAt) || now.Sub(s.lastFetched) > time.Minute {
+		if err := s.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// refresh downloads the key set; the caller must hold the lock
+func (s *JWKSKeySource) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: unexpected status %d", s.URL, resp.StatusCode)
+	}
+
+	var set JWKSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.RSAPublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	s.keys = keys
+	s.lastFetched = time.Now()
+	s.expiresAt = s.lastFetched.Add(cacheMaxAge(resp.Header.Get("Cache-Control"), time.Hour))
+	return nil
+}
+
+// cacheMaxAge extracts max-age from a Cache-Control header
+func cacheMaxAge(header string, fallback time.Duration) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if strings.HasPrefix(directive, "max-age=") {
+			if seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return fallback
+}
+
+// GoogleClaims are the claims of a Google ID token
+type GoogleClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	jwt.StandardClaims
+}
+
+// GoogleTokenVerifier checks Google ID tokens against a key source and the allowed client IDs
+type GoogleTokenVerifier struct {
+	Keys      GoogleKeySource
+	Audiences []string
+}
+
+// NewGoogleTokenVerifier creates a verifier accepting tokens issued to any of the given client IDs
+func NewGoogleTokenVerifier(keys GoogleKeySource, audiences ...string) *GoogleTokenVerifier {
+	return &GoogleTokenVerifier{Keys: keys, Audiences: audiences}
+}
+
+// Verify validates the signature, issuer, audience and expiry of an ID token and returns its claims
+func (v *GoogleTokenVerifier) Verify(ctx context.Context, idToken string) (*GoogleClaims, error) {
+	claims := &GoogleClaims{}
+	token, err := jwt.ParseWithClaims(idToken, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return v.Keys.PublicKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGoogleToken, err)
+	}
+	if !token.Valid {
+		return nil, ErrInvalidGoogleToken
+	}
+
+	// Valid() only checks exp when present, so require it explicitly
+	if claims.ExpiresAt == 0 {
+		return nil, fmt.Errorf("%w: missing expiry", ErrInvalidGoogleToken)
+	}
+
+	issuerOK := false
+	for _, issuer := range googleIssuers {
+		if claims.Issuer == issuer {
+			issuerOK = true
+			break
+		}
+	}
+	if !issuerOK {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidGoogleToken, claims.Issuer)
+	}
+
+	audienceOK := false
+	for _, audience := range v.Audiences {
+		if claims.VerifyAudience(audience, true) {
+			audienceOK = true
+			break
+		}
+	}
+	if !audienceOK {
+		return nil, fmt.Errorf("%w: unexpected audience %q", ErrInvalidGoogleToken, claims.Audience)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidGoogleToken)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const testClientID = "client-id.apps.googleusercontent.com"
+
+func generateRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+	return key
+}
+
+func signGoogleToken(t *testing.T, key *rsa.PrivateKey, kid string, claims GoogleClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return signed
+}
+
+func TestGoogleTokenVerifier(t *testing.T) {
+	key := generateRSAKey(t)
+	otherKey := generateRSAKey(t)
+	verifier := NewGoogleTokenVerifier(StaticKeySource{"k1": &key.PublicKey}, testClientID)
+
+	valid := func() GoogleClaims {
+		return GoogleClaims{
+			Email:         "user@example.com",
+			EmailVerified: true,
+			StandardClaims: jwt.StandardClaims{
+				Issuer:    "https://accounts.google.com",
+				Audience:  testClientID,
+				Subject:   "1234567890",
+				ExpiresAt: time.Now().Add(time.Hour).Unix(),
+				IssuedAt:  time.Now().Unix(),
+			},
+		}
+	}
+
+	// An HS256 token keyed with the public modulus must not pass as RS256
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, valid())
+	hmacToken.Header["kid"] = "k1"
+	hmacSigned, err := hmacToken.SignedString(key.PublicKey.N.Bytes())
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr bool
+	}{
+		{"valid", func() string { return signGoogleToken(t, key, "k1", valid()) }, false},
+		{"issuer without scheme", func() string {
+			c := valid()
+			c.Issuer = "accounts.google.com"
+			return signGoogleToken(t, key, "k1", c)
+		}, false},
+		{"wrong issuer", func() string {
+			c := valid()
+			c.Issuer = "https://evil.example.com"
+			return signGoogleToken(t, key, "k1", c)
+		}, true},
+		{"wrong audience", func() string {
+			c := valid()
+			c.Audience = "another-client"
+			return signGoogleToken(t, key, "k1", c)
+		}, true},
+		{"expired", func() string {
+			c := valid()
+			c.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+			return signGoogleToken(t, key, "k1", c)
+		}, true},
+		{"missing expiry", func() string {
+			c := valid()
+			c.ExpiresAt = 0
+			return signGoogleToken(t, key, "k1", c)
+		}, true},
+		{"missing subject", func() string {
+			c := valid()
+			c.Subject = ""
+			return signGoogleToken(t, key, "k1", c)
+		}, true},
+		{"unknown key id", func() string { return signGoogleToken(t, key, "k2", valid()) }, true},
+		{"signed by another key", func() string { return signGoogleToken(t, otherKey, "k1", valid()) }, true},
+		{"hmac signed", func() string { return hmacSigned }, true},
+		{"malformed", func() string { return "not.a.token" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), tt.token())
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidGoogleToken) {
+					t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidGoogleToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if claims.Email != "user@example.com" || claims.Subject != "1234567890" {
+				t.Errorf("Verify() claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestJWKSKeySourceCaches(t *testing.T) {
+	key := generateRSAKey(t)
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Cache-Control", "public, max-age=3600, must-revalidate")
+		json.NewEncoder(w).Encode(JWKSet{Keys: []JWK{NewRSAJWK("k1", &key.PublicKey)}})
+	}))
+	defer server.Close()
+
+	source := NewJWKSKeySource(server.URL)
+	for i := 0; i < 3; i++ {
+		got, err := source.PublicKey(context.Background(), "k1")
+		if err != nil {
+			t.Fatalf("PublicKey() error = %v", err)
+		}
+		if got.N.Cmp(key.PublicKey.N) != 0 {
+			t.Fatal("PublicKey() returned a different key")
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("fetched the key set %d times, want 1", n)
+	}
+
+	// Unknown ids do not hammer the endpoint right after a fetch
+	if _, err := source.PublicKey(context.Background(), "unknown"); err == nil {
+		t.Error("PublicKey() accepted an unknown key id")
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("fetched the key set %d times after an unknown id, want 1", n)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"public, max-age=19800, must-revalidate", 19800 * time.Second},
+		{"max-age=60", time.Minute},
+		{"no-store", time.Hour},
+		{"max-age=0", time.Hour},
+		{"max-age=abc", time.Hour},
+		{"", time.Hour},
+	}
+	for _, tt := range tests {
+		if got := cacheMaxAge(tt.header, time.Hour); got != tt.want {
+			t.Errorf("cacheMaxAge(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
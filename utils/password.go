@@ -2,19 +2,187 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword creates a bcrypt hash from a password
+// Password hashing algorithms. Stored hashes carry their algorithm: bcrypt hashes start with
+// $2a$/$2b$ and argon2id hashes use the PHC string format, $argon2id$v=19$m=...,t=...,p=...$salt$hash.
+const (
+	PasswordAlgorithmBcrypt   = "bcrypt"
+	PasswordAlgorithmArgon2id = "argon2id"
+)
+
+// ErrUnsupportedPasswordHash is returned for stored hashes in an unknown format
+var ErrUnsupportedPasswordHash = errors.New("unsupported password hash format")
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// PasswordHashConfig selects the algorithm and cost used for new password hashes
+type PasswordHashConfig struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32 // KiB
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+var (
+	passwordHashConfig     PasswordHashConfig
+	passwordHashConfigOnce sync.Once
+)
+
+// CurrentPasswordHashConfig returns the hashing policy read from PASSWORD_HASH_ALGORITHM,
+// BCRYPT_COST and the ARGON2_* variables. Invalid values fall back to the defaults.
+func CurrentPasswordHashConfig() PasswordHashConfig {
+	passwordHashConfigOnce.Do(func() {
+		passwordHashConfig = PasswordHashConfig{
+			Algorithm:         PasswordAlgorithmBcrypt,
+			BcryptCost:        envInt("BCRYPT_COST", bcrypt.DefaultCost),
+			Argon2Memory:      uint32(envInt("ARGON2_MEMORY_KB", 64*1024)),
+			Argon2Iterations:  uint32(envInt("ARGON2_ITERATIONS", 3)),
+			Argon2Parallelism: uint8(envInt("ARGON2_PARALLELISM", 2)),
+		}
+		if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm == PasswordAlgorithmArgon2id {
+			passwordHashConfig.Algorithm = algorithm
+		} else if algorithm != "" && algorithm != PasswordAlgorithmBcrypt {
+			log.Printf("Unknown PASSWORD_HASH_ALGORITHM %q, using bcrypt", algorithm)
+		}
+		if passwordHashConfig.BcryptCost < bcrypt.MinCost || passwordHashConfig.BcryptCost > bcrypt.MaxCost {
+			log.Printf("BCRYPT_COST %d is out of range, using %d", passwordHashConfig.BcryptCost, bcrypt.DefaultCost)
+			passwordHashConfig.BcryptCost = bcrypt.DefaultCost
+		}
+		// argon2 panics on zero iterations or threads
+		if passwordHashConfig.Argon2Iterations < 1 {
+			passwordHashConfig.Argon2Iterations = 1
+		}
+		if passwordHashConfig.Argon2Parallelism < 1 {
+			passwordHashConfig.Argon2Parallelism = 1
+		}
+	})
+	return passwordHashConfig
+}
+
+// HashPassword hashes a password with the configured algorithm and cost
 func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	cfg := CurrentPasswordHashConfig()
+	if cfg.Algorithm == PasswordAlgorithmArgon2id {
+		return hashArgon2id(password, cfg)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cfg.BcryptCost)
 	if err != nil {
 		return "", err
 	}
 	return string(hashedPassword), nil
 }
 
-// CheckPassword compares a password with a hash
+// CheckPassword compares a password with a hash of any supported algorithm.
+// It returns bcrypt.ErrMismatchedHashAndPassword when the password is wrong.
 func CheckPassword(password, hash string) error {
+	if strings.HasPrefix(hash, "$"+PasswordAlgorithmArgon2id+"$") {
+		return checkArgon2id(password, hash)
+	}
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
+
+// PasswordNeedsRehash reports whether a stored hash uses a different algorithm or a lower
+// cost than the current policy, so it should be replaced after the next successful login
+func PasswordNeedsRehash(hash string) bool {
+	cfg := CurrentPasswordHashConfig()
+
+	if strings.HasPrefix(hash, "$"+PasswordAlgorithmArgon2id+"$") {
+		if cfg.Algorithm != PasswordAlgorithmArgon2id {
+			return true
+		}
+		params, _, _, err := decodeArgon2id(hash)
+		return err != nil ||
+			params.Argon2Memory < cfg.Argon2Memory ||
+			params.Argon2Iterations < cfg.Argon2Iterations ||
+			params.Argon2Parallelism < cfg.Argon2Parallelism
+	}
+
+	if cfg.Algorithm != PasswordAlgorithmBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < cfg.BcryptCost
+}
+
+// hashArgon2id hashes a password with argon2id and encodes it as a PHC string
+func hashArgon2id(password string, cfg PasswordHashConfig) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, cfg.Argon2Iterations, cfg.Argon2Memory, cfg.Argon2Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkArgon2id compares a password with an argon2id PHC string in constant time
+func checkArgon2id(password, hash string) error {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.Argon2Iterations, params.Argon2Memory, params.Argon2Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// decodeArgon2id parses an argon2id PHC string into its parameters, salt and key
+func decodeArgon2id(hash string) (PasswordHashConfig, []byte, []byte, error) {
+	params := PasswordHashConfig{Algorithm: PasswordAlgorithmArgon2id}
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != PasswordAlgorithmArgon2id {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Argon2Memory, &params.Argon2Iterations, &params.Argon2Parallelism); err != nil ||
+		params.Argon2Iterations < 1 || params.Argon2Parallelism < 1 {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+	return params, salt, key, nil
+}
+
+// envInt reads an integer environment variable, falling back when it is unset or invalid
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
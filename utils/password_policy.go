@@ -0,0 +1,149 @@
+// utils/password_policy.go
+package utils
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// commonPasswords is the bundled offline list of common and breached passwords
+var commonPasswords = loadCommonPasswords(commonPasswordsFile)
+
+// Password policy violation codes
+const (
+	PasswordTooShort     = "too_short"
+	PasswordTooLong      = "too_long"
+	PasswordNoUppercase  = "missing_uppercase"
+	PasswordNoLowercase  = "missing_lowercase"
+	PasswordNoDigit      = "missing_digit"
+	PasswordNoSymbol     = "missing_symbol"
+	PasswordPersonalInfo = "contains_personal_info"
+	PasswordCommon       = "common_password"
+)
+
+// minPersonalInfoLength is the shortest part of an email or name that a password may not contain
+const minPersonalInfoLength = 3
+
+// PasswordPolicy describes what a new password must satisfy
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int // bcrypt ignores everything past 72 bytes
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	RejectCommon     bool
+}
+
+// PasswordViolation is one rule a password failed
+type PasswordViolation struct {
+	Code    string
+	Message string
+}
+
+// Validate checks a password against the policy. personalInfo holds values the password must
+// not contain, such as the account's email and name. It returns nil when the password is acceptable.
+func (p PasswordPolicy) Validate(password string, personalInfo ...string) []PasswordViolation {
+	var violations []PasswordViolation
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, PasswordViolation{PasswordTooShort,
+			fmt.Sprintf("Password must be at least %d characters long", p.MinLength)})
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, PasswordViolation{PasswordTooLong,
+			fmt.Sprintf("Password must be at most %d bytes long", p.MaxLength)})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUppercase && !hasUpper {
+		violations = append(violations, PasswordViolation{PasswordNoUppercase, "Password must contain an uppercase letter"})
+	}
+	if p.RequireLowercase && !hasLower {
+		violations = append(violations, PasswordViolation{PasswordNoLowercase, "Password must contain a lowercase letter"})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{PasswordNoDigit, "Password must contain a digit"})
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordViolation{PasswordNoSymbol, "Password must contain a symbol"})
+	}
+
+	if containsPersonalInfo(password, personalInfo) {
+		violations = append(violations, PasswordViolation{PasswordPersonalInfo, "Password must not contain your email or name"})
+	}
+	if p.RejectCommon && IsCommonPassword(password) {
+		violations = append(violations, PasswordViolation{PasswordCommon, "This password is too common. Please choose another"})
+	}
+
+	return violations
+}
+
+// IsCommonPassword reports whether the password, ignoring case and trailing digits or symbols,
+// is on the bundled list of common and breached passwords
+func IsCommonPassword(password string) bool {
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return true
+	}
+	// "Password1!" is as weak as "password"
+	base := strings.TrimRightFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	return base != "" && base != lower && commonPasswords[base]
+}
+
+// containsPersonalInfo reports whether the password contains any of the values, or the parts of
+// an email address or name, ignoring case
+func containsPersonalInfo(password string, values []string) bool {
+	lower := strings.ToLower(password)
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		parts := strings.FieldsFunc(value, func(r rune) bool {
+			return r == '@' || r == '.' || r == '_' || r == '-' || r == '+' || unicode.IsSpace(r)
+		})
+		// The domain of an email is left out; "gmail" in a password says nothing about the user
+		if at := strings.Index(value, "@"); at > 0 {
+			parts = strings.FieldsFunc(value[:at], func(r rune) bool {
+				return r == '.' || r == '_' || r == '-' || r == '+'
+			})
+			parts = append(parts, value[:at])
+		}
+		for _, part := range parts {
+			if len(part) >= minPersonalInfoLength && strings.Contains(lower, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadCommonPasswords parses the embedded list, skipping blank lines and comments
+func loadCommonPasswords(list string) map[string]bool {
+	passwords := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = true
+	}
+	return passwords
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:        8,
+		MaxLength:        72,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		RejectCommon:     true,
+	}
+
+	tests := []struct {
+		name         string
+		policy       PasswordPolicy
+		password     string
+		personalInfo []string
+		want         []string
+	}{
+		{"strong password", strict, "Tr0ub4dor&3x", nil, nil},
+		{"too short", strict, "Ab1!", nil, []string{PasswordTooShort}},
+		{"too long", strict, "Aa1!" + strings.Repeat("x", 70), nil, []string{PasswordTooLong}},
+		{"length counts runes", PasswordPolicy{MinLength: 4}, "éééé", nil, nil},
+		{"missing uppercase", strict, "tr0ub4dor&3x", nil, []string{PasswordNoUppercase}},
+		{"missing lowercase", strict, "TR0UB4DOR&3X", nil, []string{PasswordNoLowercase}},
+		{"missing digit", strict, "Troubador&xx", nil, []string{PasswordNoDigit}},
+		{"missing symbol", strict, "Tr0ub4dor3xx", nil, []string{PasswordNoSymbol}},
+		{"space counts as symbol", strict, "Tr0ub4dor 3x", nil, nil},
+		{"several violations", strict, "abc", nil, []string{PasswordTooShort, PasswordNoUppercase, PasswordNoDigit, PasswordNoSymbol}},
+		{"contains email local part", strict, "Jsmith#2024x", []string{"j.smith@example.com"}, []string{PasswordPersonalInfo}},
+		{"email domain is allowed", strict, "Example#2024x", []string{"jo@example.com"}, nil},
+		{"contains part of name", strict, "Hussein#2024x", []string{"Hussein Souheil"}, []string{PasswordPersonalInfo}},
+		{"short name parts are ignored", strict, "Al#Tr0ub4dor", []string{"Al Bo"}, nil},
+		{"common password", strict, "Password1!", nil, []string{PasswordCommon}},
+		{"common passwords allowed by policy", PasswordPolicy{MinLength: 8}, "password", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range tt.policy.Validate(tt.password, tt.personalInfo...) {
+				got = append(got, v.Code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%q) codes = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCommonPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"PASSWORD", true},
+		{"Password1!", true},
+		{"qwerty123", true},
+		{"letmein!!", true},
+		{"passwordx", false},
+		{"123!!", false},
+		{"Tr0ub4dor&3x", false},
+	}
+	for _, tt := range tests {
+		if got := IsCommonPassword(tt.password); got != tt.want {
+			t.Errorf("IsCommonPassword(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
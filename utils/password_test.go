@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// usePasswordHashConfig replaces the hashing policy for the duration of a test
+func usePasswordHashConfig(t *testing.T, cfg PasswordHashConfig) {
+	t.Helper()
+	passwordHashConfigOnce.Do(func() {})
+	previous := passwordHashConfig
+	passwordHashConfig = cfg
+	t.Cleanup(func() { passwordHashConfig = previous })
+}
+
+var (
+	testBcryptConfig = PasswordHashConfig{Algorithm: PasswordAlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1}
+	testArgon2Config = PasswordHashConfig{Algorithm: PasswordAlgorithmArgon2id, Argon2Memory: 1024, Argon2Iterations: 2, Argon2Parallelism: 1}
+)
+
+func TestHashPasswordArgon2id(t *testing.T) {
+	usePasswordHashConfig(t, testArgon2Config)
+
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=2,p=1$") {
+		t.Errorf("HashPassword() = %q, want an argon2id PHC string", hash)
+	}
+	if err := CheckPassword("correct horse", hash); err != nil {
+		t.Errorf("CheckPassword() with the right password error = %v", err)
+	}
+	if err := CheckPassword("wrong horse", hash); err != bcrypt.ErrMismatchedHashAndPassword {
+		t.Errorf("CheckPassword() with a wrong password error = %v, want %v", err, bcrypt.ErrMismatchedHashAndPassword)
+	}
+
+	other, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	if other == hash {
+		t.Error("HashPassword() reused a salt")
+	}
+}
+
+func TestCheckPasswordAcrossAlgorithms(t *testing.T) {
+	usePasswordHashConfig(t, testBcryptConfig)
+	bcryptHash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	// Existing bcrypt hashes keep working after switching to argon2id
+	usePasswordHashConfig(t, testArgon2Config)
+	if err := CheckPassword("secret", bcryptHash); err != nil {
+		t.Errorf("CheckPassword() on a bcrypt hash error = %v", err)
+	}
+	if err := CheckPassword("other", bcryptHash); err != bcrypt.ErrMismatchedHashAndPassword {
+		t.Errorf("CheckPassword() with a wrong password error = %v", err)
+	}
+}
+
+func TestCheckPasswordMalformedArgon2id(t *testing.T) {
+	tests := []string{
+		"$argon2id$v=19$m=1024,t=2,p=1$c2FsdA",
+		"$argon2id$v=18$m=1024,t=2,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=2,p=0$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=2,p=1$!!!$a2V5",
+		"$argon2id$v=19$m=1024,t=2,p=1$c2FsdA$",
+	}
+	for _, hash := range tests {
+		if err := CheckPassword("secret", hash); err != ErrUnsupportedPasswordHash {
+			t.Errorf("CheckPassword(%q) error = %v, want %v", hash, err, ErrUnsupportedPasswordHash)
+		}
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	hashWith := func(cfg PasswordHashConfig) string {
+		usePasswordHashConfig(t, cfg)
+		hash, err := HashPassword("secret")
+		if err != nil {
+			t.Fatalf("HashPassword() error = %v", err)
+		}
+		return hash
+	}
+	cheapBcrypt := hashWith(PasswordHashConfig{Algorithm: PasswordAlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	currentBcrypt := hashWith(testBcryptConfig)
+	weakArgon2 := hashWith(PasswordHashConfig{Algorithm: PasswordAlgorithmArgon2id, Argon2Memory: 512, Argon2Iterations: 2, Argon2Parallelism: 1})
+	currentArgon2 := hashWith(testArgon2Config)
+
+	tests := []struct {
+		name   string
+		policy PasswordHashConfig
+		hash   string
+		want   bool
+	}{
+		{"bcrypt at the current cost", testBcryptConfig, currentBcrypt, false},
+		{"bcrypt below the current cost", testBcryptConfig, cheapBcrypt, true},
+		{"argon2id under a bcrypt policy", testBcryptConfig, currentArgon2, true},
+		{"bcrypt under an argon2id policy", testArgon2Config, currentBcrypt, true},
+		{"argon2id with the current parameters", testArgon2Config, currentArgon2, false},
+		{"argon2id with less memory", testArgon2Config, weakArgon2, true},
+		{"malformed argon2id", testArgon2Config, "$argon2id$v=19$broken", true},
+		{"unknown format", testBcryptConfig, "plaintext", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePasswordHashConfig(t, tt.policy)
+			if got := PasswordNeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("PasswordNeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
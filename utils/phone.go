@@ -0,0 +1,56 @@
+// utils/phone.go
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidPhone is returned for numbers that cannot be normalized to E.164
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// NormalizePhone converts a phone number to E.164 (+<country code><number>).
+// Numbers without an international prefix are read as national numbers of defaultCountryCode
+// (digits only, e.g. "961"); their leading trunk zeros are dropped.
+func NormalizePhone(raw, defaultCountryCode string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+			// Formatting characters carry no information
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	phone := b.String()
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(phone, "00"):
+		phone = "+" + phone[2:]
+	case defaultCountryCode != "":
+		phone = "+" + strings.TrimPrefix(defaultCountryCode, "+") + strings.TrimLeft(phone, "0")
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	if !e164Pattern.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+	return phone, nil
+}
+
+// MaskPhone hides all but the last digits of a phone number for display
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return phone
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
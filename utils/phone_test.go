@@ -0,0 +1,58 @@
+package utils
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		countryCode string
+		want        string
+		wantErr     bool
+	}{
+		{"already e164", "+96170123456", "", "+96170123456", false},
+		{"formatting characters", "+961 (70) 123-456", "", "+96170123456", false},
+		{"surrounding whitespace", "  +96170123456 ", "", "+96170123456", false},
+		{"00 international prefix", "0096170123456", "", "+96170123456", false},
+		{"national with trunk zero", "070 123 456", "961", "+96170123456", false},
+		{"country code with plus", "70123456", "+961", "+96170123456", false},
+		{"national without default country", "70123456", "", "", true},
+		{"letters", "+9617012345a", "", "", true},
+		{"plus in the middle", "961+70123456", "961", "", true},
+		{"too short", "+9611234", "", "", true},
+		{"too long", "+1234567890123456", "", "", true},
+		{"leading zero country code", "+06170123456", "", "", true},
+		{"empty", "", "961", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePhone(tt.raw, tt.countryCode)
+			if tt.wantErr {
+				if err != ErrInvalidPhone {
+					t.Errorf("NormalizePhone(%q) = %q, %v, want ErrInvalidPhone", tt.raw, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("NormalizePhone(%q) = %q, %v, want %q", tt.raw, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskPhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+	}{
+		{"+96170123456", "********3456"},
+		{"12345", "*2345"},
+		{"1234", "1234"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := MaskPhone(tt.phone); got != tt.want {
+			t.Errorf("MaskPhone(%q) = %q, want %q", tt.phone, got, tt.want)
+		}
+	}
+}
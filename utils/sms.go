@@ -0,0 +1,64 @@
+// utils/sms.go
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// SMSSender delivers text messages to E.164 phone numbers
+type SMSSender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+// ConsoleSMSSender writes messages to the server log instead of sending them, for local development
+type ConsoleSMSSender struct{}
+
+// Send logs the message
+func (ConsoleSMSSender) Send(ctx context.Context, to, message string) error {
+	log.Printf("SMS to %s: %s", to, message)
+	return nil
+}
+
+// FileSMSSender appends messages to a file so tests and scripts can read the codes back
+type FileSMSSender struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Send appends one line per message to the file
+func (s *FileSMSSender) Send(ctx context.Context, to, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), to, message)
+	return err
+}
+
+// NewSMSSenderFromEnv picks the sender named by SMS_PROVIDER ("console" or "file")
+func NewSMSSenderFromEnv() SMSSender {
+	switch provider := os.Getenv("SMS_PROVIDER"); provider {
+	case "file":
+		path := os.Getenv("SMS_FILE_PATH")
+		if path == "" {
+			path = "sms.log"
+		}
+		return &FileSMSSender{Path: path}
+	case "", "console":
+		return ConsoleSMSSender{}
+	default:
+		log.Printf("Warning: unknown SMS_PROVIDER %q, logging messages to the console", provider)
+		return ConsoleSMSSender{}
+	}
+}
@@ -0,0 +1,14 @@
+// utils/token.go
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashToken returns the SHA-256 hex digest stored in place of a raw bearer token.
+// Tokens are random and high-entropy, so a fast unsalted hash is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,433 @@
+// utils/validator.go
+package utils
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Validation error codes
+const (
+	ValidationRequired      = "required"
+	ValidationInvalidFormat = "invalid_format"
+	ValidationInvalidValue  = "invalid_value"
+	ValidationTooShort      = "too_short"
+	ValidationTooLong       = "too_long"
+	ValidationOutOfRange    = "out_of_range"
+)
+
+// FieldViolation is a rule broken by one request field. Field is the JSON path of the value,
+// such as companyInfo.customCategory or availableDays[2].
+type FieldViolation struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// ValidationErrors lists every field a request got wrong
+type ValidationErrors []FieldViolation
+
+func (v ValidationErrors) Error() string {
+	fields := make([]string, len(v))
+	for i, violation := range v {
+		fields[i] = violation.Field + ": " + violation.Message
+	}
+	return "invalid request: " + strings.Join(fields, "; ")
+}
+
+// RequestValidator checks request structs against the rules in their `validate` tags. It
+// satisfies echo.Validator, so handlers run it with c.Validate after binding.
+//
+// Rules are separated by commas:
+//
+//	required            the value must not be empty (nil, "", or an empty slice)
+//	required_if=F v     required when the sibling field F has the value v
+//	omitempty           skip the remaining rules when the value is empty
+//	email               a plain email address
+//	date                a calendar date in YYYY-MM-DD format
+//	min=n, max=n        bounds on string length, slice length or numeric value
+//	oneof=a b c         one of the listed values
+//	dive                apply the rules that follow to every slice element
+//
+// Nested structs and struct pointers are always validated when present. Tags are parsed once
+// per type; Register parses them at startup so a malformed tag stops the server from starting.
+type RequestValidator struct {
+	mu    sync.Mutex
+	types map[reflect.Type][]fieldRules
+}
+
+// rule is one parsed validate rule
+type rule struct {
+	name    string
+	limit   float64  // min and max
+	options []string // oneof
+	field   string   // required_if: the sibling field
+	value   string   // required_if: the value that makes the field required
+}
+
+// fieldRules are the parsed rules of one struct field
+type fieldRules struct {
+	index int
+	name  string
+	rules []rule
+}
+
+// NewRequestValidator returns a validator for request structs
+func NewRequestValidator() *RequestValidator {
+	return &RequestValidator{types: make(map[reflect.Type][]fieldRules)}
+}
+
+// Register parses the validate tags of the given request structs and of the structs they
+// contain, and returns an error describing the first malformed tag
+func (rv *RequestValidator) Register(requests ...interface{}) error {
+	for _, request := range requests {
+		if _, err := rv.structRules(indirectType(reflect.TypeOf(request))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate returns ValidationErrors when any field of the struct breaks its rules, or another
+// error when the struct's validate tags are malformed
+func (rv *RequestValidator) Validate(i interface{}) error {
+	var violations ValidationErrors
+	if err := rv.validateValue(reflect.ValueOf(i), "", &violations); err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return violations
+	}
+	return nil
+}
+
+// validateValue validates the fields of a struct, following pointers
+func (rv *RequestValidator) validateValue(v reflect.Value, path string, violations *ValidationErrors) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields, err := rv.structRules(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		fieldPath := field.name
+		if path != "" {
+			fieldPath = path + "." + field.name
+		}
+
+		value := v.Field(field.index)
+		ok, err := rv.applyRules(v, value, fieldPath, field.rules, violations)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if isNestedStruct(value) {
+			if err := rv.validateValue(value, fieldPath, violations); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// structRules returns the parsed rules of a struct type, parsing its tags on first use
+func (rv *RequestValidator) structRules(t reflect.Type) ([]fieldRules, error) {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	return rv.parseStruct(t)
+}
+
+// parseStruct parses the tags of a struct type and of every struct type reachable from it
+func (rv *RequestValidator) parseStruct(t reflect.Type) ([]fieldRules, error) {
+	if fields, ok := rv.types[t]; ok {
+		return fields, nil
+	}
+	// Registered before the fields are parsed, so recursive types terminate
+	rv.types[t] = nil
+
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		rules, err := parseRules(t, field)
+		if err != nil {
+			delete(rv.types, t)
+			return nil, fmt.Errorf("validate: %s.%s: %w", t.Name(), field.Name, err)
+		}
+		fields = append(fields, fieldRules{index: i, name: name, rules: rules})
+
+		// Nested structs, directly or as slice elements, are validated too
+		nested := indirectType(field.Type)
+		if nested.Kind() == reflect.Slice || nested.Kind() == reflect.Array {
+			nested = indirectType(nested.Elem())
+		}
+		if nested.Kind() == reflect.Struct && nested != reflect.TypeOf(time.Time{}) {
+			if _, err := rv.parseStruct(nested); err != nil {
+				delete(rv.types, t)
+				return nil, err
+			}
+		}
+	}
+	rv.types[t] = fields
+	return fields, nil
+}
+
+// parseRules parses the validate tag of a struct field and checks every rule against the type
+// it applies to
+func parseRules(parent reflect.Type, field reflect.StructField) ([]rule, error) {
+	tag := field.Tag.Get("validate")
+	if tag == "" {
+		return nil, nil
+	}
+
+	target := indirectType(field.Type)
+	var rules []rule
+	for _, raw := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(raw, "=")
+		r := rule{name: name}
+		switch name {
+		case "required", "omitempty":
+		case "required_if":
+			sibling, value, ok := strings.Cut(param, " ")
+			if !ok || sibling == "" {
+				return nil, fmt.Errorf("required_if needs a field and a value, got %q", param)
+			}
+			if _, ok := parent.FieldByName(sibling); !ok {
+				return nil, fmt.Errorf("required_if refers to unknown field %s", sibling)
+			}
+			r.field, r.value = sibling, value
+		case "email", "date":
+			if target.Kind() != reflect.String {
+				return nil, fmt.Errorf("%s on %s field", name, target.Kind())
+			}
+		case "oneof":
+			r.options = strings.Fields(param)
+			if len(r.options) == 0 {
+				return nil, fmt.Errorf("oneof needs at least one value")
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s parameter %q", name, param)
+			}
+			if !hasBound(target.Kind()) {
+				return nil, fmt.Errorf("%s on %s field", name, target.Kind())
+			}
+			r.limit = limit
+		case "dive":
+			if target.Kind() != reflect.Slice && target.Kind() != reflect.Array {
+				return nil, fmt.Errorf("dive on %s field", target.Kind())
+			}
+			// The rules after dive apply to the elements
+			target = indirectType(target.Elem())
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// applyRules checks a value against its rules and reports whether validation of the value
+// should continue into nested structs
+func (rv *RequestValidator) applyRules(parent, value reflect.Value, path string, rules []rule, violations *ValidationErrors) (bool, error) {
+	for i, r := range rules {
+		switch r.name {
+		case "required":
+			if isEmptyValue(value) {
+				violations.add(path, ValidationRequired, "This field is required")
+				return false, nil
+			}
+		case "required_if":
+			other := indirect(parent.FieldByName(r.field))
+			if fmt.Sprint(other.Interface()) == r.value && isEmptyValue(value) {
+				violations.add(path, ValidationRequired, "This field is required")
+				return false, nil
+			}
+		case "omitempty":
+			if isEmptyValue(value) {
+				return false, nil
+			}
+		case "dive":
+			elements := indirect(value)
+			if elements.Kind() != reflect.Slice && elements.Kind() != reflect.Array {
+				return false, nil
+			}
+			for j := 0; j < elements.Len(); j++ {
+				elementPath := fmt.Sprintf("%s[%d]", path, j)
+				element := elements.Index(j)
+				ok, err := rv.applyRules(parent, element, elementPath, rules[i+1:], violations)
+				if err != nil {
+					return false, err
+				}
+				if ok && isNestedStruct(element) {
+					if err := rv.validateValue(element, elementPath, violations); err != nil {
+						return false, err
+					}
+				}
+			}
+			return false, nil
+		default:
+			// Absent optional fields, such as those left out of a partial update, are not checked
+			if value.Kind() == reflect.Ptr && value.IsNil() {
+				continue
+			}
+			if violation, ok := checkRule(r, indirect(value)); !ok {
+				violations.add(path, violation.Code, violation.Message)
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// checkRule applies a single value rule
+func checkRule(r rule, value reflect.Value) (FieldViolation, bool) {
+	switch r.name {
+	case "email":
+		s := value.String()
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return FieldViolation{Code: ValidationInvalidFormat, Message: "Must be a valid email address"}, false
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value.String()); err != nil {
+			return FieldViolation{Code: ValidationInvalidFormat, Message: "Must be a date in YYYY-MM-DD format"}, false
+		}
+	case "oneof":
+		actual := fmt.Sprint(value.Interface())
+		for _, option := range r.options {
+			if actual == option {
+				return FieldViolation{}, true
+			}
+		}
+		return FieldViolation{Code: ValidationInvalidValue, Message: "Must be one of: " + strings.Join(r.options, ", ")}, false
+	case "min", "max":
+		return checkBound(r.name, r.limit, value)
+	}
+	return FieldViolation{}, true
+}
+
+// checkBound applies a min or max rule to a length or a number
+func checkBound(name string, limit float64, value reflect.Value) (FieldViolation, bool) {
+	var n float64
+	isLength := true
+	switch value.Kind() {
+	case reflect.String:
+		n = float64(utf8.RuneCountInString(value.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n = float64(value.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, isLength = float64(value.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, isLength = float64(value.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		n, isLength = value.Float(), false
+	default:
+		return FieldViolation{}, true
+	}
+
+	unit := "characters"
+	if value.Kind() != reflect.String {
+		unit = "items"
+	}
+	switch {
+	case name == "min" && n < limit && limit == 1 && value.Kind() == reflect.String:
+		return FieldViolation{Code: ValidationRequired, Message: "This field cannot be empty"}, false
+	case name == "min" && n < limit && isLength:
+		return FieldViolation{Code: ValidationTooShort, Message: fmt.Sprintf("Must have at least %g %s", limit, unit)}, false
+	case name == "max" && n > limit && isLength:
+		return FieldViolation{Code: ValidationTooLong, Message: fmt.Sprintf("Must have at most %g %s", limit, unit)}, false
+	case name == "min" && n < limit:
+		return FieldViolation{Code: ValidationOutOfRange, Message: fmt.Sprintf("Must be at least %g", limit)}, false
+	case name == "max" && n > limit:
+		return FieldViolation{Code: ValidationOutOfRange, Message: fmt.Sprintf("Must be at most %g", limit)}, false
+	}
+	return FieldViolation{}, true
+}
+
+// hasBound reports whether min and max can be applied to values of the kind
+func hasBound(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func (v *ValidationErrors) add(field, code, message string) {
+	*v = append(*v, FieldViolation{Field: field, Code: code, Message: message})
+}
+
+// jsonFieldName returns the name a struct field has in JSON
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// isEmptyValue reports whether a value counts as missing for required and omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return true
+		}
+		return v.Elem().Kind() == reflect.String && v.Elem().String() == ""
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// isNestedStruct reports whether a value holds a struct whose fields should be validated
+func isNestedStruct(v reflect.Value) bool {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return false
+	}
+	v = indirect(v)
+	return v.Kind() == reflect.Struct && v.Type() != reflect.TypeOf(time.Time{})
+}
+
+// indirect follows pointers to the value they point at
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// indirectType follows pointer types to the type they point at
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
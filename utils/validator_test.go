@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testAddress struct {
+	City    string `json:"city" validate:"required,max=10"`
+	Country string `json:"country,omitempty" validate:"omitempty,oneof=LB FR"`
+}
+
+type testInfo struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type testRequest struct {
+	Email     string       `json:"email" validate:"required,email"`
+	Type      string       `json:"type" validate:"oneof=user company"`
+	Birthday  string       `json:"birthday,omitempty" validate:"omitempty,date"`
+	Age       int          `json:"age" validate:"min=18,max=120"`
+	Nickname  *string      `json:"nickname" validate:"min=1,max=5"`
+	Tags      []string     `json:"tags" validate:"max=2,dive,min=2"`
+	Address   *testAddress `json:"address"`
+	Company   *testInfo    `json:"company" validate:"required_if=Type company"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Ignored   string       `json:"-" validate:"required"`
+	NoTag     string       `validate:"max=3"`
+}
+
+func TestRequestValidator(t *testing.T) {
+	valid := func() testRequest {
+		return testRequest{Email: "user@example.com", Type: "user", Age: 30}
+	}
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name   string
+		modify func(r *testRequest)
+		want   []FieldViolation
+	}{
+		{"valid", func(r *testRequest) {}, nil},
+		{"missing required", func(r *testRequest) { r.Email = "" },
+			[]FieldViolation{{"email", ValidationRequired, "This field is required"}}},
+		{"invalid email", func(r *testRequest) { r.Email = "Jo <jo@example.com>" },
+			[]FieldViolation{{"email", ValidationInvalidFormat, "Must be a valid email address"}}},
+		{"value not in oneof", func(r *testRequest) { r.Type = "admin" },
+			[]FieldViolation{{"type", ValidationInvalidValue, "Must be one of: user, company"}}},
+		{"valid date", func(r *testRequest) { r.Birthday = "1990-02-28" }, nil},
+		{"invalid date", func(r *testRequest) { r.Birthday = "28/02/1990" },
+			[]FieldViolation{{"birthday", ValidationInvalidFormat, "Must be a date in YYYY-MM-DD format"}}},
+		{"number below min", func(r *testRequest) { r.Age = 17 },
+			[]FieldViolation{{"age", ValidationOutOfRange, "Must be at least 18"}}},
+		{"number above max", func(r *testRequest) { r.Age = 121 },
+			[]FieldViolation{{"age", ValidationOutOfRange, "Must be at most 120"}}},
+		{"absent pointer is not checked", func(r *testRequest) { r.Nickname = nil }, nil},
+		{"empty pointer string", func(r *testRequest) { r.Nickname = str("") },
+			[]FieldViolation{{"nickname", ValidationRequired, "This field cannot be empty"}}},
+		{"pointer string too long", func(r *testRequest) { r.Nickname = str("abcdef") },
+			[]FieldViolation{{"nickname", ValidationTooLong, "Must have at most 5 characters"}}},
+		{"length counts runes", func(r *testRequest) { r.Nickname = str("ééééé") }, nil},
+		{"too many items", func(r *testRequest) { r.Tags = []string{"ab", "cd", "ef"} },
+			[]FieldViolation{{"tags", ValidationTooLong, "Must have at most 2 items"}}},
+		{"dive into elements", func(r *testRequest) { r.Tags = []string{"ab", "c"} },
+			[]FieldViolation{{"tags[1]", ValidationTooShort, "Must have at least 2 characters"}}},
+		{"nested struct", func(r *testRequest) { r.Address = &testAddress{City: "", Country: "US"} },
+			[]FieldViolation{
+				{"address.city", ValidationRequired, "This field is required"},
+				{"address.country", ValidationInvalidValue, "Must be one of: LB, FR"},
+			}},
+		{"required_if met", func(r *testRequest) { r.Type = "company" },
+			[]FieldViolation{{"company", ValidationRequired, "This field is required"}}},
+		{"required_if not met", func(r *testRequest) { r.Type = "user"; r.Company = nil }, nil},
+		{"required_if satisfied validates nested", func(r *testRequest) { r.Type = "company"; r.Company = &testInfo{} },
+			[]FieldViolation{{"company.name", ValidationRequired, "This field is required"}}},
+		{"untagged json name", func(r *testRequest) { r.NoTag = "abcd" },
+			[]FieldViolation{{"NoTag", ValidationTooLong, "Must have at most 3 characters"}}},
+		{"several violations", func(r *testRequest) { r.Email = ""; r.Age = 0 },
+			[]FieldViolation{
+				{"email", ValidationRequired, "This field is required"},
+				{"age", ValidationOutOfRange, "Must be at least 18"},
+			}},
+	}
+
+	validator := NewRequestValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+			err := validator.Validate(&req)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			violations, ok := err.(ValidationErrors)
+			if !ok {
+				t.Fatalf("Validate() error = %v, want ValidationErrors", err)
+			}
+			if !reflect.DeepEqual([]FieldViolation(violations), tt.want) {
+				t.Errorf("Validate() = %+v, want %+v", violations, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationErrorsError(t *testing.T) {
+	err := ValidationErrors{
+		{Field: "email", Code: ValidationRequired, Message: "This field is required"},
+		{Field: "age", Code: ValidationOutOfRange, Message: "Must be at least 18"},
+	}
+	want := "invalid request: email: This field is required; age: Must be at least 18"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestRequestValidatorRejectsMalformedTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		request interface{}
+	}{
+		{"unknown rule", struct {
+			Name string `validate:"requird"`
+		}{}},
+		{"non-numeric bound", struct {
+			Name string `validate:"max=ten"`
+		}{}},
+		{"bound on a struct", struct {
+			Address testAddress `validate:"min=1"`
+		}{}},
+		{"email on a number", struct {
+			Age int `validate:"email"`
+		}{}},
+		{"dive on a string", struct {
+			Name string `validate:"dive,min=1"`
+		}{}},
+		{"required_if without value", struct {
+			Type string
+			Name string `validate:"required_if=Type"`
+		}{}},
+		{"required_if on unknown field", struct {
+			Name string `validate:"required_if=Kind company"`
+		}{}},
+		{"empty oneof", struct {
+			Type string `validate:"oneof="`
+		}{}},
+		{"malformed nested struct", struct {
+			Items []struct {
+				Name string `validate:"maxlen=3"`
+			} `validate:"dive"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewRequestValidator()
+			if err := validator.Register(tt.request); err == nil {
+				t.Error("Register() accepted a malformed tag")
+			}
+			err := validator.Validate(tt.request)
+			if err == nil {
+				t.Fatal("Validate() accepted a malformed tag")
+			}
+			if _, ok := err.(ValidationErrors); ok {
+				t.Errorf("Validate() = %v, want a tag error rather than ValidationErrors", err)
+			}
+		})
+	}
+}
+
+func TestRequestValidatorRegister(t *testing.T) {
+	validator := NewRequestValidator()
+	if err := validator.Register(testRequest{}, &testAddress{}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+}